@@ -0,0 +1,133 @@
+// Package analysis computes link metrics over the graph of a crawl: internal
+// PageRank, in/out degree, click depth from the seed, strongly connected
+// components and dead-end pages.
+package analysis
+
+import (
+	"crawler/crawler"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Graph is a directed graph of pages identified by their URL. It is safe for
+// concurrent use, so it can be fed straight from the crawler callbacks. Pages
+// are either crawled, their links being known, or only linked to (e.g. the
+// request failed or the page budget ran out), in which case they are neither
+// dead ends nor take part in PageRank until the end (see Analyze).
+type Graph struct {
+	seed     string
+	outLinks map[string]map[string]bool
+	crawled  map[string]bool
+	urls     map[string]string
+	m        sync.Mutex
+}
+
+// NewGraph returns a graph holding only the seed, the page the crawl
+// started from.
+func NewGraph(seed string) *Graph {
+	g := &Graph{
+		seed:     seed,
+		outLinks: make(map[string]map[string]bool),
+		crawled:  make(map[string]bool),
+		urls:     make(map[string]string),
+	}
+	if seedURL, err := url.Parse(seed); err == nil {
+		g.urls[crawler.PageKey(seedURL)] = seed
+	}
+	g.addPage(seed, false)
+
+	return g
+}
+
+// AddPage adds a crawled page without links, which is what dead ends look
+// like.
+func (g *Graph) AddPage(page string) {
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.addPage(page, true)
+}
+
+func (g *Graph) addPage(page string, crawled bool) {
+	if _, ok := g.outLinks[page]; !ok {
+		g.outLinks[page] = make(map[string]bool)
+	}
+	if crawled {
+		g.crawled[page] = true
+	}
+}
+
+// AddCrawledPage adds a page of a crawl along with its links, the pages they
+// lead to being only linked to until they are added too. Pages are told apart
+// like the crawler does (see crawler.PageKey), so links that only differ by
+// scheme, query or fragment lead to the same page, named after the first URL
+// seen for it.
+func (g *Graph) AddCrawledPage(page *crawler.LinksByTargetURL) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	from := g.pageURL(page.TargetURL)
+	g.addPage(from, true)
+	for _, link := range page.Links {
+		to := g.pageURL(link)
+		g.addPage(to, false)
+		if from != to {
+			g.outLinks[from][to] = true
+		}
+	}
+}
+
+func (g *Graph) pageURL(u *url.URL) string {
+	key := crawler.PageKey(u)
+	pageURL, ok := g.urls[key]
+	if !ok {
+		pageURL = crawler.PageURL(u)
+		g.urls[key] = pageURL
+	}
+
+	return pageURL
+}
+
+// AddLink adds a link between two crawled pages. Links from a page to itself
+// and repeated links are ignored, so degrees count distinct pages.
+func (g *Graph) AddLink(from string, to string) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	g.addPage(from, true)
+	g.addPage(to, true)
+	if from != to {
+		g.outLinks[from][to] = true
+	}
+}
+
+// snapshot returns the pages sorted by URL along with the adjacency lists as
+// indexes into that slice, which makes every computation deterministic, and
+// whether each page was crawled.
+func (g *Graph) snapshot() ([]string, [][]int, []bool) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	pages := make([]string, 0, len(g.outLinks))
+	for page := range g.outLinks {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+
+	index := make(map[string]int, len(pages))
+	for i, page := range pages {
+		index[page] = i
+	}
+
+	adjacency := make([][]int, len(pages))
+	crawled := make([]bool, len(pages))
+	for i, page := range pages {
+		crawled[i] = g.crawled[page]
+		for to := range g.outLinks[page] {
+			adjacency[i] = append(adjacency[i], index[to])
+		}
+		sort.Ints(adjacency[i])
+	}
+
+	return pages, adjacency, crawled
+}
@@ -0,0 +1,168 @@
+package analysis
+
+import "math"
+
+// DefaultDamping and DefaultIterations are the PageRank parameters Analyze
+// uses unless Options says otherwise.
+const (
+	DefaultDamping    = 0.85
+	DefaultIterations = 50
+)
+
+// pageRank computes the PageRank of every page, with the rank of dead ends
+// spread evenly across the graph. It stops after the given iterations or
+// once the ranks converge, whatever comes first. Only the crawled pages and
+// the links between them take part: as nothing is known of where the other
+// pages link to, they only get the rank their in-links give them once the
+// ranks converged (the way the original PageRank paper treats dangling links).
+func pageRank(adjacency [][]int, crawled []bool, damping float64, iterations int) []float64 {
+	ranks := make([]float64, len(adjacency))
+	var pages []int
+	links := make([][]int, len(adjacency))
+	for i, targets := range adjacency {
+		if !crawled[i] {
+			continue
+		}
+		pages = append(pages, i)
+		for _, j := range targets {
+			if crawled[j] {
+				links[i] = append(links[i], j)
+			}
+		}
+	}
+	n := len(pages)
+	if n == 0 {
+		return ranks
+	}
+
+	for _, i := range pages {
+		ranks[i] = 1 / float64(n)
+	}
+
+	for iteration := 0; iteration < iterations; iteration++ {
+		var danglingRank float64
+		for _, i := range pages {
+			if len(links[i]) == 0 {
+				danglingRank += ranks[i]
+			}
+		}
+
+		base := (1-damping)/float64(n) + damping*danglingRank/float64(n)
+		next := make([]float64, len(adjacency))
+		for _, i := range pages {
+			next[i] = base
+		}
+		for _, i := range pages {
+			for _, j := range links[i] {
+				next[j] += damping * ranks[i] / float64(len(links[i]))
+			}
+		}
+
+		var delta float64
+		for i := range ranks {
+			delta += math.Abs(next[i] - ranks[i])
+		}
+		ranks = next
+		if delta < 1e-10 {
+			break
+		}
+	}
+
+	for _, i := range pages {
+		for _, j := range adjacency[i] {
+			if !crawled[j] {
+				ranks[j] += damping * ranks[i] / float64(len(adjacency[i]))
+			}
+		}
+	}
+
+	return ranks
+}
+
+// shortestPaths runs a breadth-first search from the seed, returning the
+// parent of every page reached (-1 for the seed) and their click depth. Pages
+// that can't be reached have a depth of -1.
+func shortestPaths(adjacency [][]int, seed int) ([]int, []int) {
+	parents := make([]int, len(adjacency))
+	depths := make([]int, len(adjacency))
+	for i := range depths {
+		parents[i] = -1
+		depths[i] = -1
+	}
+	if seed < 0 {
+		return parents, depths
+	}
+
+	depths[seed] = 0
+	queue := []int{seed}
+	for len(queue) > 0 {
+		page := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacency[page] {
+			if depths[next] >= 0 {
+				continue
+			}
+			depths[next] = depths[page] + 1
+			parents[next] = page
+			queue = append(queue, next)
+		}
+	}
+
+	return parents, depths
+}
+
+// stronglyConnectedComponents uses Tarjan's algorithm and returns the
+// component of every page. Components are numbered in the order they are
+// completed.
+func stronglyConnectedComponents(adjacency [][]int) []int {
+	n := len(adjacency)
+	indexes := make([]int, n)
+	lowLinks := make([]int, n)
+	onStack := make([]bool, n)
+	components := make([]int, n)
+	for i := range indexes {
+		indexes[i] = -1
+	}
+
+	var stack []int
+	var index, component int
+	var visit func(page int)
+	visit = func(page int) {
+		indexes[page] = index
+		lowLinks[page] = index
+		index++
+		stack = append(stack, page)
+		onStack[page] = true
+
+		for _, next := range adjacency[page] {
+			if indexes[next] < 0 {
+				visit(next)
+				lowLinks[page] = min(lowLinks[page], lowLinks[next])
+			} else if onStack[next] {
+				lowLinks[page] = min(lowLinks[page], indexes[next])
+			}
+		}
+
+		if lowLinks[page] != indexes[page] {
+			return
+		}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			components[top] = component
+			if top == page {
+				break
+			}
+		}
+		component++
+	}
+
+	for page := range adjacency {
+		if indexes[page] < 0 {
+			visit(page)
+		}
+	}
+
+	return components
+}
@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// Options tunes the PageRank computed by Analyze. Zero values use the
+// defaults.
+type Options struct {
+	Damping    float64
+	Iterations int
+}
+
+// PageMetrics are the metrics of a single page. ClickDepth is -1 and ClickPath
+// is empty when the page can't be reached from the seed. Crawled is false for
+// the pages that were only linked to, which are never dead ends.
+type PageMetrics struct {
+	URL        string   `json:"url"`
+	PageRank   float64  `json:"page_rank"`
+	InDegree   int      `json:"in_degree"`
+	OutDegree  int      `json:"out_degree"`
+	ClickDepth int      `json:"click_depth"`
+	ClickPath  []string `json:"click_path,omitempty"`
+	Component  int      `json:"component"`
+	Crawled    bool     `json:"crawled"`
+	DeadEnd    bool     `json:"dead_end"`
+}
+
+// Report is what Analyze finds out about a graph. Seed is the page click
+// depths are measured from.
+type Report struct {
+	Seed       string         `json:"seed"`
+	Pages      []*PageMetrics `json:"pages"`
+	Components [][]string     `json:"components"`
+	DeadEnds   []string       `json:"dead_ends"`
+}
+
+// Analyze computes the metrics of every page of the graph. Components are
+// sorted from the largest to the smallest and pages are sorted by URL.
+func Analyze(g *Graph, options Options) *Report {
+	if options.Damping <= 0 || options.Damping >= 1 {
+		options.Damping = DefaultDamping
+	}
+	if options.Iterations <= 0 {
+		options.Iterations = DefaultIterations
+	}
+
+	pages, adjacency, crawled := g.snapshot()
+	seed := sort.SearchStrings(pages, g.seed)
+	if seed == len(pages) || pages[seed] != g.seed {
+		seed = -1
+	}
+
+	ranks := pageRank(adjacency, crawled, options.Damping, options.Iterations)
+	parents, depths := shortestPaths(adjacency, seed)
+	components := stronglyConnectedComponents(adjacency)
+
+	inDegrees := make([]int, len(pages))
+	for _, links := range adjacency {
+		for _, to := range links {
+			inDegrees[to]++
+		}
+	}
+
+	report := &Report{Seed: g.seed, Pages: make([]*PageMetrics, len(pages)), DeadEnds: []string{}}
+	componentPages := make(map[int][]string)
+	for i, page := range pages {
+		metrics := &PageMetrics{
+			URL:        page,
+			PageRank:   ranks[i],
+			InDegree:   inDegrees[i],
+			OutDegree:  len(adjacency[i]),
+			ClickDepth: depths[i],
+			Crawled:    crawled[i],
+			DeadEnd:    crawled[i] && len(adjacency[i]) == 0,
+		}
+		if depths[i] >= 0 {
+			metrics.ClickPath = make([]string, depths[i]+1)
+			for step, p := depths[i], i; p >= 0; step, p = step-1, parents[p] {
+				metrics.ClickPath[step] = pages[p]
+			}
+		}
+		if metrics.DeadEnd {
+			report.DeadEnds = append(report.DeadEnds, page)
+		}
+
+		report.Pages[i] = metrics
+		componentPages[components[i]] = append(componentPages[components[i]], page)
+	}
+
+	for _, members := range componentPages {
+		report.Components = append(report.Components, members)
+	}
+	sort.Slice(report.Components, func(i, j int) bool {
+		if len(report.Components[i]) != len(report.Components[j]) {
+			return len(report.Components[i]) > len(report.Components[j])
+		}
+		return report.Components[i][0] < report.Components[j][0]
+	})
+
+	// Components are numbered after sorting, so the largest one is always 0.
+	componentNumbers := make(map[string]int)
+	for number, members := range report.Components {
+		for _, page := range members {
+			componentNumbers[page] = number
+		}
+	}
+	for _, metrics := range report.Pages {
+		metrics.Component = componentNumbers[metrics.URL]
+	}
+
+	return report
+}
+
+// WriteJSON writes the report as an indented JSON object.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+var sortFunctions = map[string]func(a, b *PageMetrics) bool{
+	"url":      func(a, b *PageMetrics) bool { return a.URL < b.URL },
+	"pagerank": func(a, b *PageMetrics) bool { return a.PageRank > b.PageRank },
+	"in":       func(a, b *PageMetrics) bool { return a.InDegree > b.InDegree },
+	"out":      func(a, b *PageMetrics) bool { return a.OutDegree > b.OutDegree },
+	"depth": func(a, b *PageMetrics) bool {
+		// Unreachable pages go last.
+		if (a.ClickDepth < 0) != (b.ClickDepth < 0) {
+			return b.ClickDepth < 0
+		}
+		return a.ClickDepth < b.ClickDepth
+	},
+}
+
+// SortColumns are the columns WriteTable can sort by.
+var SortColumns = []string{"pagerank", "in", "out", "depth", "url"}
+
+// WriteTable writes the page metrics as an aligned text table sorted by one
+// of SortColumns. Ties are sorted by URL.
+func (r *Report) WriteTable(w io.Writer, sortBy string) error {
+	less, ok := sortFunctions[sortBy]
+	if !ok {
+		return fmt.Errorf("can't sort by %q, use one of %v", sortBy, SortColumns)
+	}
+
+	pages := append([]*PageMetrics(nil), r.Pages...)
+	sort.SliceStable(pages, func(i, j int) bool {
+		if less(pages[i], pages[j]) {
+			return true
+		}
+		if less(pages[j], pages[i]) {
+			return false
+		}
+		return pages[i].URL < pages[j].URL
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tPAGERANK\tIN\tOUT\tDEPTH\tCOMPONENT\tDEAD END")
+	for _, page := range pages {
+		depth := "-"
+		if page.ClickDepth >= 0 {
+			depth = fmt.Sprint(page.ClickDepth)
+		}
+		fmt.Fprintf(tw, "%s\t%.6f\t%d\t%d\t%s\t%d\t%t\n",
+			page.URL, page.PageRank, page.InDegree, page.OutDegree, depth, page.Component, page.DeadEnd)
+	}
+
+	return tw.Flush()
+}
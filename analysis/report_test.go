@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"bytes"
+	"crawler/crawler"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func makeGraphForTest() *Graph {
+	g := NewGraph("https://abc.com/")
+	g.AddLink("https://abc.com/", "https://abc.com/b")
+	g.AddLink("https://abc.com/", "https://abc.com/c")
+	g.AddLink("https://abc.com/", "https://abc.com/c")
+	g.AddLink("https://abc.com/b", "https://abc.com/c")
+	g.AddLink("https://abc.com/b", "https://abc.com/e")
+	g.AddLink("https://abc.com/c", "https://abc.com/")
+	g.AddLink("https://abc.com/c", "https://abc.com/c")
+	g.AddLink("https://abc.com/d", "https://abc.com/")
+
+	return g
+}
+
+func metricsByURL(report *Report) map[string]*PageMetrics {
+	metrics := make(map[string]*PageMetrics)
+	for _, page := range report.Pages {
+		metrics[page.URL] = page
+	}
+
+	return metrics
+}
+
+func TestAnalyze_Degrees_Success(t *testing.T) {
+	metrics := metricsByURL(Analyze(makeGraphForTest(), Options{}))
+
+	assert.Equal(t, 2, metrics["https://abc.com/"].OutDegree)
+	assert.Equal(t, 2, metrics["https://abc.com/"].InDegree)
+	assert.Equal(t, 2, metrics["https://abc.com/c"].InDegree)
+	assert.Equal(t, 1, metrics["https://abc.com/c"].OutDegree)
+	assert.Equal(t, 0, metrics["https://abc.com/d"].InDegree)
+	assert.True(t, metrics["https://abc.com/e"].DeadEnd)
+	assert.False(t, metrics["https://abc.com/b"].DeadEnd)
+}
+
+func TestAnalyze_ClickPaths_Success(t *testing.T) {
+	metrics := metricsByURL(Analyze(makeGraphForTest(), Options{}))
+
+	assert.Equal(t, 0, metrics["https://abc.com/"].ClickDepth)
+	assert.Equal(t, []string{"https://abc.com/"}, metrics["https://abc.com/"].ClickPath)
+	assert.Equal(t, 2, metrics["https://abc.com/e"].ClickDepth)
+	assert.Equal(t, []string{"https://abc.com/", "https://abc.com/b", "https://abc.com/e"}, metrics["https://abc.com/e"].ClickPath)
+	assert.Equal(t, -1, metrics["https://abc.com/d"].ClickDepth)
+	assert.Empty(t, metrics["https://abc.com/d"].ClickPath)
+}
+
+func TestAnalyze_ComponentsAndDeadEnds_Success(t *testing.T) {
+	report := Analyze(makeGraphForTest(), Options{})
+
+	assert.Equal(t, [][]string{
+		{"https://abc.com/", "https://abc.com/b", "https://abc.com/c"},
+		{"https://abc.com/d"},
+		{"https://abc.com/e"},
+	}, report.Components)
+	assert.Equal(t, []string{"https://abc.com/e"}, report.DeadEnds)
+
+	metrics := metricsByURL(report)
+	assert.Equal(t, 0, metrics["https://abc.com/b"].Component)
+	assert.Equal(t, 2, metrics["https://abc.com/e"].Component)
+}
+
+func TestAnalyze_PageRank_Success(t *testing.T) {
+	report := Analyze(makeGraphForTest(), Options{Damping: 0.85, Iterations: 100})
+
+	var total float64
+	for _, page := range report.Pages {
+		total += page.PageRank
+	}
+	assert.InDelta(t, 1, total, 1e-9)
+
+	metrics := metricsByURL(report)
+	assert.Greater(t, metrics["https://abc.com/c"].PageRank, metrics["https://abc.com/b"].PageRank)
+	assert.Greater(t, metrics["https://abc.com/"].PageRank, metrics["https://abc.com/d"].PageRank)
+	assert.InDelta(t, (1-0.85)/5+0.85*metrics["https://abc.com/e"].PageRank/5, metrics["https://abc.com/d"].PageRank, 1e-9)
+}
+
+func TestAnalyze_PageRank_Symmetric_Success(t *testing.T) {
+	g := NewGraph("a")
+	g.AddLink("a", "b")
+	g.AddLink("b", "a")
+
+	for _, page := range Analyze(g, Options{}).Pages {
+		assert.True(t, math.Abs(page.PageRank-0.5) < 1e-9)
+	}
+}
+
+func TestAnalyze_CrawledPages_SamePage(t *testing.T) {
+	parse := func(rawURL string) *url.URL {
+		u, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		return u
+	}
+
+	g := NewGraph("https://abc.com/")
+	g.AddCrawledPage(&crawler.LinksByTargetURL{
+		TargetURL: parse("https://abc.com/"),
+		Links:     []*url.URL{parse("https://abc.com/a#reviews"), parse("https://abc.com/a?q=shoes"), parse("https://abc.com/#top")},
+	})
+	g.AddCrawledPage(&crawler.LinksByTargetURL{
+		TargetURL: parse("https://abc.com/a?q=shoes"),
+		Links:     []*url.URL{parse("https://abc.com/")},
+	})
+
+	report := Analyze(g, Options{})
+	assert.Len(t, report.Pages, 2)
+	assert.Empty(t, report.DeadEnds)
+
+	metrics := metricsByURL(report)
+	assert.Equal(t, 1, metrics["https://abc.com/"].OutDegree)
+	assert.Equal(t, 1, metrics["https://abc.com/a"].ClickDepth)
+	assert.InDelta(t, 0.5, metrics["https://abc.com/a"].PageRank, 1e-9)
+}
+
+func TestAnalyze_CrawledPages_NotCrawled(t *testing.T) {
+	parse := func(rawURL string) *url.URL {
+		u, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		return u
+	}
+
+	g := NewGraph("https://abc.com/")
+	g.AddCrawledPage(&crawler.LinksByTargetURL{
+		TargetURL: parse("https://abc.com/"),
+		Links:     []*url.URL{parse("https://abc.com/a"), parse("https://abc.com/failed")},
+	})
+	g.AddCrawledPage(&crawler.LinksByTargetURL{
+		TargetURL: parse("https://abc.com/a"),
+		Links:     []*url.URL{parse("http://abc.com/")},
+	})
+
+	report := Analyze(g, Options{})
+	assert.Len(t, report.Pages, 3)
+	assert.Empty(t, report.DeadEnds)
+
+	metrics := metricsByURL(report)
+	assert.False(t, metrics["https://abc.com/failed"].Crawled)
+	assert.False(t, metrics["https://abc.com/failed"].DeadEnd)
+	assert.Equal(t, 1, metrics["https://abc.com/"].InDegree)
+	assert.InDelta(t, 1, metrics["https://abc.com/"].PageRank+metrics["https://abc.com/a"].PageRank, 1e-9)
+	assert.InDelta(t, 0.85*metrics["https://abc.com/"].PageRank/2, metrics["https://abc.com/failed"].PageRank, 1e-9)
+}
+
+func TestReport_WriteJSON_Success(t *testing.T) {
+	var output bytes.Buffer
+	assert.NoError(t, Analyze(makeGraphForTest(), Options{}).WriteJSON(&output))
+
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &decoded))
+	assert.Equal(t, "https://abc.com/", decoded.Seed)
+	assert.Len(t, decoded.Pages, 5)
+	assert.Contains(t, output.String(), `"click_path": [`)
+}
+
+func TestReport_WriteTable_Success(t *testing.T) {
+	report := Analyze(makeGraphForTest(), Options{})
+
+	var output bytes.Buffer
+	assert.NoError(t, report.WriteTable(&output, "depth"))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Len(t, lines, 6)
+	assert.True(t, strings.HasPrefix(lines[0], "URL "))
+	assert.True(t, strings.HasPrefix(lines[1], "https://abc.com/ "))
+	assert.True(t, strings.HasPrefix(lines[5], "https://abc.com/d "))
+	assert.Contains(t, lines[5], " - ")
+
+	output.Reset()
+	assert.NoError(t, report.WriteTable(&output, "in"))
+	lines = strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.True(t, strings.HasPrefix(lines[1], "https://abc.com/ "))
+	assert.True(t, strings.HasPrefix(lines[2], "https://abc.com/c "))
+
+	assert.Error(t, report.WriteTable(&output, "size"))
+}
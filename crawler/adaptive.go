@@ -0,0 +1,224 @@
+package crawler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of the AdaptiveConcurrencyParams.
+const (
+	DefaultInitialConcurrency = 4
+	DefaultMaxConcurrency     = 100
+	DefaultTargetLatency      = time.Second
+	DefaultMaxErrorRate       = 0.1
+	DefaultAdaptiveWindow     = 20
+)
+
+// AdaptiveConcurrencyParams configures an AdaptiveConcurrency. Zero values use
+// the defaults, except for Logger: decisions are only logged when it is set.
+type AdaptiveConcurrencyParams struct {
+	InitialConcurrency int
+	MinConcurrency     int
+	MaxConcurrency     int
+	TargetLatency      time.Duration
+	MaxErrorRate       float64
+	Window             int
+	Logger             *slog.Logger
+}
+
+// AdaptiveConcurrency finds how many requests each host can take at once,
+// using AIMD (additive increase, multiplicative decrease): after every window
+// of responses from a host, its limit grows by one when the average latency and
+// the error rate (request errors, 429 and 5XX responses) were under target, and
+// is halved otherwise. Its Middleware holds requests back while a host is at
+// its limit.
+type AdaptiveConcurrency struct {
+	params   AdaptiveConcurrencyParams
+	hosts    map[string]*HostConcurrency
+	onResize func(totalConcurrency int)
+	m        sync.Mutex
+	cond     *sync.Cond
+}
+
+// HostConcurrency is the state of a host in an AdaptiveConcurrency.
+type HostConcurrency struct {
+	Host         string
+	Limit        int
+	InFlight     int
+	Increases    int
+	Decreases    int
+	responses    int
+	errors       int
+	totalLatency time.Duration
+}
+
+// NewAdaptiveConcurrency starts every host at params.InitialConcurrency.
+func NewAdaptiveConcurrency(params AdaptiveConcurrencyParams) *AdaptiveConcurrency {
+	if params.MinConcurrency <= 0 {
+		params.MinConcurrency = 1
+	}
+	if params.MaxConcurrency <= 0 {
+		params.MaxConcurrency = DefaultMaxConcurrency
+	}
+	params.MaxConcurrency = max(params.MaxConcurrency, params.MinConcurrency)
+	if params.InitialConcurrency <= 0 {
+		params.InitialConcurrency = DefaultInitialConcurrency
+	}
+	params.InitialConcurrency = min(max(params.InitialConcurrency, params.MinConcurrency), params.MaxConcurrency)
+	if params.TargetLatency <= 0 {
+		params.TargetLatency = DefaultTargetLatency
+	}
+	if params.MaxErrorRate <= 0 {
+		params.MaxErrorRate = DefaultMaxErrorRate
+	}
+	if params.Window <= 0 {
+		params.Window = DefaultAdaptiveWindow
+	}
+
+	a := &AdaptiveConcurrency{params: params, hosts: make(map[string]*HostConcurrency)}
+	a.cond = sync.NewCond(&a.m)
+
+	return a
+}
+
+// OnResize sets a function called with the sum of the limits of every host
+// whenever it changes, e.g. to resize a WorkerPool.
+func (a *AdaptiveConcurrency) OnResize(onResize func(totalConcurrency int)) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.onResize = onResize
+}
+
+// Middleware waits for the host of a request to be under its limit before
+// passing the request on, then counts the response towards the next decision.
+func (a *AdaptiveConcurrency) Middleware() FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+			host := request.URL.Host
+			if err := a.acquire(ctx, host); err != nil {
+				return nil, err
+			}
+
+			start := time.Now()
+			response, err := next.Fetch(ctx, request)
+			latency := time.Since(start)
+			if response != nil && response.Duration > 0 {
+				latency = response.Duration
+			}
+
+			failed := err != nil || response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+			a.release(host, latency, failed)
+
+			return response, err
+		})
+	}
+}
+
+func (a *AdaptiveConcurrency) acquire(ctx context.Context, host string) error {
+	stop := context.AfterFunc(ctx, func() {
+		a.m.Lock()
+		defer a.m.Unlock()
+		a.cond.Broadcast()
+	})
+	defer stop()
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	hostConcurrency := a.hostConcurrencyFor(host)
+	for hostConcurrency.InFlight >= hostConcurrency.Limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		a.cond.Wait()
+	}
+	hostConcurrency.InFlight++
+
+	return nil
+}
+
+func (a *AdaptiveConcurrency) hostConcurrencyFor(host string) *HostConcurrency {
+	hostConcurrency, ok := a.hosts[host]
+	if !ok {
+		hostConcurrency = &HostConcurrency{Host: host, Limit: a.params.InitialConcurrency}
+		a.hosts[host] = hostConcurrency
+		a.resized()
+	}
+
+	return hostConcurrency
+}
+
+func (a *AdaptiveConcurrency) release(host string, latency time.Duration, failed bool) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	hostConcurrency := a.hosts[host]
+	hostConcurrency.InFlight--
+	hostConcurrency.responses++
+	hostConcurrency.totalLatency += latency
+	if failed {
+		hostConcurrency.errors++
+	}
+	a.cond.Broadcast()
+
+	if hostConcurrency.responses < a.params.Window {
+		return
+	}
+
+	averageLatency := hostConcurrency.totalLatency / time.Duration(hostConcurrency.responses)
+	errorRate := float64(hostConcurrency.errors) / float64(hostConcurrency.responses)
+	hostConcurrency.responses, hostConcurrency.errors, hostConcurrency.totalLatency = 0, 0, 0
+
+	limit := hostConcurrency.Limit
+	if averageLatency > a.params.TargetLatency || errorRate > a.params.MaxErrorRate {
+		limit = max(limit/2, a.params.MinConcurrency)
+	} else {
+		limit = min(limit+1, a.params.MaxConcurrency)
+	}
+	if limit == hostConcurrency.Limit {
+		return
+	}
+
+	if limit > hostConcurrency.Limit {
+		hostConcurrency.Increases++
+	} else {
+		hostConcurrency.Decreases++
+	}
+	if a.params.Logger != nil {
+		a.params.Logger.Info("concurrency changed", "host", host, "from", hostConcurrency.Limit, "to", limit,
+			"latency", averageLatency, "error_rate", errorRate)
+	}
+	hostConcurrency.Limit = limit
+	a.resized()
+}
+
+func (a *AdaptiveConcurrency) resized() {
+	if a.onResize == nil {
+		return
+	}
+
+	var totalConcurrency int
+	for _, hostConcurrency := range a.hosts {
+		totalConcurrency += hostConcurrency.Limit
+	}
+	a.onResize(totalConcurrency)
+}
+
+// Stats returns the state of every host, sorted by host.
+func (a *AdaptiveConcurrency) Stats() []HostConcurrency {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	stats := make([]HostConcurrency, 0, len(a.hosts))
+	for _, hostConcurrency := range a.hosts {
+		stats = append(stats, *hostConcurrency)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+
+	return stats
+}
@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveConcurrency_AIMD(t *testing.T) {
+	var responses []*FetchResponse
+	fetcher := FetcherFunc(func(context.Context, *FetchRequest) (*FetchResponse, error) {
+		response := responses[0]
+		responses = responses[1:]
+		if response == nil {
+			return nil, errors.New("connection reset")
+		}
+		return response, nil
+	})
+
+	var logs bytes.Buffer
+	var totals []int
+	adaptiveConcurrency := NewAdaptiveConcurrency(AdaptiveConcurrencyParams{
+		InitialConcurrency: 4,
+		MaxConcurrency:     5,
+		TargetLatency:      time.Second,
+		MaxErrorRate:       0.4,
+		Window:             2,
+		Logger:             newTestLogger(&logs),
+	})
+	adaptiveConcurrency.OnResize(func(totalConcurrency int) {
+		totals = append(totals, totalConcurrency)
+	})
+	adaptiveFetcher := adaptiveConcurrency.Middleware()(fetcher)
+
+	fast := &FetchResponse{StatusCode: http.StatusOK, Duration: 100 * time.Millisecond}
+	slow := &FetchResponse{StatusCode: http.StatusOK, Duration: 3 * time.Second}
+	tooMany := &FetchResponse{StatusCode: http.StatusTooManyRequests, Duration: 100 * time.Millisecond}
+	unavailable := &FetchResponse{StatusCode: http.StatusServiceUnavailable, Duration: 100 * time.Millisecond}
+	responses = []*FetchResponse{
+		fast, fast, // 4 -> 5
+		fast, fast, // stays at the maximum
+		fast, slow, // 5 -> 2
+		unavailable, tooMany, // 2 -> 1
+		nil, fast, // stays at the minimum
+	}
+
+	request := NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com"))
+	for range responses {
+		_, _ = adaptiveFetcher.Fetch(context.Background(), request)
+	}
+
+	assert.Equal(t, []int{4, 5, 2, 1}, totals)
+	assert.Equal(t, "level=INFO msg=\"concurrency changed\" host=abc.com from=4 to=5 latency=100ms error_rate=0\n"+
+		"level=INFO msg=\"concurrency changed\" host=abc.com from=5 to=2 latency=1.55s error_rate=0\n"+
+		"level=INFO msg=\"concurrency changed\" host=abc.com from=2 to=1 latency=100ms error_rate=1\n", logs.String())
+
+	stats := adaptiveConcurrency.Stats()
+	assert.Len(t, stats, 1)
+	assert.Equal(t, HostConcurrency{Host: "abc.com", Limit: 1, Increases: 1, Decreases: 2}, stats[0])
+}
+
+func TestAdaptiveConcurrency_LimitsInFlightRequests(t *testing.T) {
+	var m sync.Mutex
+	var inFlight, maxInFlight int
+	fetcher := FetcherFunc(func(context.Context, *FetchRequest) (*FetchResponse, error) {
+		m.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		m.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		m.Lock()
+		inFlight--
+		m.Unlock()
+		return &FetchResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	adaptiveConcurrency := NewAdaptiveConcurrency(AdaptiveConcurrencyParams{InitialConcurrency: 2, Window: 1000})
+	adaptiveFetcher := adaptiveConcurrency.Middleware()(fetcher)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := adaptiveFetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com")))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, maxInFlight)
+}
+
+func TestAdaptiveConcurrency_Canceled(t *testing.T) {
+	release := make(chan struct{})
+	fetcher := FetcherFunc(func(context.Context, *FetchRequest) (*FetchResponse, error) {
+		<-release
+		return &FetchResponse{StatusCode: http.StatusOK}, nil
+	})
+
+	adaptiveConcurrency := NewAdaptiveConcurrency(AdaptiveConcurrencyParams{InitialConcurrency: 1})
+	adaptiveFetcher := adaptiveConcurrency.Middleware()(fetcher)
+	request := NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com"))
+
+	go func() {
+		_, _ = adaptiveFetcher.Fetch(context.Background(), request)
+	}()
+	for len(adaptiveConcurrency.Stats()) == 0 || adaptiveConcurrency.Stats()[0].InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := adaptiveFetcher.Fetch(ctx, request)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(release)
+}
+
+func TestCrawler_GetAllLinksFor_AdaptiveConcurrency(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com": NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/b">B</a><a href="/c">C</a>`),
+	})
+
+	adaptiveConcurrency := NewAdaptiveConcurrency(AdaptiveConcurrencyParams{InitialConcurrency: 2, Window: 2})
+	crawler := New(WithFetcher(fetcher), WithWorkers(10), WithRetryAttempts(1), WithAdaptiveConcurrency(adaptiveConcurrency))
+
+	var processed int
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(*LinksByTargetURL) {
+		processed++
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, 4, processed)
+	// 404s count as successes, so the limit grew twice.
+	assert.Equal(t, 4, adaptiveConcurrency.Stats()[0].Limit)
+	assert.Equal(t, 4, crawler.workerPool.Size())
+}
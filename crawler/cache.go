@@ -0,0 +1,376 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// cacheableStatusCodes are the status codes whose responses are stored.
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// HTTPCache keeps the responses of GET requests on disk, one file per
+// normalized URL, so that a crawl run again does not download pages that did
+// not change. Responses still fresh according to their Cache-Control or
+// Expires headers are served from the disk. Stale ones are revalidated with
+// If-None-Match and If-Modified-Since, a 304 Not Modified being answered with
+// the stored response. A response is only stored once its body was read to the
+// end, so that the ones its caller left unread, e.g. because the Crawler did
+// not want their content type or found them too large, are not.
+type HTTPCache struct {
+	dir string
+	now func() time.Time
+
+	hits          atomic.Int64
+	revalidations atomic.Int64
+	misses        atomic.Int64
+	stores        atomic.Int64
+	bytesSaved    atomic.Int64
+	errors        atomic.Int64
+}
+
+// CacheStats tells how the requests went through an HTTPCache. Hits were
+// served from the disk, Revalidations were confirmed by a 304 and Misses were
+// downloaded. BytesSaved counts the bodies of the first two.
+type CacheStats struct {
+	Hits          int64
+	Revalidations int64
+	Misses        int64
+	Stores        int64
+	BytesSaved    int64
+	Errors        int64
+}
+
+// HitRate is the share of requests that did not download the page again.
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Revalidations + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits+s.Revalidations) / float64(total)
+}
+
+// cacheEntry is what is stored for a response.
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	RedirectedTo string      `json:"redirected_to,omitempty"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+// NewHTTPCache keeps responses in dir, creating it if needed.
+func NewHTTPCache(dir string) (*HTTPCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &HTTPCache{dir: dir, now: time.Now}, nil
+}
+
+// Stats returns the counters of the cache since it was created.
+func (c *HTTPCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Revalidations: c.revalidations.Load(),
+		Misses:        c.misses.Load(),
+		Stores:        c.stores.Load(),
+		BytesSaved:    c.bytesSaved.Load(),
+		Errors:        c.errors.Load(),
+	}
+}
+
+// Middleware serves GET requests from the cache. Requests that are already
+// conditional are sent as they are, their caller expecting the 304.
+func (c *HTTPCache) Middleware() FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+			if request.Method != http.MethodGet || request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != "" {
+				return next.Fetch(ctx, request)
+			}
+
+			return c.fetch(ctx, next, request)
+		})
+	}
+}
+
+func (c *HTTPCache) fetch(ctx context.Context, next Fetcher, request *FetchRequest) (*FetchResponse, error) {
+	path := c.pathOf(request.URL)
+	entry, err := c.load(path)
+	if err != nil {
+		c.errors.Add(1)
+	}
+
+	now := c.now()
+	if entry != nil && now.Before(entry.StoredAt.Add(freshnessLifetime(entry.Header, entry.StoredAt))) {
+		c.hits.Add(1)
+		c.bytesSaved.Add(int64(len(entry.Body)))
+		return entry.response(0), nil
+	}
+
+	if entry != nil && (entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "") {
+		conditional := &FetchRequest{Method: request.Method, URL: request.URL, Header: request.Header.Clone()}
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			conditional.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			conditional.Header.Set("If-Modified-Since", lastModified)
+		}
+		request = conditional
+	}
+
+	response, err := next.Fetch(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotModified && entry != nil {
+		response.Body.Close()
+		c.revalidations.Add(1)
+		c.bytesSaved.Add(int64(len(entry.Body)))
+
+		// The 304 carries the headers that changed, e.g. a new Cache-Control.
+		for key, values := range response.Header {
+			if key != "Content-Length" {
+				entry.Header[key] = values
+			}
+		}
+		entry.StoredAt = now
+		if err := c.store(path, entry); err != nil {
+			c.errors.Add(1)
+		}
+		return entry.response(response.Duration), nil
+	}
+
+	c.misses.Add(1)
+	if !c.storable(response) {
+		return response, nil
+	}
+
+	entry = &cacheEntry{URL: request.URL.String(), StatusCode: response.StatusCode, Header: response.Header.Clone(), StoredAt: now}
+	if response.RedirectedTo != nil {
+		entry.RedirectedTo = response.RedirectedTo.String()
+	}
+	response.Body = &storingReadCloser{ReadCloser: response.Body, store: func(body []byte) {
+		entry.Body = body
+		if err := c.store(path, entry); err != nil {
+			c.errors.Add(1)
+		} else {
+			c.stores.Add(1)
+		}
+	}}
+
+	return response, nil
+}
+
+// storingReadCloser keeps what is read of a body and calls store with it once
+// the body was read to the end.
+type storingReadCloser struct {
+	io.ReadCloser
+	body  bytes.Buffer
+	store func(body []byte)
+	done  bool
+}
+
+func (s *storingReadCloser) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if s.done {
+		return n, err
+	}
+
+	s.body.Write(p[:n])
+	if err == io.EOF {
+		s.done = true
+		s.store(s.body.Bytes())
+	} else if err != nil {
+		s.done = true
+	}
+
+	return n, err
+}
+
+// storable tells whether a response is worth storing: it must be allowed to be,
+// and either stay fresh for a while or be possible to revalidate.
+func (c *HTTPCache) storable(response *FetchResponse) bool {
+	if !cacheableStatusCodes[response.StatusCode] {
+		return false
+	}
+	if _, ok := parseCacheControl(response.Header.Get("Cache-Control"))["no-store"]; ok {
+		return false
+	}
+
+	return freshnessLifetime(response.Header, c.now()) > 0 ||
+		response.Header.Get("ETag") != "" || response.Header.Get("Last-Modified") != ""
+}
+
+func (e *cacheEntry) response(duration time.Duration) *FetchResponse {
+	response := &FetchResponse{
+		StatusCode:    e.StatusCode,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Duration:      duration,
+	}
+	if redirectedTo, err := url.Parse(e.RedirectedTo); err == nil && e.RedirectedTo != "" {
+		response.RedirectedTo = redirectedTo
+	}
+
+	return response
+}
+
+// pathOf returns the file of a URL, named after the hash of its normalized
+// form and spread over 256 directories.
+func (c *HTTPCache) pathOf(targetURL *url.URL) string {
+	sum := sha256.Sum256([]byte(NormalizeURL(targetURL)))
+	key := hex.EncodeToString(sum[:])
+
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// load returns nil when the URL is not in the cache.
+func (c *HTTPCache) load(path string) (*cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Header == nil {
+		entry.Header = make(http.Header)
+	}
+
+	return &entry, nil
+}
+
+// store writes the entry to a temporary file first, so that concurrent
+// readers never see half of it.
+func (c *HTTPCache) store(path string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return writeFileAtomically(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomically writes a temporary file next to path and renames it to
+// path once complete, so that path always holds a whole file.
+func writeFileAtomically(path string, write func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// NormalizeURL returns the form of a URL two URLs of the same page share: the
+// scheme and host lowercased, default ports and fragments dropped and the
+// query parameters sorted.
+func NormalizeURL(targetURL *url.URL) string {
+	normalized := *targetURL
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.Host = strings.ToLower(normalized.Host)
+	if port := normalized.Port(); (normalized.Scheme == "http" && port == "80") || (normalized.Scheme == "https" && port == "443") {
+		normalized.Host = normalized.Hostname()
+	}
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+	normalized.Fragment, normalized.RawFragment = "", ""
+	normalized.RawQuery = normalized.Query().Encode()
+
+	return normalized.String()
+}
+
+// freshnessLifetime is how long a response stays fresh after date, from its
+// Cache-Control max-age or its Expires header. Responses without either are
+// always revalidated.
+func freshnessLifetime(header http.Header, date time.Time) time.Duration {
+	cacheControl := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := cacheControl["no-cache"]; ok {
+		return 0
+	}
+	if _, ok := cacheControl["no-store"]; ok {
+		return 0
+	}
+	if maxAge, ok := cacheControl["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		if responseDate, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = responseDate
+		}
+		return max(expiresAt.Sub(date), 0)
+	}
+
+	return 0
+}
+
+// parseCacheControl returns the directives of a Cache-Control header, keyed by
+// their lowercased name.
+func parseCacheControl(cacheControl string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+
+	return directives
+}
@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cachedSite serves body with the given headers, answering the conditional
+// requests matching its ETag with a 304.
+func cachedSite(requests *[]*FetchRequest, header http.Header, body string) Fetcher {
+	return FetcherFunc(func(_ context.Context, request *FetchRequest) (*FetchResponse, error) {
+		*requests = append(*requests, request)
+		if etag := header.Get("ETag"); etag != "" && request.Header.Get("If-None-Match") == etag {
+			return &FetchResponse{StatusCode: http.StatusNotModified, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+
+		return &FetchResponse{
+			StatusCode:    http.StatusOK,
+			Header:        header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+		}, nil
+	})
+}
+
+func readCachedBody(t *testing.T, fetcher Fetcher, rawURL string) (*FetchResponse, string) {
+	response, err := fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, rawURL)))
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	return response, string(body)
+}
+
+func TestHTTPCache_Revalidation(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+
+	var requests []*FetchRequest
+	header := http.Header{"Content-Type": {"text/html"}, "Etag": {`"v1"`}}
+	fetcher := cache.Middleware()(cachedSite(&requests, header, "<p>hello</p>"))
+
+	response, body := readCachedBody(t, fetcher, "https://abc.com/a?b=2&a=1")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "<p>hello</p>", body)
+
+	// The same page, written differently.
+	response, body = readCachedBody(t, fetcher, "https://ABC.com:443/a?a=1&b=2#top")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "<p>hello</p>", body)
+	assert.Equal(t, "text/html", response.Header.Get("Content-Type"))
+
+	assert.Len(t, requests, 2)
+	assert.Empty(t, requests[0].Header.Get("If-None-Match"))
+	assert.Equal(t, `"v1"`, requests[1].Header.Get("If-None-Match"))
+	assert.Equal(t, CacheStats{Revalidations: 1, Misses: 1, Stores: 1, BytesSaved: 12}, cache.Stats())
+
+	// A new version is downloaded and stored again.
+	header.Set("ETag", `"v2"`)
+	fetcher = cache.Middleware()(cachedSite(&requests, header, "<p>bye</p>"))
+	_, body = readCachedBody(t, fetcher, "https://abc.com/a?a=1&b=2")
+	assert.Equal(t, "<p>bye</p>", body)
+	assert.Equal(t, int64(2), cache.Stats().Stores)
+}
+
+func TestHTTPCache_Freshness(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	var requests []*FetchRequest
+	header := http.Header{"Cache-Control": {"public, max-age=3600"}, "Last-Modified": {"Sun, 01 Jan 2023 00:00:00 GMT"}}
+	fetcher := cache.Middleware()(cachedSite(&requests, header, "fresh"))
+
+	readCachedBody(t, fetcher, "https://abc.com")
+	now = now.Add(30 * time.Minute)
+	_, body := readCachedBody(t, fetcher, "https://abc.com")
+	assert.Equal(t, "fresh", body)
+	assert.Len(t, requests, 1)
+
+	now = now.Add(time.Hour)
+	readCachedBody(t, fetcher, "https://abc.com")
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "Sun, 01 Jan 2023 00:00:00 GMT", requests[1].Header.Get("If-Modified-Since"))
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.InDelta(t, 1.0/3, stats.HitRate(), 0.001)
+}
+
+func TestHTTPCache_NotStored(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+
+	var requests []*FetchRequest
+	noStore := cache.Middleware()(cachedSite(&requests, http.Header{"Cache-Control": {"no-store"}, "Etag": {`"v1"`}}, "secret"))
+	readCachedBody(t, noStore, "https://abc.com/no-store")
+	readCachedBody(t, noStore, "https://abc.com/no-store")
+
+	noValidators := cache.Middleware()(cachedSite(&requests, http.Header{}, "dynamic"))
+	readCachedBody(t, noValidators, "https://abc.com/dynamic")
+	readCachedBody(t, noValidators, "https://abc.com/dynamic")
+
+	// Requests made conditional by the caller bypass the cache.
+	request := NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com/no-store"))
+	request.Header.Set("If-None-Match", `"v1"`)
+	response, err := noStore.Fetch(context.Background(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+
+	assert.Len(t, requests, 5)
+	assert.Equal(t, CacheStats{Misses: 4}, cache.Stats())
+}
+
+func TestHTTPCache_UnreadBody(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+
+	var requests []*FetchRequest
+	fetcher := cache.Middleware()(cachedSite(&requests, http.Header{"Etag": {`"v1"`}}, "<p>hello</p>"))
+
+	response, err := fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com")))
+	assert.NoError(t, err)
+	_, err = io.ReadFull(response.Body, make([]byte, 4))
+	assert.NoError(t, err)
+	response.Body.Close()
+	readCachedBody(t, fetcher, "https://abc.com")
+
+	assert.Len(t, requests, 2)
+	assert.Empty(t, requests[1].Header.Get("If-None-Match"))
+	assert.Equal(t, int64(1), cache.Stats().Stores)
+}
+
+func TestHTTPCache_RedirectedTo(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+
+	var requests []*FetchRequest
+	site := cachedSite(&requests, http.Header{"Etag": {`"v1"`}}, "<p>hello</p>")
+	fetcher := cache.Middleware()(FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		response, err := site.Fetch(ctx, request)
+		if err == nil {
+			response.RedirectedTo = makeURLFor(t, "https://abc.com/home")
+		}
+		return response, err
+	}))
+
+	readCachedBody(t, fetcher, "https://abc.com")
+	response, _ := readCachedBody(t, fetcher, "https://abc.com")
+
+	assert.Equal(t, int64(1), cache.Stats().Revalidations)
+	assert.Equal(t, "https://abc.com/home", response.RedirectedTo.String())
+}
+
+func TestFreshnessLifetime_Success(t *testing.T) {
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Minute, freshnessLifetime(http.Header{"Cache-Control": {"max-age=60"}}, date))
+	assert.Equal(t, time.Duration(0), freshnessLifetime(http.Header{"Cache-Control": {"no-cache, max-age=60"}}, date))
+	assert.Equal(t, 2*time.Hour, freshnessLifetime(http.Header{"Expires": {"Sun, 01 Jan 2023 02:00:00 GMT"}}, date))
+	assert.Equal(t, time.Hour, freshnessLifetime(http.Header{
+		"Expires": {"Sun, 01 Jan 2023 02:00:00 GMT"},
+		"Date":    {"Sun, 01 Jan 2023 01:00:00 GMT"},
+	}, date))
+	assert.Equal(t, time.Duration(0), freshnessLifetime(http.Header{"Expires": {"0"}}, date))
+	assert.Equal(t, time.Duration(0), freshnessLifetime(http.Header{}, date))
+}
+
+func TestCrawler_GetAllLinksFor_Cache(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+
+	var requests []*FetchRequest
+	header := http.Header{"Content-Type": {"text/html"}, "Etag": {`"v1"`}}
+	site := cachedSite(&requests, header, `<a href="/">home</a>`)
+
+	for i := 0; i < 2; i++ {
+		crawler := New(WithFetcher(site), WithWorkers(1), WithRetryAttempts(1), WithCache(cache))
+		var processed []*LinksByTargetURL
+		crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com/"), func(page *LinksByTargetURL) {
+			processed = append(processed, page)
+		}, func(err error) {
+			assert.NoError(t, err)
+		})
+		assert.Len(t, processed, 1)
+		assert.Equal(t, http.StatusOK, processed[0].StatusCode)
+	}
+
+	assert.Len(t, requests, 2)
+	assert.Equal(t, int64(1), cache.Stats().Revalidations)
+}
+
+func TestCrawler_GetAllLinksFor_CacheSkippedPages(t *testing.T) {
+	cache, err := NewHTTPCache(t.TempDir())
+	assert.NoError(t, err)
+
+	var requests []*FetchRequest
+	image := cachedSite(&requests, http.Header{"Content-Type": {"image/png"}, "Etag": {`"v1"`}}, "PNG")
+	large := cachedSite(&requests, http.Header{"Content-Type": {"text/html"}, "Etag": {`"v1"`}}, strings.Repeat("a", 100))
+
+	for i := 0; i < 2; i++ {
+		crawler := New(WithFetcher(image), WithRetryAttempts(1), WithCache(cache))
+		page, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com/image"))
+		assert.NoError(t, err)
+		assert.True(t, page.Skipped())
+
+		crawler = New(WithFetcher(large), WithRetryAttempts(1), WithCache(cache), WithMaxBodySize(10))
+		page, err = crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com/large"))
+		assert.NoError(t, err)
+		assert.True(t, page.Skipped())
+	}
+
+	assert.Len(t, requests, 4)
+	for _, request := range requests {
+		assert.Empty(t, request.Header.Get("If-None-Match"))
+	}
+	assert.Equal(t, int64(0), cache.Stats().Stores)
+}
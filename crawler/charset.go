@@ -0,0 +1,423 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	charsetUTF8        = "utf-8"
+	charsetUTF16LE     = "utf-16le"
+	charsetUTF16BE     = "utf-16be"
+	charsetWindows1252 = "windows-1252"
+	charsetISO885915   = "iso-8859-15"
+	charsetShiftJIS    = "shift_jis"
+
+	// The HTML spec only prescans the first 1024 bytes for a <meta> charset.
+	charsetPrescanLength = 1024
+)
+
+// Labels from the WHATWG Encoding Standard for the encodings this crawler can
+// decode. ISO-8859-1 and US-ASCII are decoded as windows-1252, as browsers do.
+var charsetLabels = map[string]string{
+	"unicode-1-1-utf-8": charsetUTF8,
+	"unicode11utf8":     charsetUTF8,
+	"unicode20utf8":     charsetUTF8,
+	"utf-8":             charsetUTF8,
+	"utf8":              charsetUTF8,
+	"x-unicode20utf8":   charsetUTF8,
+	"csunicode":         charsetUTF16LE,
+	"iso-10646-ucs-2":   charsetUTF16LE,
+	"ucs-2":             charsetUTF16LE,
+	"unicode":           charsetUTF16LE,
+	"unicodefeff":       charsetUTF16LE,
+	"utf-16":            charsetUTF16LE,
+	"utf-16le":          charsetUTF16LE,
+	"unicodefffe":       charsetUTF16BE,
+	"utf-16be":          charsetUTF16BE,
+	"ansi_x3.4-1968":    charsetWindows1252,
+	"ascii":             charsetWindows1252,
+	"cp1252":            charsetWindows1252,
+	"cp819":             charsetWindows1252,
+	"csisolatin1":       charsetWindows1252,
+	"ibm819":            charsetWindows1252,
+	"iso-8859-1":        charsetWindows1252,
+	"iso-ir-100":        charsetWindows1252,
+	"iso8859-1":         charsetWindows1252,
+	"iso88591":          charsetWindows1252,
+	"iso_8859-1":        charsetWindows1252,
+	"iso_8859-1:1987":   charsetWindows1252,
+	"l1":                charsetWindows1252,
+	"latin1":            charsetWindows1252,
+	"us-ascii":          charsetWindows1252,
+	"windows-1252":      charsetWindows1252,
+	"x-cp1252":          charsetWindows1252,
+	"csisolatin9":       charsetISO885915,
+	"iso-8859-15":       charsetISO885915,
+	"iso8859-15":        charsetISO885915,
+	"iso885915":         charsetISO885915,
+	"iso_8859-15":       charsetISO885915,
+	"l9":                charsetISO885915,
+	"csshiftjis":        charsetShiftJIS,
+	"ms932":             charsetShiftJIS,
+	"ms_kanji":          charsetShiftJIS,
+	"shift-jis":         charsetShiftJIS,
+	"shift_jis":         charsetShiftJIS,
+	"sjis":              charsetShiftJIS,
+	"windows-31j":       charsetShiftJIS,
+	"x-sjis":            charsetShiftJIS,
+}
+
+// Code points for the 0x80-0x9F range of windows-1252, the rest of the bytes
+// map to the code point with the same value.
+var windows1252HighControls = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+var iso885915Replacements = map[byte]rune{
+	0xA4: 0x20AC, 0xA6: 0x0160, 0xA8: 0x0161, 0xB4: 0x017D,
+	0xB8: 0x017E, 0xBC: 0x0152, 0xBD: 0x0153, 0xBE: 0x0178,
+}
+
+func lookupCharset(label string) (string, bool) {
+	charset, ok := charsetLabels[strings.ToLower(strings.Trim(label, " \t\n\f\r\"'"))]
+	return charset, ok
+}
+
+// DetectCharset follows the encoding sniffing algorithm from the HTML spec:
+// the byte order mark wins, then the charset parameter from the Content-Type
+// header and then the <meta> prescan of the body. When nothing is declared the
+// body is treated as UTF-8 if it is valid UTF-8, otherwise as windows-1252.
+// Declarations of charsets that are not supported are ignored.
+func DetectCharset(contentType string, body []byte) string {
+	charset, _ := detectCharset(contentType, body)
+	return charset
+}
+
+// detectCharset is DetectCharset, also returning the labels of the charsets
+// that were declared but are not supported.
+func detectCharset(contentType string, body []byte) (string, []string) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return charsetUTF8, nil
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return charsetUTF16BE, nil
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return charsetUTF16LE, nil
+	}
+
+	var unknownLabels []string
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		if charset, ok := lookupCharset(params["charset"]); ok {
+			return charset, nil
+		}
+		unknownLabels = append(unknownLabels, params["charset"])
+	}
+
+	charset, ok, unknownMetaLabels := prescanForMetaCharset(body)
+	unknownLabels = append(unknownLabels, unknownMetaLabels...)
+	if ok {
+		return charset, unknownLabels
+	}
+
+	if utf8.Valid(body) {
+		return charsetUTF8, unknownLabels
+	}
+
+	return charsetWindows1252, unknownLabels
+}
+
+// DecodeToUTF8 transcodes the body from the given charset to UTF-8. Invalid
+// sequences are replaced by U+FFFD.
+func DecodeToUTF8(body []byte, charset string) ([]byte, error) {
+	switch charset {
+	case charsetUTF8:
+		return bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF}), nil
+	case charsetUTF16LE, charsetUTF16BE:
+		return decodeUTF16(body, charset == charsetUTF16BE), nil
+	case charsetWindows1252:
+		return decodeSingleByte(body, nil), nil
+	case charsetISO885915:
+		return decodeSingleByte(body, iso885915Replacements), nil
+	case charsetShiftJIS:
+		return decodeShiftJIS(body), nil
+	}
+
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+func decodeUTF16(body []byte, bigEndian bool) []byte {
+	if len(body) >= 2 && (bigEndian && body[0] == 0xFE && body[1] == 0xFF || !bigEndian && body[0] == 0xFF && body[1] == 0xFE) {
+		body = body[2:]
+	}
+
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(body[i])<<8|uint16(body[i+1]))
+		} else {
+			units = append(units, uint16(body[i+1])<<8|uint16(body[i]))
+		}
+	}
+
+	decoded := []byte(string(utf16.Decode(units)))
+	if len(body)%2 != 0 {
+		decoded = utf8.AppendRune(decoded, utf8.RuneError)
+	}
+
+	return decoded
+}
+
+func decodeSingleByte(body []byte, replacements map[byte]rune) []byte {
+	decoded := make([]byte, 0, len(body))
+	for _, b := range body {
+		switch r, ok := replacements[b]; {
+		case ok:
+			decoded = utf8.AppendRune(decoded, r)
+		case b < 0x80:
+			decoded = append(decoded, b)
+		case b < 0xA0 && replacements == nil:
+			decoded = utf8.AppendRune(decoded, windows1252HighControls[b-0x80])
+		default:
+			decoded = utf8.AppendRune(decoded, rune(b))
+		}
+	}
+
+	return decoded
+}
+
+// decodeShiftJIS implements the Shift_JIS decoder from the WHATWG Encoding Standard.
+func decodeShiftJIS(body []byte) []byte {
+	decoded := make([]byte, 0, len(body))
+
+	for i := 0; i < len(body); i++ {
+		b := body[i]
+		switch {
+		case b < 0x80:
+			decoded = append(decoded, b)
+		case b == 0x80:
+			decoded = utf8.AppendRune(decoded, 0x80)
+		case b >= 0xA1 && b <= 0xDF:
+			decoded = utf8.AppendRune(decoded, 0xFF61+rune(b-0xA1))
+		case (b >= 0x81 && b <= 0x9F) || (b >= 0xE0 && b <= 0xFC):
+			if i+1 == len(body) {
+				decoded = utf8.AppendRune(decoded, utf8.RuneError)
+				continue
+			}
+
+			trail := body[i+1]
+			codePoint := shiftJISCodePoint(b, trail)
+			if codePoint == 0 {
+				decoded = utf8.AppendRune(decoded, utf8.RuneError)
+				// An ASCII trail byte is not consumed, so it can't hide markup like '<'.
+				if trail >= 0x80 {
+					i++
+				}
+				continue
+			}
+
+			decoded = utf8.AppendRune(decoded, codePoint)
+			i++
+		default:
+			decoded = utf8.AppendRune(decoded, utf8.RuneError)
+		}
+	}
+
+	return decoded
+}
+
+func shiftJISCodePoint(lead, trail byte) rune {
+	if !(trail >= 0x40 && trail <= 0x7E) && !(trail >= 0x80 && trail <= 0xFC) {
+		return 0
+	}
+
+	leadOffset, trailOffset := 0xC1, 0x41
+	if lead < 0xA0 {
+		leadOffset = 0x81
+	}
+	if trail < 0x7F {
+		trailOffset = 0x40
+	}
+
+	pointer := (int(lead)-leadOffset)*188 + int(trail) - trailOffset
+	if pointer >= 8836 && pointer <= 10715 {
+		return 0xE000 + rune(pointer-8836)
+	}
+	if pointer >= len(jis0208) {
+		return 0
+	}
+
+	return rune(jis0208[pointer])
+}
+
+// prescanForMetaCharset is a simplified version of the "prescan a byte stream
+// to determine its encoding" algorithm from the HTML spec. It also returns the
+// labels of the unsupported charsets it skipped.
+func prescanForMetaCharset(body []byte) (string, bool, []string) {
+	if len(body) > charsetPrescanLength {
+		body = body[:charsetPrescanLength]
+	}
+
+	var unknownLabels []string
+	for i := 0; i < len(body); i++ {
+		switch {
+		case bytes.HasPrefix(body[i:], []byte("<!--")):
+			end := bytes.Index(body[i+4:], []byte("-->"))
+			if end < 0 {
+				return "", false, unknownLabels
+			}
+			i += 4 + end + 2
+		case hasMetaTagPrefix(body[i:]):
+			attributes, end := parseTagAttributes(body, i+len("<meta"))
+			if label, ok := charsetFromMetaAttributes(attributes); ok {
+				charset, known := lookupCharset(label)
+				switch {
+				case !known:
+					unknownLabels = append(unknownLabels, label)
+				// A UTF-16 declaration can't be right, as the prescan is only able to read it in ASCII.
+				case charset == charsetUTF16LE || charset == charsetUTF16BE:
+					return charsetUTF8, true, unknownLabels
+				default:
+					return charset, true, unknownLabels
+				}
+			}
+			i = end
+		case body[i] == '<' && i+1 < len(body) && (isASCIILetter(body[i+1]) || body[i+1] == '/'):
+			_, i = parseTagAttributes(body, skipTagName(body, i+1))
+		case body[i] == '<' && i+1 < len(body) && (body[i+1] == '!' || body[i+1] == '?'):
+			end := bytes.IndexByte(body[i:], '>')
+			if end < 0 {
+				return "", false, unknownLabels
+			}
+			i += end
+		}
+	}
+
+	return "", false, unknownLabels
+}
+
+func hasMetaTagPrefix(body []byte) bool {
+	const prefix = "<meta"
+	if len(body) <= len(prefix) || !strings.EqualFold(string(body[:len(prefix)]), prefix) {
+		return false
+	}
+
+	return isHTMLSpace(body[len(prefix)]) || body[len(prefix)] == '/'
+}
+
+// charsetFromMetaAttributes returns the charset label a <meta> tag declares,
+// if any.
+func charsetFromMetaAttributes(attributes map[string]string) (string, bool) {
+	if charset, ok := attributes["charset"]; ok {
+		return charset, charset != ""
+	}
+
+	if !strings.EqualFold(attributes["http-equiv"], "content-type") {
+		return "", false
+	}
+
+	content := strings.ToLower(attributes["content"])
+	index := strings.Index(content, "charset")
+	if index < 0 {
+		return "", false
+	}
+
+	value := strings.TrimLeft(content[index+len("charset"):], " \t\n\f\r")
+	if !strings.HasPrefix(value, "=") {
+		return "", false
+	}
+	value = strings.TrimLeft(value[1:], " \t\n\f\r")
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1], true
+		}
+		return "", false
+	}
+	if end := strings.IndexAny(value, " \t\n\f\r;"); end >= 0 {
+		value = value[:end]
+	}
+
+	return value, value != ""
+}
+
+func skipTagName(body []byte, i int) int {
+	for i < len(body) && !isHTMLSpace(body[i]) && body[i] != '>' {
+		i++
+	}
+
+	return i
+}
+
+// parseTagAttributes reads the attributes of a tag starting at position i and
+// returns them along with the position of the closing '>'. Only the first
+// occurrence of each attribute is kept.
+func parseTagAttributes(body []byte, i int) (map[string]string, int) {
+	attributes := make(map[string]string)
+
+	for i < len(body) {
+		for i < len(body) && (isHTMLSpace(body[i]) || body[i] == '/') {
+			i++
+		}
+		if i >= len(body) || body[i] == '>' {
+			return attributes, i
+		}
+
+		nameStart := i
+		for i < len(body) && !isHTMLSpace(body[i]) && body[i] != '=' && body[i] != '>' && body[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(string(body[nameStart:i]))
+
+		for i < len(body) && isHTMLSpace(body[i]) {
+			i++
+		}
+		if i >= len(body) || body[i] != '=' {
+			if _, ok := attributes[name]; !ok {
+				attributes[name] = ""
+			}
+			continue
+		}
+		i++
+		for i < len(body) && isHTMLSpace(body[i]) {
+			i++
+		}
+
+		var value string
+		if i < len(body) && (body[i] == '"' || body[i] == '\'') {
+			quote := body[i]
+			end := bytes.IndexByte(body[i+1:], quote)
+			if end < 0 {
+				return attributes, len(body)
+			}
+			value = string(body[i+1 : i+1+end])
+			i += end + 2
+		} else {
+			valueStart := i
+			for i < len(body) && !isHTMLSpace(body[i]) && body[i] != '>' {
+				i++
+			}
+			value = string(body[valueStart:i])
+		}
+
+		if _, ok := attributes[name]; !ok {
+			attributes[name] = value
+		}
+	}
+
+	return attributes, i
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetectCharset_ByteOrderMark_Success(t *testing.T) {
+	assert.Equal(t, "utf-8", DetectCharset("text/html; charset=shift_jis", []byte("\xEF\xBB\xBF<html>")))
+	assert.Equal(t, "utf-16be", DetectCharset("", []byte("\xFE\xFF\x00<")))
+	assert.Equal(t, "utf-16le", DetectCharset("", []byte("\xFF\xFE<\x00")))
+}
+
+func TestDetectCharset_ContentTypeHeader_Success(t *testing.T) {
+	body := []byte(`<meta charset="shift_jis">`)
+	assert.Equal(t, "windows-1252", DetectCharset("text/html; charset=ISO-8859-1", body))
+	assert.Equal(t, "shift_jis", DetectCharset("text/html; charset=unknown-label", body))
+}
+
+func TestDetectCharset_MetaPrescan_Success(t *testing.T) {
+	assert.Equal(t, "shift_jis", DetectCharset("text/html", []byte(`<!DOCTYPE html><html><head><META CHARSET=Shift_JIS></head>`)))
+	assert.Equal(t, "windows-1252", DetectCharset("", []byte(`<meta http-equiv="Content-Type" content="text/html; charset='latin1'">`)))
+	assert.Equal(t, "utf-8", DetectCharset("", []byte(`<meta charset="utf-16">`)))
+	assert.Equal(t, "utf-8", DetectCharset("", []byte(`<!-- <meta charset="shift_jis"> --><p>olá</p>`)))
+	assert.Equal(t, "utf-8", DetectCharset("", []byte(`<div title='<meta charset="shift_jis">'></div>`)))
+}
+
+func TestDetectCharset_UnknownLabels(t *testing.T) {
+	charset, unknownLabels := detectCharset("text/html; charset=x-mac-roman", []byte(`<meta charset="koi8-u"><meta charset="latin1">`))
+	assert.Equal(t, "windows-1252", charset)
+	assert.Equal(t, []string{"x-mac-roman", "koi8-u"}, unknownLabels)
+
+	charset, unknownLabels = detectCharset("text/html; charset=utf-8", []byte(`<meta charset="koi8-u">`))
+	assert.Equal(t, "utf-8", charset)
+	assert.Empty(t, unknownLabels)
+}
+
+func TestDetectCharset_Fallback_Success(t *testing.T) {
+	assert.Equal(t, "utf-8", DetectCharset("", []byte("<p>olá</p>")))
+	assert.Equal(t, "windows-1252", DetectCharset("", []byte("<p>ol\xE1</p>")))
+}
+
+func TestDecodeToUTF8_Success(t *testing.T) {
+	testCases := []struct {
+		charset  string
+		body     []byte
+		expected string
+	}{
+		{charset: "utf-8", body: []byte("\xEF\xBB\xBFolá"), expected: "olá"},
+		{charset: "windows-1252", body: []byte("\x93caf\xE9\x94 \x80"), expected: "“café” €"},
+		{charset: "iso-8859-15", body: []byte("caf\xE9 \xA4"), expected: "café €"},
+		{charset: "utf-16le", body: []byte("\xFF\xFEo\x00l\x00\xE1\x00"), expected: "olá"},
+		{charset: "utf-16be", body: []byte("\x00o\x00l\x00\xE1"), expected: "olá"},
+		{charset: "shift_jis", body: []byte("\x82\xA0\x93\xFA\x96{ \xB1 abc"), expected: "あ日本 ｱ abc"},
+		{charset: "shift_jis", body: []byte("\x81<a>"), expected: "�<a>"},
+	}
+
+	for _, testCase := range testCases {
+		decoded, err := DecodeToUTF8(testCase.body, testCase.charset)
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, string(decoded), testCase.charset)
+	}
+}
+
+func TestDecodeToUTF8_UnsupportedCharset_Error(t *testing.T) {
+	_, err := DecodeToUTF8([]byte("abc"), "euc-kr")
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultMaxBodySize is the size above which a response body is not read and
+// its page is skipped.
+const DefaultMaxBodySize int64 = 10 << 20
+
+// DefaultAllowedContentTypes are the media types of the pages links are
+// extracted from, unless WithAllowedContentTypes says otherwise.
+var DefaultAllowedContentTypes = []string{"text/html", "application/xhtml+xml", "text/css"}
+
+// DefaultDeniedExtensions are the extensions of the links that are never
+// followed, unless WithDeniedExtensions says otherwise.
+var DefaultDeniedExtensions = []string{
+	".7z", ".avi", ".bin", ".bmp", ".dmg", ".doc", ".docx", ".exe", ".gif", ".gz", ".ico", ".iso",
+	".jpeg", ".jpg", ".mov", ".mp3", ".mp4", ".mpeg", ".pdf", ".png", ".ppt", ".pptx", ".rar",
+	".svg", ".tar", ".tgz", ".tif", ".tiff", ".wav", ".webm", ".webp", ".woff", ".woff2", ".xls",
+	".xlsx", ".zip",
+}
+
+// Extensions that usually serve HTML documents. Links ending with any other
+// extension are checked with a HEAD request first when headBeforeGet is enabled.
+var htmlLookingExtensions = map[string]bool{
+	"":       true,
+	".htm":   true,
+	".html":  true,
+	".xhtml": true,
+	".php":   true,
+	".asp":   true,
+	".aspx":  true,
+	".jsp":   true,
+	".cgi":   true,
+}
+
+var errBodyTooLarge = errors.New("response body is too large")
+
+func extensionOf(targetURL *url.URL) string {
+	return strings.ToLower(path.Ext(targetURL.Path))
+}
+
+// LooksLikeHTML reports whether the extension of a URL usually serves HTML,
+// e.g. none, .html or .php.
+func LooksLikeHTML(targetURL *url.URL) bool {
+	return htmlLookingExtensions[extensionOf(targetURL)]
+}
+
+// IsHTMLContentType reports whether the media type in the Content-Type header
+// is HTML. Responses without a Content-Type are treated as HTML.
+func IsHTMLContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// IsAllowedContentType reports whether the media type in the Content-Type
+// header is part of the allow-list. Responses without a Content-Type are
+// allowed, as there is nothing to decide on.
+func IsAllowedContentType(contentType string, allowedContentTypes []string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range allowedContentTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadBody reads a whole body, failing when it is larger than maxBodySize. A
+// maxBodySize of zero or less means no limit.
+func ReadBody(body io.Reader, maxBodySize int64) ([]byte, error) {
+	if maxBodySize <= 0 {
+		return io.ReadAll(body)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxBodySize {
+		return nil, fmt.Errorf("%w: more than %d bytes", errBodyTooLarge, maxBodySize)
+	}
+
+	return content, nil
+}
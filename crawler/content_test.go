@@ -0,0 +1,41 @@
+package crawler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLooksLikeHTML_Success(t *testing.T) {
+	assert.True(t, LooksLikeHTML(makeURLFor(t, "https://abc.com")))
+	assert.True(t, LooksLikeHTML(makeURLFor(t, "https://abc.com/path-a/")))
+	assert.True(t, LooksLikeHTML(makeURLFor(t, "https://abc.com/path-a.HTML")))
+	assert.True(t, LooksLikeHTML(makeURLFor(t, "https://abc.com/index.php?page=1")))
+	assert.False(t, LooksLikeHTML(makeURLFor(t, "https://abc.com/movie.mp4")))
+	assert.False(t, LooksLikeHTML(makeURLFor(t, "https://abc.com/report.pdf")))
+}
+
+func TestIsAllowedContentType_Success(t *testing.T) {
+	assert.True(t, IsAllowedContentType("text/html; charset=utf-8", DefaultAllowedContentTypes))
+	assert.True(t, IsAllowedContentType("application/XHTML+xml", DefaultAllowedContentTypes))
+	assert.True(t, IsAllowedContentType("", DefaultAllowedContentTypes))
+	assert.False(t, IsAllowedContentType("video/mp4", DefaultAllowedContentTypes))
+	assert.False(t, IsAllowedContentType("not a media type;;", DefaultAllowedContentTypes))
+}
+
+func TestReadBody_Success(t *testing.T) {
+	body, err := ReadBody(strings.NewReader("abcdef"), 6)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abcdef"), body)
+
+	body, err = ReadBody(strings.NewReader("abcdef"), -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abcdef"), body)
+}
+
+func TestReadBody_TooLarge_Error(t *testing.T) {
+	body, err := ReadBody(strings.NewReader("abcdef"), 5)
+	assert.Nil(t, body)
+	assert.True(t, errors.Is(err, errBodyTooLarge))
+}
@@ -0,0 +1,904 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/avast/retry-go/v4"
+	"log/slog"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Crawler crawls the pages of a site, following the links between them. It is
+// created with New and can run one crawl at a time.
+type Crawler struct {
+	fetcher             Fetcher
+	pageVisited         VisitedSet
+	workerPool          *WorkerPool[struct{}]
+	frontier            *Frontier
+	metrics             *Metrics
+	m                   sync.Mutex
+	retryAttempts       uint
+	maxBodySize         int64
+	allowedContentTypes []string
+	deniedExtensions    map[string]bool
+	headBeforeGet       bool
+	extractors          *ExtractorRegistry
+	feedMonitor         *FeedMonitor
+	trapDetector        *TrapDetector
+	previous            *Manifest
+	logger              *slog.Logger
+	hooks               hooks
+	crawling            bool
+}
+
+// DefaultWorkers and DefaultRetryAttempts are used by New unless WithWorkers
+// and WithRetryAttempts say otherwise.
+const (
+	DefaultWorkers       = 100
+	DefaultRetryAttempts = 3
+)
+
+// options are what an Option can change in a Crawler.
+type options struct {
+	httpClient          *http.Client
+	fetcher             Fetcher
+	numberOfWorkers     int
+	retryAttempts       uint
+	maxBodySize         int64
+	allowedContentTypes []string
+	deniedExtensions    []string
+	headBeforeGet       bool
+	extractors          *ExtractorRegistry
+	feedMonitor         *FeedMonitor
+	trapDetector        *TrapDetector
+	visitedSet          VisitedSet
+	scorer              Scorer
+	maxPages            int
+	adaptiveConcurrency *AdaptiveConcurrency
+	metrics             *Metrics
+	cache               *HTTPCache
+	previous            *Manifest
+	logger              *slog.Logger
+}
+
+// Option configures a Crawler created with New.
+type Option func(*options)
+
+// WithHTTPClient sets the client of the HTTPFetcher pages are fetched with,
+// http.DefaultClient by default. It has no effect along with WithFetcher.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) { o.httpClient = httpClient }
+}
+
+// WithFetcher sets how pages are fetched, instead of an HTTPFetcher.
+func WithFetcher(fetcher Fetcher) Option {
+	return func(o *options) { o.fetcher = fetcher }
+}
+
+// WithWorkers sets the number of pages crawled at once, DefaultWorkers by
+// default. With WithAdaptiveConcurrency, it is the maximum.
+func WithWorkers(numberOfWorkers int) Option {
+	return func(o *options) { o.numberOfWorkers = numberOfWorkers }
+}
+
+// WithRetryAttempts sets how many times a request is attempted before giving
+// up on a page, DefaultRetryAttempts by default. Zero means retry until success.
+func WithRetryAttempts(retryAttempts uint) Option {
+	return func(o *options) { o.retryAttempts = retryAttempts }
+}
+
+// WithMaxBodySize sets the size above which pages are skipped rather than
+// downloaded, DefaultMaxBodySize by default. Zero also means
+// DefaultMaxBodySize; a negative size means no limit.
+func WithMaxBodySize(maxBodySize int64) Option {
+	return func(o *options) { o.maxBodySize = maxBodySize }
+}
+
+// WithAllowedContentTypes sets the content types links are extracted from,
+// DefaultAllowedContentTypes by default. Pages of other types are skipped.
+func WithAllowedContentTypes(contentTypes ...string) Option {
+	return func(o *options) { o.allowedContentTypes = append([]string{}, contentTypes...) }
+}
+
+// WithDeniedExtensions sets the file extensions that are never fetched,
+// DefaultDeniedExtensions by default.
+func WithDeniedExtensions(extensions ...string) Option {
+	return func(o *options) { o.deniedExtensions = append([]string{}, extensions...) }
+}
+
+// WithHeadBeforeGet sends a HEAD request before fetching the URLs that don't
+// look like HTML, so that the pages that would be skipped are not downloaded.
+func WithHeadBeforeGet(headBeforeGet bool) Option {
+	return func(o *options) { o.headBeforeGet = headBeforeGet }
+}
+
+// WithExtractors sets the extractors links are extracted by, picked by the
+// content type of each page. NewDefaultExtractorRegistry is used by default.
+func WithExtractors(extractors *ExtractorRegistry) Option {
+	return func(o *options) { o.extractors = extractors }
+}
+
+// WithFeedMonitor follows the RSS/Atom feeds advertised by pages and keeps
+// track of their health in feedMonitor. Feeds are not followed otherwise.
+func WithFeedMonitor(feedMonitor *FeedMonitor) Option {
+	return func(o *options) { o.feedMonitor = feedMonitor }
+}
+
+// WithTrapDetector gives trapDetector the final say on every link, links to
+// pages already seen included so that it sees their query strings.
+func WithTrapDetector(trapDetector *TrapDetector) Option {
+	return func(o *options) { o.trapDetector = trapDetector }
+}
+
+// WithVisitedSet sets where visited pages are kept, a MapVisitedSet by
+// default.
+func WithVisitedSet(visitedSet VisitedSet) Option {
+	return func(o *options) { o.visitedSet = visitedSet }
+}
+
+// WithScorer sets the order in which pages are crawled, BFSScorer by default.
+func WithScorer(scorer Scorer) Option {
+	return func(o *options) { o.scorer = scorer }
+}
+
+// WithMaxPages stops the crawl after maxPages pages. Zero means no limit.
+func WithMaxPages(maxPages int) Option {
+	return func(o *options) { o.maxPages = maxPages }
+}
+
+// WithAdaptiveConcurrency sends requests through the middleware of
+// adaptiveConcurrency and makes the number of workers follow the limits it
+// finds, up to the one set by WithWorkers.
+func WithAdaptiveConcurrency(adaptiveConcurrency *AdaptiveConcurrency) Option {
+	return func(o *options) { o.adaptiveConcurrency = adaptiveConcurrency }
+}
+
+// WithMetrics keeps metrics up to date during the crawl. Metrics can only be
+// given to one Crawler.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) { o.metrics = metrics }
+}
+
+// WithCache serves pages from cache when they did not change since they were
+// stored. Only the pages the Crawler read whole are stored, the ones skipped
+// for their content type or size never are. Cached pages are neither counted
+// by the metrics nor by the adaptive concurrency, as they do not reach the
+// server.
+func WithCache(cache *HTTPCache) Option {
+	return func(o *options) { o.cache = cache }
+}
+
+// WithPreviousManifest recrawls incrementally from the manifest of an earlier
+// crawl: the pages most likely to have changed are crawled first, requests are
+// made conditional on the validators stored in the manifest and the pages that
+// did not change get their links from it instead of being parsed again.
+func WithPreviousManifest(previous *Manifest) Option {
+	return func(o *options) { o.previous = previous }
+}
+
+// WithLogger logs every request to logger at the debug level (warn when it
+// fails), along with the page and the worker. Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// New creates a Crawler, using the defaults for everything opts don't set.
+func New(opts ...Option) *Crawler {
+	params := &options{numberOfWorkers: DefaultWorkers, retryAttempts: DefaultRetryAttempts}
+	for _, opt := range opts {
+		opt(params)
+	}
+
+	fetcher := params.fetcher
+	if fetcher == nil {
+		httpClient := params.httpClient
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		fetcher = NewHTTPFetcher(httpClient)
+	}
+
+	maxBodySize := params.maxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	allowedContentTypes := params.allowedContentTypes
+	if allowedContentTypes == nil {
+		allowedContentTypes = DefaultAllowedContentTypes
+	}
+	if params.feedMonitor != nil {
+		allowedContentTypes = append(append([]string(nil), allowedContentTypes...), feedContentTypes...)
+	}
+
+	deniedExtensions := params.deniedExtensions
+	if deniedExtensions == nil {
+		deniedExtensions = DefaultDeniedExtensions
+	}
+
+	extractors := params.extractors
+	if extractors == nil {
+		extractors = NewDefaultExtractorRegistry()
+	}
+
+	visitedSet := params.visitedSet
+	if visitedSet == nil {
+		visitedSet = NewMapVisitedSet()
+	}
+
+	logger := params.logger
+	if logger == nil {
+		logger = discardLogger()
+	}
+
+	if params.metrics != nil {
+		fetcher = params.metrics.Middleware()(fetcher)
+	}
+
+	workerPool := NewWorkerPool[struct{}](params.numberOfWorkers)
+	workerPool.SetLogger(logger)
+	if params.adaptiveConcurrency != nil {
+		fetcher = params.adaptiveConcurrency.Middleware()(fetcher)
+		params.adaptiveConcurrency.OnResize(func(totalConcurrency int) {
+			workerPool.Resize(min(totalConcurrency, params.numberOfWorkers))
+		})
+	}
+	if params.cache != nil {
+		fetcher = params.cache.Middleware()(fetcher)
+	}
+
+	scorer := params.scorer
+	if params.previous != nil {
+		if scorer == nil {
+			scorer = BFSScorer
+		}
+		scorer = CombineScorers(scorer, ChangeLikelihoodScorer(params.previous))
+	}
+
+	crawler := &Crawler{
+		fetcher:             fetcher,
+		pageVisited:         visitedSet,
+		workerPool:          workerPool,
+		frontier:            NewFrontier(scorer, params.maxPages),
+		metrics:             params.metrics,
+		retryAttempts:       params.retryAttempts,
+		maxBodySize:         maxBodySize,
+		allowedContentTypes: allowedContentTypes,
+		deniedExtensions:    makeExtensionSet(deniedExtensions),
+		headBeforeGet:       params.headBeforeGet,
+		extractors:          extractors,
+		feedMonitor:         params.feedMonitor,
+		trapDetector:        params.trapDetector,
+		previous:            params.previous,
+		logger:              logger,
+	}
+
+	if params.metrics != nil {
+		params.metrics.queued = crawler.frontier.Len
+		registry := params.metrics.registry
+		registry.NewGaugeFunc("crawler_queue_depth", "Pages waiting to be crawled.", func() float64 {
+			return float64(params.metrics.Queued())
+		})
+		registry.NewGaugeFunc("crawler_workers", "Size of the worker pool.", func() float64 {
+			return float64(workerPool.Size())
+		})
+		registry.NewGaugeFunc("crawler_active_workers", "Workers processing a page.", func() float64 {
+			return float64(workerPool.Active())
+		})
+		if params.adaptiveConcurrency != nil {
+			registry.NewGaugeVecFunc("crawler_host_concurrency_limit", "Concurrent requests allowed per host.", "host", func() map[string]float64 {
+				limits := make(map[string]float64)
+				for _, hostConcurrency := range params.adaptiveConcurrency.Stats() {
+					limits[hostConcurrency.Host] = float64(hostConcurrency.Limit)
+				}
+				return limits
+			})
+		}
+	}
+
+	return crawler
+}
+
+func makeExtensionSet(extensions []string) map[string]bool {
+	extensionSet := make(map[string]bool, len(extensions))
+	for _, extension := range extensions {
+		extension = strings.ToLower(extension)
+		if extension != "" && extension[0] != '.' {
+			extension = "." + extension
+		}
+		extensionSet[extension] = true
+	}
+
+	return extensionSet
+}
+
+// Result is a page of a crawl: its links, or the reason it could not be
+// crawled in Err. A Result without a TargetURL is the error of the crawl
+// itself, e.g. a panic in a worker.
+type Result struct {
+	TargetURL *url.URL
+	Page      *LinksByTargetURL
+	Err       error
+}
+
+var (
+	errNoSeeds         = errors.New("no seeds to crawl")
+	errAlreadyCrawling = errors.New("the crawler is already crawling")
+)
+
+// Crawl crawls every page reachable from the seeds on their hosts, sending
+// the result of every page on the returned channel, which is closed once there
+// are no pages left or ctx is done. Pages visited by an earlier crawl of the
+// Crawler are not crawled again, but the pages it left in the frontier are
+// dropped and the page budget starts over. The channel is unbuffered: workers
+// wait for their result to be received before crawling another page, so a
+// caller that stops receiving must cancel ctx.
+func (c *Crawler) Crawl(ctx context.Context, seeds ...*url.URL) (<-chan Result, error) {
+	if len(seeds) == 0 {
+		return nil, errNoSeeds
+	}
+
+	c.m.Lock()
+	if c.crawling {
+		c.m.Unlock()
+		return nil, errAlreadyCrawling
+	}
+	c.crawling = true
+	c.m.Unlock()
+
+	c.frontier.Reset()
+	for _, seed := range seeds {
+		task := &Task{TargetURL: seed}
+		if c.hooks.runBeforeEnqueue(task) && c.MarkPageAsVisited(task.TargetURL) {
+			c.enqueue(task)
+		}
+	}
+
+	results := make(chan Result)
+	go func() {
+		defer close(results)
+
+		err := c.workerPool.ProcessTasks(ctx, func(ctx context.Context, _ struct{}) error {
+			return c.crawlNext(ctx, results)
+		})
+
+		c.m.Lock()
+		c.crawling = false
+		c.m.Unlock()
+		c.hooks.runOnDone(err)
+
+		// When ctx is done, the caller may have stopped receiving.
+		if err != nil && ctx.Err() == nil {
+			results <- Result{Err: err}
+		}
+	}()
+
+	return results, nil
+}
+
+// crawlNext crawls the next page of the frontier, sends its result and adds
+// its links to the frontier.
+func (c *Crawler) crawlNext(ctx context.Context, results chan<- Result) error {
+	task, ok := c.frontier.Pop()
+	if !ok {
+		return nil
+	}
+
+	logger := c.logger.With("url", task.TargetURL.String(), "depth", task.Depth)
+	if workerID, ok := WorkerIDFrom(ctx); ok {
+		logger = logger.With("worker_id", workerID)
+	}
+	ctx = ContextWithLogger(ctx, logger)
+
+	linksForTargetURL, err := c.GetLinksForTargetURL(ctx, task.TargetURL)
+	if err == nil {
+		linksForTargetURL.Depth = task.Depth
+		linksForTargetURL.Metadata = make(map[string]interface{}, len(task.Metadata))
+		maps.Copy(linksForTargetURL.Metadata, task.Metadata)
+		err = c.afterExtract(ctx, linksForTargetURL)
+	}
+	if err != nil {
+		if c.metrics != nil {
+			c.metrics.pageErrors.Inc()
+		}
+		c.hooks.runOnError(task.TargetURL, err)
+		sendResult(ctx, results, Result{TargetURL: task.TargetURL, Err: err})
+		return nil
+	}
+	if c.metrics != nil {
+		c.metrics.pagesFetched.Inc()
+		if linksForTargetURL.Skipped() {
+			c.metrics.pagesSkipped.Inc()
+		}
+	}
+	if c.feedMonitor != nil {
+		c.feedMonitor.ObservePage(linksForTargetURL)
+	}
+	if linksForTargetURL.Skipped() {
+		c.hooks.runOnSkip(linksForTargetURL)
+	}
+	if !sendResult(ctx, results, Result{TargetURL: task.TargetURL, Page: linksForTargetURL}) {
+		return nil
+	}
+
+	for _, link := range linksForTargetURL.OutLinks {
+		linkTask := &Task{TargetURL: link.URL, Depth: task.Depth + 1, InLinks: 1, SitemapPriority: link.Priority}
+		if !c.hooks.runBeforeEnqueue(linkTask) {
+			continue
+		}
+		newPage := c.MarkPageAsVisited(linkTask.TargetURL)
+		if c.trapDetector != nil && !c.trapDetector.Allow(linkTask.TargetURL, newPage) {
+			continue
+		}
+		if !newPage {
+			c.frontier.AddInLink(linkTask.TargetURL)
+			continue
+		}
+		c.enqueue(linkTask)
+	}
+
+	return nil
+}
+
+// afterExtract runs the AfterExtract hooks on a page, skipping it when one of
+// them returns ErrSkip.
+func (c *Crawler) afterExtract(ctx context.Context, page *LinksByTargetURL) error {
+	err := c.hooks.runAfterExtract(ctx, page)
+	if errors.Is(err, ErrSkip) {
+		skipPage(page, err)
+		return nil
+	}
+	if err != nil {
+		return &Error{TargetURL: page.TargetURL, Kind: ErrorKindHook, Err: err}
+	}
+
+	return nil
+}
+
+// sendResult returns false when ctx is done before result is received.
+func sendResult(ctx context.Context, results chan<- Result, result Result) bool {
+	select {
+	case results <- result:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// GetAllLinksFor crawls every page reachable from targetURL on the same host,
+// calling onTargetURLProcessed with the links of every page and onError for
+// the pages that failed. They are called one at a time, from the calling
+// goroutine, and a panic in either of them is given to onError as a
+// PanicError. It returns once there are no pages left or ctx is done, in which
+// case onError gets ctx.Err().
+func (c *Crawler) GetAllLinksFor(
+	ctx context.Context,
+	targetURL *url.URL,
+	onTargetURLProcessed func(*LinksByTargetURL),
+	onError func(error),
+) {
+	iterator, err := c.Iterate(ctx, targetURL)
+	if err != nil {
+		onError(err)
+		return
+	}
+	defer iterator.Close()
+
+	for iterator.Next() {
+		result := iterator.Result()
+		if result.Err != nil {
+			callRecovering(func() { onError(result.Err) }, onError)
+			continue
+		}
+		callRecovering(func() { onTargetURLProcessed(result.Page) }, onError)
+	}
+	if err := iterator.Err(); err != nil {
+		onError(err)
+	}
+}
+
+// callRecovering calls f, giving onError a PanicError when it panics.
+func callRecovering(f func(), onError func(error)) {
+	defer func() {
+		if value := recover(); value != nil {
+			onError(&PanicError{Value: value, Stack: debug.Stack()})
+		}
+	}()
+
+	f()
+}
+
+// enqueue adds a page to the frontier. The worker pool only decides how many
+// pages are crawled at once, the frontier decides which one comes next: every
+// task of the pool stands for one page of the frontier.
+func (c *Crawler) enqueue(task *Task) {
+	c.frontier.Push(task)
+	c.workerPool.AddTask(struct{}{})
+}
+
+// Task is a page waiting to be crawled. Depth is the number of links followed
+// from the target URL of the crawl to reach it, InLinks the number of links to
+// it found so far and SitemapPriority its priority in the sitemap that listed
+// it, if any. Metadata, set by EnqueueHooks, is given to the page once crawled.
+type Task struct {
+	TargetURL       *url.URL
+	Depth           int
+	InLinks         int
+	SitemapPriority float64
+	Metadata        map[string]interface{}
+}
+
+// LinksByTargetURL is what the crawler found out about a page: its links and
+// what the response said of it.
+type LinksByTargetURL struct {
+	// Links are the navigational links of the page and Resources what it uses
+	// (e.g. the stylesheets and images referenced by CSS). Both are crawled.
+	Links     []*url.URL
+	Resources []*url.URL
+	// OutLinks has every link kept from the page along with what was
+	// extracted with it (anchor text, rel, etc.).
+	OutLinks  []*Link
+	TargetURL *url.URL
+	Depth     int
+	// StatusCode is the status of the last response and Duration how long the
+	// server took to send it.
+	StatusCode int
+	Duration   time.Duration
+	// ContentType and ContentLength describe the body, even when it was not
+	// parsed. A ContentLength of -1 means the size is unknown.
+	ContentType   string
+	ContentLength int64
+	// Charset is the encoding the page was decoded from before extracting its
+	// links.
+	Charset string
+	// SkipReason explains why the page was not parsed, e.g. it is not HTML or
+	// it is too large.
+	SkipReason string
+	// Timings breaks down every attempt at every request made for the page, in
+	// order.
+	Timings []*RequestTiming
+	// Metadata holds what hooks attached to the page.
+	Metadata map[string]interface{}
+	// ETag and LastModified are the validators the server sent for the page,
+	// and ContentHash the SHA-256 of its body.
+	ETag         string
+	LastModified string
+	ContentHash  string
+	// NotModified tells that the server answered a conditional request with a
+	// 304, the links being those of the previous crawl (see
+	// WithPreviousManifest).
+	NotModified bool
+	// RedirectedTo is where the page redirected to, if it did.
+	RedirectedTo *url.URL
+	// Title and Canonical come from the head of HTML pages.
+	Title     string
+	Canonical *url.URL
+	// Err is the error of a page that was kept anyway: a 429 or 5XX status
+	// still there after the last attempt (ErrorKindHTTPStatus), the page
+	// keeping its status code and links, or a body over the maximum size
+	// (ErrorKindBodyTooLarge), the page being skipped.
+	Err *Error
+	// Feed is the parsed feed when the page is one the FeedMonitor watches.
+	Feed *Feed
+}
+
+// Skipped reports whether the page was fetched but its links were not
+// extracted, SkipReason telling why.
+func (l *LinksByTargetURL) Skipped() bool {
+	return l.SkipReason != ""
+}
+
+// GetLinksForTargetURL fetches a single page and extracts its links.
+func (c *Crawler) GetLinksForTargetURL(ctx context.Context, targetURL *url.URL) (*LinksByTargetURL, error) {
+	var traces []*requestTrace
+	linksForTargetURL, err := c.getLinksForTargetURL(ctx, targetURL, &traces)
+	if errors.Is(err, ErrSkip) {
+		linksForTargetURL, err = &LinksByTargetURL{TargetURL: targetURL, ContentLength: -1, SkipReason: err.Error()}, nil
+	}
+	if linksForTargetURL != nil {
+		linksForTargetURL.Timings = timingsOf(traces)
+		if isRetryableStatus(linksForTargetURL.StatusCode) {
+			linksForTargetURL.Err = &Error{
+				TargetURL:  targetURL,
+				Kind:       ErrorKindHTTPStatus,
+				StatusCode: linksForTargetURL.StatusCode,
+				Retryable:  true,
+				Err:        &statusError{statusCode: linksForTargetURL.StatusCode},
+			}
+		}
+		if linksForTargetURL.Err != nil {
+			linksForTargetURL.Err.Attempts = len(traces)
+		}
+	}
+	var crawlerErr *Error
+	if errors.As(err, &crawlerErr) {
+		crawlerErr.Attempts = len(traces)
+	}
+
+	return linksForTargetURL, err
+}
+
+func (c *Crawler) getLinksForTargetURL(ctx context.Context, targetURL *url.URL, traces *[]*requestTrace) (*LinksByTargetURL, error) {
+	if extension := extensionOf(targetURL); c.deniedExtensions[extension] {
+		return &LinksByTargetURL{
+			TargetURL:     targetURL,
+			ContentType:   mime.TypeByExtension(extension),
+			ContentLength: -1,
+			SkipReason:    fmt.Sprintf("extension %s is denied", extension),
+		}, nil
+	}
+
+	if c.headBeforeGet && !LooksLikeHTML(targetURL) {
+		response, err := c.doRequest(ctx, http.MethodHead, targetURL, traces)
+		if err != nil {
+			return nil, err
+		}
+		response.Body.Close()
+
+		// Servers that don't support HEAD properly are given the benefit of the doubt.
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			if skipped := c.skipResponse(targetURL, response); skipped != nil {
+				return skipped, nil
+			}
+		}
+	}
+
+	response, err := c.doRequest(ctx, http.MethodGet, targetURL, traces)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified && c.previous != nil {
+		if entry, ok := c.previous.Lookup(targetURL); ok {
+			page := entry.page(targetURL, response)
+			if page.Feed != nil && c.feedMonitor != nil {
+				// An unchanged feed is still reported, from what the manifest kept of it.
+				c.feedMonitor.observeFeed(targetURL, page.Feed, nil)
+			}
+			return page, nil
+		}
+	}
+
+	// I decided to not check if the Status Code from the response is in the range of
+	// 2XX as some pages return links even when the response is not success (e.g. https://monzo.com/non-existent-page/)
+
+	if skipped := c.skipResponse(targetURL, response); skipped != nil {
+		return skipped, nil
+	}
+
+	body, err := ReadBody(response.Body, c.maxBodySize)
+	(*traces)[len(*traces)-1].downloaded()
+	if errors.Is(err, errBodyTooLarge) {
+		return &LinksByTargetURL{
+			TargetURL:     targetURL,
+			StatusCode:    response.StatusCode,
+			Duration:      response.Duration,
+			ContentType:   response.Header.Get("Content-Type"),
+			ContentLength: response.ContentLength,
+			SkipReason:    err.Error(),
+			Err:           &Error{TargetURL: targetURL, Kind: ErrorKindBodyTooLarge, Err: err},
+		}, nil
+	}
+	if err != nil {
+		return nil, newError(targetURL, fmt.Errorf("failed to read the response body: %w", err))
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	charset, unknownLabels := detectCharset(contentType, body)
+	for _, label := range unknownLabels {
+		LoggerFrom(ctx, c.logger.With("url", targetURL.String())).Warn("unknown charset", "label", label, "charset", charset)
+	}
+	decodedBody, err := DecodeToUTF8(body, charset)
+	if err != nil {
+		return nil, &Error{
+			Err:       fmt.Errorf("failed to decode the response body: %w", err),
+			TargetURL: targetURL,
+			Kind:      ErrorKindDecode,
+		}
+	}
+
+	contentHash := sha256.Sum256(body)
+	linksForTargetURL := &LinksByTargetURL{
+		TargetURL:     targetURL,
+		StatusCode:    response.StatusCode,
+		Duration:      response.Duration,
+		ContentType:   contentType,
+		ContentLength: int64(len(body)),
+		Charset:       charset,
+		ETag:          response.Header.Get("ETag"),
+		LastModified:  response.Header.Get("Last-Modified"),
+		ContentHash:   hex.EncodeToString(contentHash[:]),
+		RedirectedTo:  response.RedirectedTo,
+	}
+	if IsHTMLContentType(contentType) {
+		title, canonical := ExtractPageInfo(bytes.NewReader(decodedBody))
+		linksForTargetURL.Title = title
+		if canonical != nil {
+			linksForTargetURL.Canonical = targetURL.ResolveReference(canonical)
+		}
+	}
+
+	var links []*Link
+	if c.feedMonitor.isFeed(targetURL, contentType) {
+		// Feeds are parsed once, for both their health and their links.
+		var feed *Feed
+		feed, err = ParseFeed(bytes.NewReader(decodedBody))
+		c.feedMonitor.observeFeed(targetURL, feed, err)
+		if err == nil {
+			linksForTargetURL.Feed = feed
+			links = feed.links()
+		}
+	} else {
+		extractor, ok := c.extractorFor(contentType)
+		if !ok {
+			linksForTargetURL.SkipReason = fmt.Sprintf("no extractor for content type %q", contentType)
+			return linksForTargetURL, nil
+		}
+		links, err = extractor.Extract(bytes.NewReader(decodedBody))
+	}
+	if err != nil {
+		return nil, &Error{
+			Err:       fmt.Errorf("failed to extract links: %w", err),
+			TargetURL: targetURL,
+			Kind:      ErrorKindExtract,
+		}
+	}
+	for _, link := range FilterLinksBySubdomain(targetURL, links) {
+		if IsFeedContentType(link.MediaType) {
+			if c.feedMonitor == nil {
+				continue
+			}
+			c.feedMonitor.advertiseFeed(link.URL)
+		}
+
+		linksForTargetURL.OutLinks = append(linksForTargetURL.OutLinks, link)
+		if link.Resource {
+			linksForTargetURL.Resources = append(linksForTargetURL.Resources, link.URL)
+		} else {
+			linksForTargetURL.Links = append(linksForTargetURL.Links, link.URL)
+		}
+	}
+
+	return linksForTargetURL, nil
+}
+
+// extractorFor looks up the Extractor for a content type. Pages served without
+// a Content-Type are treated as HTML.
+func (c *Crawler) extractorFor(contentType string) (Extractor, bool) {
+	if contentType == "" {
+		return HTMLExtractor{}, true
+	}
+
+	return c.extractors.Lookup(contentType)
+}
+
+// doRequest fetches targetURL, retrying on retryable errors and, for GETs, on
+// server errors and rate limiting. The response to the last attempt is
+// returned whatever its status, so that the page is reported with it. GETs are
+// conditional on the validators of the previous manifest, if any. Every
+// attempt is logged to the logger of ctx, when there is one, and traced: its
+// requestTrace is added to traces.
+func (c *Crawler) doRequest(ctx context.Context, method string, targetURL *url.URL, traces *[]*requestTrace) (*FetchResponse, error) {
+	request := NewFetchRequest(method, targetURL)
+	logger := LoggerFrom(ctx, c.logger.With("url", targetURL.String()))
+	if method == http.MethodGet && c.previous != nil {
+		c.previous.setConditionalHeaders(request)
+	}
+	if err := c.hooks.runBeforeFetch(ctx, request); err != nil {
+		return nil, hookError(targetURL, err)
+	}
+
+	var response *FetchResponse
+	var attempt int
+	err := retry.Do(func() error {
+		attempt++
+		trace := newRequestTrace(method, attempt)
+		*traces = append(*traces, trace)
+
+		var err error
+		response, err = c.fetcher.Fetch(trace.context(ctx), request)
+		if err != nil {
+			trace.failed(err)
+			logger.Warn("fetch failed", "method", method, "attempt", attempt, "error", err, "duration", time.Since(trace.start))
+			return err
+		}
+
+		trace.responded(response)
+		logger.Debug("fetch", "method", method, "attempt", attempt, "status", response.StatusCode, "duration", trace.timing.TimeToFirstByte)
+		lastAttempt := c.retryAttempts != 0 && uint(attempt) >= c.retryAttempts
+		if method == http.MethodGet && isRetryableStatus(response.StatusCode) && !lastAttempt {
+			response.Body.Close()
+			return &statusError{statusCode: response.StatusCode}
+		}
+		return nil
+	}, retry.Context(ctx), retry.Attempts(c.retryAttempts), retry.LastErrorOnly(true), retry.RetryIf(func(err error) bool {
+		_, retryable := classifyError(err)
+		return retryable
+	}), retry.OnRetry(func(uint, error) {
+		if c.metrics != nil {
+			c.metrics.retries.Inc()
+		}
+	}))
+	if err != nil {
+		return nil, newError(targetURL, fmt.Errorf("failed to make the request: %w", err))
+	}
+
+	if err := c.hooks.runAfterFetch(ctx, request, response); err != nil {
+		response.Body.Close()
+		return nil, hookError(targetURL, err)
+	}
+
+	return response, nil
+}
+
+// hookError is what doRequest returns when a hook stops a request: ErrSkip is
+// left as is, so that the page is skipped.
+func hookError(targetURL *url.URL, err error) error {
+	if errors.Is(err, ErrSkip) {
+		return err
+	}
+
+	return &Error{TargetURL: targetURL, Kind: ErrorKindHook, Err: err}
+}
+
+// skipResponse checks the response headers against the content-type allow-list
+// and the maximum body size, so that unwanted bodies are never downloaded.
+func (c *Crawler) skipResponse(targetURL *url.URL, response *FetchResponse) *LinksByTargetURL {
+	contentType := response.Header.Get("Content-Type")
+
+	skipped := &LinksByTargetURL{
+		TargetURL:     targetURL,
+		StatusCode:    response.StatusCode,
+		Duration:      response.Duration,
+		ContentType:   contentType,
+		ContentLength: response.ContentLength,
+	}
+	if !IsAllowedContentType(contentType, c.allowedContentTypes) && !c.feedMonitor.isFeed(targetURL, contentType) {
+		skipped.SkipReason = fmt.Sprintf("content type %q is not allowed", contentType)
+	} else if c.maxBodySize > 0 && response.ContentLength > c.maxBodySize {
+		err := fmt.Errorf("%w: %d bytes", errBodyTooLarge, response.ContentLength)
+		skipped.SkipReason = err.Error()
+		skipped.Err = &Error{TargetURL: targetURL, Kind: ErrorKindBodyTooLarge, Err: err}
+	}
+
+	if !skipped.Skipped() {
+		return nil
+	}
+
+	return skipped
+}
+
+// MarkPageAsVisited returns false when the page was already visited.
+func (c *Crawler) MarkPageAsVisited(targetURL *url.URL) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.pageVisited.Add(PageKey(targetURL))
+}
+
+// PageKey identifies a page regardless of its scheme, query and fragment, as
+// the crawler tells pages apart.
+func PageKey(targetURL *url.URL) string {
+	return targetURL.Host + targetURL.Path
+}
+
+// PageURL returns the URL of the page a URL points at: the URL without its
+// query and fragment, which the crawler does not tell pages apart by.
+func PageURL(targetURL *url.URL) string {
+	page := *targetURL
+	page.RawQuery, page.ForceQuery = "", false
+	page.Fragment, page.RawFragment = "", ""
+
+	return page.String()
+}
@@ -0,0 +1,376 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawler_GetAllLinksFor_Success(t *testing.T) {
+	var m sync.Mutex
+	var linkA *url.URL
+	var linkB *url.URL
+	var linkC *url.URL
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			m.Lock()
+			defer m.Unlock()
+			linkA = makeURLFor(t, fmt.Sprintf("http://%s/abc", r.Host))
+			linkB = makeURLFor(t, fmt.Sprintf("http://%s/bca", r.Host))
+			linkC = makeURLFor(t, "/bca/abce/indiana/jones")
+			htmlContent := fmt.Sprintf(`
+			<a href="%s"/>
+			<!DOCTYPE html>
+			<html>
+					<div>
+						<div><a href="%s"/></div>
+					</div>
+				<body>
+					<a href="https://community.monzo.com/cab"/>
+				</body>
+				<a href="%s"/>
+			</html>
+		`, linkA, linkB, linkC)
+
+			w.WriteHeader(http.StatusOK)
+			_, err := w.Write([]byte(htmlContent))
+			assert.NoError(t, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var linksForTargetURLs []*LinksByTargetURL
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		linksForTargetURLs = append(linksForTargetURLs, linksForTargetURL)
+	}
+
+	var errs []error
+	onError := func(err error) {
+		errs = append(errs, err)
+	}
+
+	crawler := New(WithHTTPClient(http.DefaultClient), WithWorkers(100), WithRetryAttempts(1))
+	targetURL := makeURLFor(t, server.URL)
+	crawler.GetAllLinksFor(context.Background(), targetURL, onTargetURLProcessed, onError)
+
+	assert.Empty(t, errs)
+	assert.Len(t, linksForTargetURLs, 4)
+	for _, linksForTargetURL := range linksForTargetURLs {
+		if linksForTargetURL.TargetURL == targetURL {
+			assert.Contains(t, linksForTargetURL.Links, linkA)
+			assert.Contains(t, linksForTargetURL.Links, linkB)
+			assert.Contains(t, linksForTargetURL.Links, targetURL.ResolveReference(linkC))
+		} else {
+			assert.Empty(t, linksForTargetURL.Links)
+		}
+	}
+}
+
+func TestCrawler_GetAllLinksFor_TwoPagesSuccess(t *testing.T) {
+	var m sync.Mutex
+	var linkA *url.URL
+	var linkB *url.URL
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+
+		var htmlContent string
+		if r.URL.Path == "/" {
+			linkA = makeURLFor(t, fmt.Sprintf("http://%s/abc", r.Host))
+			htmlContent = fmt.Sprintf(`<a href="%s"/>`, linkA)
+		} else if r.URL.Path == "/abc" {
+			linkB = makeURLFor(t, fmt.Sprintf("http://%s/cba", r.Host))
+			htmlContent = fmt.Sprintf(`<a href="%s"/>`, linkB)
+		} else {
+			htmlContent = ""
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(htmlContent))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	var linksForTargetURLs []*LinksByTargetURL
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		linksForTargetURLs = append(linksForTargetURLs, linksForTargetURL)
+	}
+
+	var errs []error
+	onError := func(err error) {
+		errs = append(errs, err)
+	}
+
+	crawler := New(WithHTTPClient(http.DefaultClient), WithWorkers(100), WithRetryAttempts(1))
+	targetURL := makeURLFor(t, server.URL)
+	crawler.GetAllLinksFor(context.Background(), targetURL, onTargetURLProcessed, onError)
+
+	assert.Empty(t, errs)
+	assert.Len(t, linksForTargetURLs, 3)
+	for _, linksForTargetURL := range linksForTargetURLs {
+		if linksForTargetURL.TargetURL.String() == targetURL.String() {
+			assert.Contains(t, linksForTargetURL.Links, linkA)
+		} else if linksForTargetURL.TargetURL.String() == linkA.String() {
+			assert.Contains(t, linksForTargetURL.Links, linkB)
+		} else {
+			assert.Empty(t, linksForTargetURL.Links)
+		}
+	}
+}
+
+func TestCrawler_GetAllLinksFor_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer server.Close()
+
+	var linksForTargetURLs []*LinksByTargetURL
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		linksForTargetURLs = append(linksForTargetURLs, linksForTargetURL)
+	}
+
+	var errs []error
+	onError := func(err error) {
+		errs = append(errs, err)
+	}
+
+	crawler := New(WithHTTPClient(&http.Client{Timeout: time.Nanosecond}), WithWorkers(100), WithRetryAttempts(1))
+	targetURL := makeURLFor(t, server.URL)
+	crawler.GetAllLinksFor(context.Background(), targetURL, onTargetURLProcessed, onError)
+
+	var crawlerError *Error
+	errors.As(errs[0], &crawlerError)
+
+	assert.Equal(t, targetURL, crawlerError.TargetURL)
+	assert.Error(t, crawlerError)
+}
+
+func TestCrawler_GetAllLinksFor_SkippedResources(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com": NewMemoryPage(http.StatusOK, "text/html", `
+			<a href="/report.pdf"/>
+			<a href="/video.m4v"/>
+			<a href="/large"/>
+			<a href="/data.json"/>
+		`),
+		"https://abc.com/video.m4v": NewMemoryPage(http.StatusOK, "video/mp4", strings.Repeat("v", 1024)),
+		"https://abc.com/large":     NewMemoryPage(http.StatusOK, "text/html", strings.Repeat("a", 2048)),
+		"https://abc.com/data.json": NewMemoryPage(http.StatusOK, "application/json", "{}"),
+	})
+
+	linksForTargetURLs := make(map[string]*LinksByTargetURL)
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		linksForTargetURLs[linksForTargetURL.TargetURL.Path] = linksForTargetURL
+	}
+
+	var errs []error
+	onError := func(err error) {
+		errs = append(errs, err)
+	}
+
+	crawler := New(
+		WithFetcher(fetcher),
+		WithWorkers(10),
+		WithRetryAttempts(1),
+		WithMaxBodySize(1024),
+		WithHeadBeforeGet(true),
+	)
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), onTargetURLProcessed, onError)
+
+	assert.Empty(t, errs)
+	assert.Len(t, linksForTargetURLs, 5)
+	assert.False(t, linksForTargetURLs[""].Skipped())
+
+	assert.True(t, linksForTargetURLs["/report.pdf"].Skipped())
+	assert.Equal(t, "application/pdf", linksForTargetURLs["/report.pdf"].ContentType)
+	assert.Equal(t, int64(-1), linksForTargetURLs["/report.pdf"].ContentLength)
+
+	assert.True(t, linksForTargetURLs["/video.m4v"].Skipped())
+	assert.Equal(t, "video/mp4", linksForTargetURLs["/video.m4v"].ContentType)
+	assert.Equal(t, int64(1024), linksForTargetURLs["/video.m4v"].ContentLength)
+
+	assert.True(t, linksForTargetURLs["/large"].Skipped())
+	assert.Equal(t, "text/html", linksForTargetURLs["/large"].ContentType)
+	assert.ErrorIs(t, linksForTargetURLs["/large"].Err, ErrorKindBodyTooLarge)
+
+	assert.True(t, linksForTargetURLs["/data.json"].Skipped())
+	assert.Equal(t, "application/json", linksForTargetURLs["/data.json"].ContentType)
+	assert.Nil(t, linksForTargetURLs["/data.json"].Err)
+
+	var headRequests []string
+	for _, request := range fetcher.Requests() {
+		if request.Method == http.MethodHead {
+			headRequests = append(headRequests, request.URL.Path)
+		}
+	}
+	assert.ElementsMatch(t, []string{"/video.m4v", "/data.json"}, headRequests)
+}
+
+func TestCrawler_GetAllLinksFor_DeniedExtensionsIgnoreCase(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com": NewMemoryPage(http.StatusOK, "text/html", `<a href="/report.pdf"/><a href="/SCAN.Tiff"/>`),
+	})
+
+	crawler := New(WithFetcher(fetcher), WithWorkers(1), WithRetryAttempts(1), WithDeniedExtensions(".PDF", "tiff"))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(*LinksByTargetURL) {}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Len(t, fetcher.Requests(), 1)
+}
+
+func TestCrawler_GetLinksForTargetURL_ShiftJIS(t *testing.T) {
+	// <meta charset="shift_jis"><a href="/日本">
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com": NewMemoryPage(http.StatusOK, "text/html", "<meta charset=\"shift_jis\"><a href=\"/\x93\xFA\x96{\">"),
+	})
+
+	crawler := New(WithFetcher(fetcher), WithWorkers(1), WithRetryAttempts(1))
+	linksForTargetURL, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "shift_jis", linksForTargetURL.Charset)
+	assert.Len(t, linksForTargetURL.Links, 1)
+	assert.Equal(t, "/日本", linksForTargetURL.Links[0].Path)
+}
+
+func TestCrawler_GetAllLinksFor_ExtractorsByContentType(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":            NewMemoryPage(http.StatusOK, "text/html", `<a href="/api"/><a href="/notes.txt"/>`),
+		"https://abc.com/api":        NewMemoryPage(http.StatusOK, "application/json", `{"next": "/api/page-2"}`),
+		"https://abc.com/api/page-2": NewMemoryPage(http.StatusOK, "application/json", `{"next": null}`),
+		"https://abc.com/notes.txt":  NewMemoryPage(http.StatusOK, "text/plain", "see https://abc.com/unlisted"),
+	})
+
+	linksForTargetURLs := make(map[string]*LinksByTargetURL)
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		linksForTargetURLs[linksForTargetURL.TargetURL.Path] = linksForTargetURL
+	}
+
+	crawler := New(
+		WithFetcher(fetcher),
+		WithWorkers(10),
+		WithRetryAttempts(1),
+		WithAllowedContentTypes("text/html", "application/json"),
+	)
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), onTargetURLProcessed, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Len(t, linksForTargetURLs, 4)
+	assert.Equal(t, []*url.URL{makeURLFor(t, "https://abc.com/api/page-2")}, linksForTargetURLs["/api"].Links)
+	assert.True(t, linksForTargetURLs["/notes.txt"].Skipped())
+}
+
+func TestCrawler_GetAllLinksFor_CSSResources(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":           NewMemoryPage(http.StatusOK, "text/html", `<link rel="stylesheet" href="/main.css"><a href="/a">A</a>`),
+		"https://abc.com/main.css":  NewMemoryPage(http.StatusOK, "text/css", `@import "/fonts.css"; .a { background: url(/bg.png) }`),
+		"https://abc.com/fonts.css": NewMemoryPage(http.StatusOK, "text/css", ``),
+		"https://abc.com/a":         NewMemoryPage(http.StatusOK, "text/html", ``),
+	})
+
+	linksForTargetURLs := make(map[string]*LinksByTargetURL)
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		linksForTargetURLs[linksForTargetURL.TargetURL.Path] = linksForTargetURL
+	}
+
+	crawler := New(WithFetcher(fetcher), WithWorkers(10), WithRetryAttempts(1))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), onTargetURLProcessed, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Len(t, linksForTargetURLs, 5)
+	assert.Equal(t, []*url.URL{makeURLFor(t, "https://abc.com/a")}, linksForTargetURLs[""].Links)
+	assert.Equal(t, []*url.URL{makeURLFor(t, "https://abc.com/main.css")}, linksForTargetURLs[""].Resources)
+	assert.Empty(t, linksForTargetURLs["/main.css"].Links)
+	assert.Equal(t, []*url.URL{
+		makeURLFor(t, "https://abc.com/fonts.css"),
+		makeURLFor(t, "https://abc.com/bg.png"),
+	}, linksForTargetURLs["/main.css"].Resources)
+	assert.True(t, linksForTargetURLs["/bg.png"].Skipped())
+}
+
+func TestCrawler_GetAllLinksFor_PanicRecovered(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":   NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/b">B</a>`),
+		"https://abc.com/a": NewMemoryPage(http.StatusOK, "text/html", ``),
+		"https://abc.com/b": NewMemoryPage(http.StatusOK, "text/html", ``),
+	})
+
+	var processed []string
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		if linksForTargetURL.TargetURL.Path == "/a" {
+			panic("something went wrong")
+		}
+		processed = append(processed, linksForTargetURL.TargetURL.String())
+	}
+
+	var errs []error
+	crawler := New(WithFetcher(fetcher), WithWorkers(2), WithRetryAttempts(1))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), onTargetURLProcessed, func(err error) {
+		errs = append(errs, err)
+	})
+
+	assert.ElementsMatch(t, []string{"https://abc.com", "https://abc.com/b"}, processed)
+	assert.Len(t, errs, 1)
+	var panicErr *PanicError
+	assert.ErrorAs(t, errs[0], &panicErr)
+}
+
+func TestCrawler_GetAllLinksFor_LogsFetches(t *testing.T) {
+	pages := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":   NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a>`),
+		"https://abc.com/a": NewMemoryPage(http.StatusOK, "text/html", ``),
+	})
+	var failed bool
+	fetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		if request.URL.Path == "/a" && !failed {
+			failed = true
+			return nil, errors.New("connection reset")
+		}
+		return pages.Fetch(ctx, request)
+	})
+
+	var output bytes.Buffer
+	logger, err := NewLogger(&output, "debug", "json")
+	assert.NoError(t, err)
+
+	crawler := New(WithFetcher(fetcher), WithWorkers(1), WithRetryAttempts(2), WithLogger(logger))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(*LinksByTargetURL) {}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	var fetches []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "fetch" || entry["msg"] == "fetch failed" {
+			delete(entry, "time")
+			assert.Contains(t, entry, "duration")
+			delete(entry, "duration")
+			fetches = append(fetches, entry)
+		}
+	}
+
+	assert.Equal(t, []map[string]interface{}{
+		{"level": "DEBUG", "msg": "fetch", "url": "https://abc.com", "depth": 0.0, "worker_id": 1.0, "method": "GET", "attempt": 1.0, "status": 200.0},
+		{"level": "WARN", "msg": "fetch failed", "url": "https://abc.com/a", "depth": 1.0, "worker_id": 1.0, "method": "GET", "attempt": 1.0, "error": "connection reset"},
+		{"level": "DEBUG", "msg": "fetch", "url": "https://abc.com/a", "depth": 1.0, "worker_id": 1.0, "method": "GET", "attempt": 2.0, "status": 200.0},
+	}, fetches)
+}
@@ -0,0 +1,299 @@
+package crawler
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type cssTokenType int
+
+const (
+	cssWhitespaceToken cssTokenType = iota
+	cssIdentToken
+	cssFunctionToken
+	cssAtKeywordToken
+	cssStringToken
+	cssURLToken
+	cssDelimToken
+)
+
+type cssToken struct {
+	tokenType cssTokenType
+	value     string
+}
+
+// cssTokenizer implements the parts of the CSS Syntax Module Level 3 tokenizer
+// needed to find URLs: comments, strings, escapes, identifiers, functions,
+// at-keywords and url() tokens. Everything else is returned as delimiters.
+type cssTokenizer struct {
+	input string
+	pos   int
+}
+
+func (t *cssTokenizer) peek(offset int) rune {
+	pos := t.pos
+	for ; offset > 0 && pos < len(t.input); offset-- {
+		_, size := utf8.DecodeRuneInString(t.input[pos:])
+		pos += size
+	}
+	if pos >= len(t.input) {
+		return -1
+	}
+
+	r, _ := utf8.DecodeRuneInString(t.input[pos:])
+	return r
+}
+
+func (t *cssTokenizer) advance() rune {
+	r, size := utf8.DecodeRuneInString(t.input[t.pos:])
+	t.pos += size
+	return r
+}
+
+func (t *cssTokenizer) next() (cssToken, bool) {
+	for strings.HasPrefix(t.input[t.pos:], "/*") {
+		end := strings.Index(t.input[t.pos+2:], "*/")
+		if end < 0 {
+			t.pos = len(t.input)
+		} else {
+			t.pos += end + 4
+		}
+	}
+
+	if t.pos >= len(t.input) {
+		return cssToken{}, false
+	}
+
+	switch r := t.peek(0); {
+	case isCSSWhitespace(r):
+		for isCSSWhitespace(t.peek(0)) {
+			t.advance()
+		}
+		return cssToken{tokenType: cssWhitespaceToken}, true
+	case r == '"' || r == '\'':
+		t.advance()
+		return cssToken{tokenType: cssStringToken, value: t.consumeString(r)}, true
+	case r == '@' && t.startsIdentifier(1):
+		t.advance()
+		return cssToken{tokenType: cssAtKeywordToken, value: t.consumeName()}, true
+	case t.startsIdentifier(0):
+		name := t.consumeName()
+		if t.peek(0) != '(' {
+			return cssToken{tokenType: cssIdentToken, value: name}, true
+		}
+		t.advance()
+
+		if !strings.EqualFold(name, "url") {
+			return cssToken{tokenType: cssFunctionToken, value: name}, true
+		}
+		for isCSSWhitespace(t.peek(0)) {
+			t.advance()
+		}
+		if quote := t.peek(0); quote == '"' || quote == '\'' {
+			return cssToken{tokenType: cssFunctionToken, value: name}, true
+		}
+		return t.consumeURL(), true
+	default:
+		t.advance()
+		return cssToken{tokenType: cssDelimToken, value: string(r)}, true
+	}
+}
+
+func (t *cssTokenizer) consumeString(quote rune) string {
+	var value strings.Builder
+	for t.pos < len(t.input) {
+		switch r := t.advance(); {
+		case r == quote:
+			return value.String()
+		case r == '\n':
+			// Unterminated strings are bad strings, which are dropped.
+			return ""
+		case r == '\\':
+			if t.peek(0) == '\n' {
+				t.advance()
+			} else if t.pos < len(t.input) {
+				value.WriteRune(t.consumeEscape())
+			}
+		default:
+			value.WriteRune(r)
+		}
+	}
+
+	return value.String()
+}
+
+func (t *cssTokenizer) consumeURL() cssToken {
+	var value strings.Builder
+	for t.pos < len(t.input) {
+		switch r := t.advance(); {
+		case r == ')':
+			return cssToken{tokenType: cssURLToken, value: value.String()}
+		case isCSSWhitespace(r):
+			for isCSSWhitespace(t.peek(0)) {
+				t.advance()
+			}
+			if t.peek(0) == ')' || t.pos >= len(t.input) {
+				t.advance()
+				return cssToken{tokenType: cssURLToken, value: value.String()}
+			}
+			return t.consumeBadURL()
+		case r == '"' || r == '\'' || r == '(' || isCSSNonPrintable(r):
+			return t.consumeBadURL()
+		case r == '\\':
+			if t.peek(0) == '\n' {
+				return t.consumeBadURL()
+			}
+			value.WriteRune(t.consumeEscape())
+		default:
+			value.WriteRune(r)
+		}
+	}
+
+	return cssToken{tokenType: cssURLToken, value: value.String()}
+}
+
+// consumeBadURL skips what is left of a malformed url() so that tokenizing
+// can carry on after it.
+func (t *cssTokenizer) consumeBadURL() cssToken {
+	for t.pos < len(t.input) {
+		switch t.advance() {
+		case ')':
+			return cssToken{tokenType: cssDelimToken}
+		case '\\':
+			if t.pos < len(t.input) {
+				t.advance()
+			}
+		}
+	}
+
+	return cssToken{tokenType: cssDelimToken}
+}
+
+func (t *cssTokenizer) consumeName() string {
+	var name strings.Builder
+	for {
+		switch r := t.peek(0); {
+		case r == '\\' && t.peek(1) != '\n' && t.peek(1) != -1:
+			t.advance()
+			name.WriteRune(t.consumeEscape())
+		case isCSSNameCharacter(r):
+			name.WriteRune(t.advance())
+		default:
+			return name.String()
+		}
+	}
+}
+
+// consumeEscape is called right after a backslash.
+func (t *cssTokenizer) consumeEscape() rune {
+	if !isHexDigit(t.peek(0)) {
+		return t.advance()
+	}
+
+	start := t.pos
+	for t.pos-start < 6 && isHexDigit(t.peek(0)) {
+		t.advance()
+	}
+	codePoint, _ := strconv.ParseUint(t.input[start:t.pos], 16, 32)
+	if isCSSWhitespace(t.peek(0)) {
+		t.advance()
+	}
+
+	if codePoint == 0 || codePoint > utf8.MaxRune || (codePoint >= 0xD800 && codePoint <= 0xDFFF) {
+		return utf8.RuneError
+	}
+
+	return rune(codePoint)
+}
+
+func (t *cssTokenizer) startsIdentifier(offset int) bool {
+	first := t.peek(offset)
+	if first == '-' {
+		second := t.peek(offset + 1)
+		return isCSSNameStartCharacter(second) || second == '-' || (second == '\\' && t.peek(offset+2) != '\n')
+	}
+	if first == '\\' {
+		return t.peek(offset+1) != '\n' && t.peek(offset+1) != -1
+	}
+
+	return isCSSNameStartCharacter(first)
+}
+
+func isCSSWhitespace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+func isCSSNameStartCharacter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r >= 0x80
+}
+
+func isCSSNameCharacter(r rune) bool {
+	return isCSSNameStartCharacter(r) || (r >= '0' && r <= '9') || r == '-'
+}
+
+func isCSSNonPrintable(r rune) bool {
+	return (r >= 0 && r <= 8) || r == 0x0B || (r >= 0x0E && r <= 0x1F) || r == 0x7F
+}
+
+func isHexDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+// ExtractCSSLinks finds the URLs referenced by a stylesheet, quoted or not, in
+// url() functions and @import rules. They are all resources.
+func ExtractCSSLinks(css string) []*Link {
+	var tokens []cssToken
+	tokenizer := &cssTokenizer{input: css}
+	for {
+		token, ok := tokenizer.next()
+		if !ok {
+			break
+		}
+		if token.tokenType != cssWhitespaceToken {
+			tokens = append(tokens, token)
+		}
+	}
+
+	var links []*Link
+	addLink := func(rawURL string) {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" {
+			return
+		}
+		if u, err := url.Parse(rawURL); err == nil {
+			links = append(links, &Link{URL: u, Resource: true})
+		}
+	}
+
+	for i, token := range tokens {
+		switch token.tokenType {
+		case cssURLToken:
+			addLink(token.value)
+		case cssFunctionToken, cssAtKeywordToken:
+			isURLFunction := token.tokenType == cssFunctionToken && strings.EqualFold(token.value, "url")
+			isImport := token.tokenType == cssAtKeywordToken && strings.EqualFold(token.value, "import")
+			if (isURLFunction || isImport) && i+1 < len(tokens) && tokens[i+1].tokenType == cssStringToken {
+				addLink(tokens[i+1].value)
+			}
+		}
+	}
+
+	return links
+}
+
+// CSSExtractor gets the links out of text/css documents.
+type CSSExtractor struct{}
+
+// Extract returns the links of a stylesheet in document order (see
+// ExtractCSSLinks).
+func (CSSExtractor) Extract(body io.Reader) ([]*Link, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExtractCSSLinks(string(content)), nil
+}
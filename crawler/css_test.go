@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExtractCSSLinks_Success(t *testing.T) {
+	links := ExtractCSSLinks(`
+		@import "/base.css";
+		@import url(/print.css) print;
+		.a { background-image: url( "/a.png" ); }
+		.b { background: url('/b.png') no-repeat; }
+		.c { background: URL( /c.png ); }
+	`)
+
+	assert.Equal(t, []string{"/base.css", "/print.css", "/a.png", "/b.png", "/c.png"}, urlStringsOf(links))
+	for _, link := range links {
+		assert.True(t, link.Resource)
+	}
+}
+
+func TestExtractCSSLinks_EscapesAndComments_Success(t *testing.T) {
+	links := ExtractCSSLinks(`
+		/* .old { background: url(/old.png) } */
+		.a { background: url(/images/a\ b.png) }
+		.b { background: url("/images/\"quoted\".png") }
+		.c { background: url(/images/\63 .png) }
+		.d { content: "url(/not-a-link.png)" }
+		.e { background: my-url(/not-a-link.png) }
+	`)
+
+	assert.Equal(t, []string{"/images/a%20b.png", `/images/%22quoted%22.png`, "/images/c.png"}, urlStringsOf(links))
+}
+
+func TestExtractCSSLinks_Malformed_Success(t *testing.T) {
+	links := ExtractCSSLinks(`
+		.a { background: url(/a b.png) }
+		.b { background: url(/b(.png) }
+		.c { background: url("/c.png
+		.d { background: url(/d.png) }
+		.e { background: url(/e.png`)
+
+	assert.Equal(t, []string{"/d.png", "/e.png"}, urlStringsOf(links))
+}
+
+func TestCSSExtractor_Extract_SingleQuotedImport(t *testing.T) {
+	links, err := CSSExtractor{}.Extract(strings.NewReader(`@import '/base.css'; body { background: url(/bg.png) }`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/base.css", "/bg.png"}, urlStringsOf(links))
+}
@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+)
+
+// DiffFormats are the formats CrawlDiff can be written in.
+var DiffFormats = []string{"text", "json"}
+
+// CrawlDiff is what changed between two crawls of a site, e.g. before and
+// after a migration. NewlyOrphaned lists the pages that were linked to or used
+// as a resource by the old crawl but by no page of the new one, whether or not
+// they were reached.
+type CrawlDiff struct {
+	Added            []string      `json:"added"`
+	Removed          []string      `json:"removed"`
+	StatusChanges    []FieldChange `json:"status_changes"`
+	NewRedirects     []FieldChange `json:"new_redirects"`
+	TitleChanges     []FieldChange `json:"title_changes"`
+	CanonicalChanges []FieldChange `json:"canonical_changes"`
+	LinkChanges      []LinkChange  `json:"link_changes"`
+	NewlyOrphaned    []string      `json:"newly_orphaned"`
+}
+
+// FieldChange is a page whose field went from Old to New.
+type FieldChange struct {
+	URL string `json:"url"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// LinkChange is a page whose outbound links changed.
+type LinkChange struct {
+	URL     string   `json:"url"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// DiffManifests compares the manifests of two crawls.
+func DiffManifests(oldManifest *Manifest, newManifest *Manifest) *CrawlDiff {
+	diff := &CrawlDiff{
+		Added:            []string{},
+		Removed:          []string{},
+		StatusChanges:    []FieldChange{},
+		NewRedirects:     []FieldChange{},
+		TitleChanges:     []FieldChange{},
+		CanonicalChanges: []FieldChange{},
+		LinkChanges:      []LinkChange{},
+		NewlyOrphaned:    []string{},
+	}
+
+	for _, newEntry := range newManifest.Entries() {
+		targetURL, _ := url.Parse(newEntry.URL)
+		oldEntry, ok := oldManifest.Lookup(targetURL)
+		if !ok {
+			diff.Added = append(diff.Added, newEntry.URL)
+			continue
+		}
+
+		if oldStatus, newStatus := statusOf(oldEntry), statusOf(newEntry); oldStatus != newStatus {
+			diff.StatusChanges = append(diff.StatusChanges, FieldChange{URL: newEntry.URL, Old: oldStatus, New: newStatus})
+		}
+		if newEntry.RedirectedTo != "" && newEntry.RedirectedTo != oldEntry.RedirectedTo {
+			diff.NewRedirects = append(diff.NewRedirects, FieldChange{URL: newEntry.URL, Old: oldEntry.RedirectedTo, New: newEntry.RedirectedTo})
+		}
+		if oldEntry.Title != newEntry.Title {
+			diff.TitleChanges = append(diff.TitleChanges, FieldChange{URL: newEntry.URL, Old: oldEntry.Title, New: newEntry.Title})
+		}
+		if oldEntry.Canonical != newEntry.Canonical {
+			diff.CanonicalChanges = append(diff.CanonicalChanges, FieldChange{URL: newEntry.URL, Old: oldEntry.Canonical, New: newEntry.Canonical})
+		}
+		if added, removed := diffStrings(oldEntry.Links, newEntry.Links); len(added) > 0 || len(removed) > 0 {
+			diff.LinkChanges = append(diff.LinkChanges, LinkChange{URL: newEntry.URL, Added: added, Removed: removed})
+		}
+	}
+
+	for _, oldEntry := range oldManifest.Entries() {
+		targetURL, _ := url.Parse(oldEntry.URL)
+		if _, ok := newManifest.Lookup(targetURL); !ok {
+			diff.Removed = append(diff.Removed, oldEntry.URL)
+		}
+	}
+
+	oldReferrers, newReferrers := referrers(oldManifest), referrers(newManifest)
+	for _, entry := range oldManifest.Entries() {
+		key := normalizedURLOf(entry.URL)
+		if len(oldReferrers[key]) > 0 && len(newReferrers[key]) == 0 {
+			diff.NewlyOrphaned = append(diff.NewlyOrphaned, entry.URL)
+		}
+	}
+
+	return diff
+}
+
+// Empty tells whether the crawls found the site the same.
+func (d *CrawlDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.StatusChanges) == 0 && len(d.NewRedirects) == 0 &&
+		len(d.TitleChanges) == 0 && len(d.CanonicalChanges) == 0 && len(d.LinkChanges) == 0 && len(d.NewlyOrphaned) == 0
+}
+
+// WriteJSON writes the diff as an indented JSON object.
+func (d *CrawlDiff) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(d)
+}
+
+// WriteText writes the diff for humans, one section per kind of change, the
+// empty ones being left out.
+func (d *CrawlDiff) WriteText(w io.Writer) error {
+	if d.Empty() {
+		_, err := fmt.Fprintln(w, "No differences.")
+		return err
+	}
+
+	writeURLs := func(title string, urls []string) {
+		if len(urls) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(urls))
+		for _, u := range urls {
+			fmt.Fprintf(w, "  %s\n", u)
+		}
+		fmt.Fprintln(w)
+	}
+	writeChanges := func(title string, changes []FieldChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(changes))
+		for _, change := range changes {
+			fmt.Fprintf(w, "  %s: %q -> %q\n", change.URL, change.Old, change.New)
+		}
+		fmt.Fprintln(w)
+	}
+
+	writeURLs("Added", d.Added)
+	writeURLs("Removed", d.Removed)
+	writeChanges("Status changes", d.StatusChanges)
+	writeChanges("New redirects", d.NewRedirects)
+	writeChanges("Title changes", d.TitleChanges)
+	writeChanges("Canonical changes", d.CanonicalChanges)
+	if len(d.LinkChanges) > 0 {
+		fmt.Fprintf(w, "Link changes (%d):\n", len(d.LinkChanges))
+		for _, change := range d.LinkChanges {
+			fmt.Fprintf(w, "  %s\n", change.URL)
+			for _, link := range change.Added {
+				fmt.Fprintf(w, "    + %s\n", link)
+			}
+			for _, link := range change.Removed {
+				fmt.Fprintf(w, "    - %s\n", link)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	writeURLs("Newly orphaned", d.NewlyOrphaned)
+
+	return nil
+}
+
+// statusOf describes the outcome of a page: its status code, or the error it
+// failed with.
+func statusOf(entry *ManifestEntry) string {
+	if entry.Error != "" {
+		return "error: " + entry.Error
+	}
+
+	return fmt.Sprint(entry.StatusCode)
+}
+
+// referrers returns the URLs of the pages linking to each page of the
+// manifest or using it as a resource, keyed by normalized URL. Links of a page
+// to itself are left out.
+func referrers(manifest *Manifest) map[string][]string {
+	linkedFrom := make(map[string][]string)
+	for _, entry := range manifest.Entries() {
+		from := normalizedURLOf(entry.URL)
+		for _, links := range [][]string{entry.Links, entry.Resources} {
+			for _, link := range links {
+				if to := normalizedURLOf(link); to != from && !slices.Contains(linkedFrom[to], entry.URL) {
+					linkedFrom[to] = append(linkedFrom[to], entry.URL)
+				}
+			}
+		}
+	}
+
+	return linkedFrom
+}
+
+func normalizedURLOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return NormalizeURL(u)
+}
+
+// diffStrings returns the strings of b missing from a, and those of a missing
+// from b, sorted.
+func diffStrings(a []string, b []string) ([]string, []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+
+	var added, removed []string
+	for s := range inB {
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for s := range inA {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func newTestManifest(t *testing.T, entries ...*ManifestEntry) *Manifest {
+	manifest := NewManifest()
+	for _, entry := range entries {
+		assert.NoError(t, manifest.Add(entry))
+	}
+
+	return manifest
+}
+
+func TestDiffManifests_Success(t *testing.T) {
+	oldManifest := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Title: "Home",
+			Links: []string{"https://abc.com/about", "https://abc.com/blog", "https://abc.com/old"}},
+		&ManifestEntry{URL: "https://abc.com/about", StatusCode: http.StatusOK, Title: "About", Canonical: "https://abc.com/about"},
+		&ManifestEntry{URL: "https://abc.com/blog", StatusCode: http.StatusOK, Title: "Blog"},
+		&ManifestEntry{URL: "https://abc.com/old", StatusCode: http.StatusOK},
+	)
+	newManifest := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Title: "Home",
+			Links: []string{"https://abc.com/about", "https://abc.com/news"}},
+		&ManifestEntry{URL: "https://abc.com/about", StatusCode: http.StatusOK, Title: "About us", Canonical: "https://abc.com/about-us"},
+		&ManifestEntry{URL: "https://abc.com/blog", StatusCode: http.StatusOK, Title: "Blog", RedirectedTo: "https://abc.com/news"},
+		&ManifestEntry{URL: "https://abc.com/news", StatusCode: http.StatusInternalServerError},
+	)
+
+	diff := DiffManifests(oldManifest, newManifest)
+
+	assert.Equal(t, []string{"https://abc.com/news"}, diff.Added)
+	assert.Equal(t, []string{"https://abc.com/old"}, diff.Removed)
+	assert.Empty(t, diff.StatusChanges)
+	assert.Equal(t, []FieldChange{{URL: "https://abc.com/blog", New: "https://abc.com/news"}}, diff.NewRedirects)
+	assert.Equal(t, []FieldChange{{URL: "https://abc.com/about", Old: "About", New: "About us"}}, diff.TitleChanges)
+	assert.Equal(t, []FieldChange{{URL: "https://abc.com/about", Old: "https://abc.com/about", New: "https://abc.com/about-us"}}, diff.CanonicalChanges)
+	assert.Equal(t, []LinkChange{{
+		URL:     "https://abc.com/",
+		Added:   []string{"https://abc.com/news"},
+		Removed: []string{"https://abc.com/blog", "https://abc.com/old"},
+	}}, diff.LinkChanges)
+	assert.Equal(t, []string{"https://abc.com/blog", "https://abc.com/old"}, diff.NewlyOrphaned)
+	assert.False(t, diff.Empty())
+
+	var text bytes.Buffer
+	assert.NoError(t, diff.WriteText(&text))
+	assert.Equal(t, `Added (1):
+  https://abc.com/news
+
+Removed (1):
+  https://abc.com/old
+
+New redirects (1):
+  https://abc.com/blog: "" -> "https://abc.com/news"
+
+Title changes (1):
+  https://abc.com/about: "About" -> "About us"
+
+Canonical changes (1):
+  https://abc.com/about: "https://abc.com/about" -> "https://abc.com/about-us"
+
+Link changes (1):
+  https://abc.com/
+    + https://abc.com/news
+    - https://abc.com/blog
+    - https://abc.com/old
+
+Newly orphaned (2):
+  https://abc.com/blog
+  https://abc.com/old
+
+`, text.String())
+}
+
+func TestDiffManifests_Resources(t *testing.T) {
+	oldManifest := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK,
+			Links: []string{"https://abc.com/logo.png"}, Resources: []string{"https://abc.com/style.css"}},
+		&ManifestEntry{URL: "https://abc.com/logo.png", StatusCode: http.StatusOK},
+		&ManifestEntry{URL: "https://abc.com/style.css", StatusCode: http.StatusOK},
+	)
+	newManifest := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK,
+			Resources: []string{"https://abc.com/logo.png"}},
+		&ManifestEntry{URL: "https://abc.com/logo.png", StatusCode: http.StatusOK},
+		&ManifestEntry{URL: "https://abc.com/style.css", StatusCode: http.StatusOK},
+	)
+
+	// The logo is still used as an image, only the stylesheet is orphaned.
+	diff := DiffManifests(oldManifest, newManifest)
+	assert.Equal(t, []string{"https://abc.com/style.css"}, diff.NewlyOrphaned)
+}
+
+func TestDiffManifests_StatusChanges(t *testing.T) {
+	oldManifest := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusOK},
+		&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusOK},
+	)
+	newManifest := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusNotFound},
+		&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusOK, Error: "timeout"},
+	)
+
+	diff := DiffManifests(oldManifest, newManifest)
+	assert.Equal(t, []FieldChange{
+		{URL: "https://abc.com/a", Old: "200", New: "404"},
+		{URL: "https://abc.com/b", Old: "200", New: "error: timeout"},
+	}, diff.StatusChanges)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, diff.WriteJSON(&buffer))
+	assert.Contains(t, buffer.String(), `"status_changes": [
+    {
+      "url": "https://abc.com/a",
+      "old": "200",
+      "new": "404"
+    },
+    {
+      "url": "https://abc.com/b",
+      "old": "200",
+      "new": "error: timeout"
+    }
+  ]`)
+
+	diff = DiffManifests(oldManifest, oldManifest)
+	assert.True(t, diff.Empty())
+	buffer.Reset()
+	assert.NoError(t, diff.WriteText(&buffer))
+	assert.Equal(t, "No differences.\n", buffer.String())
+}
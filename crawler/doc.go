@@ -0,0 +1,12 @@
+// Package crawler crawls the pages of a site, following the links between
+// them. A Crawler is created with New and configured with options, e.g.
+//
+//	c := crawler.New(crawler.WithWorkers(10), crawler.WithMaxPages(1000))
+//	c.GetAllLinksFor(ctx, targetURL, onTargetURLProcessed, onError)
+//
+// Pages are fetched by a Fetcher, which can be wrapped by FetcherMiddleware,
+// and their links are extracted by the Extractor registered for their content
+// type. Feeds, spider traps, metrics and timings are looked after by the
+// FeedMonitor, TrapDetector, Metrics and TimingReport given to the Crawler or
+// fed its results.
+package crawler
@@ -0,0 +1,275 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+)
+
+// ErrorKind classifies why a page could not be crawled. It is also an error,
+// so that errors.Is(err, ErrorKindTimeout) tells whether err is an Error of
+// that kind.
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is an error none of the other kinds describe.
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindDNS is a host name that could not be resolved.
+	ErrorKindDNS
+	// ErrorKindConnection is a connection refused, reset or otherwise lost.
+	ErrorKindConnection
+	// ErrorKindTLS is a failed handshake or an invalid certificate.
+	ErrorKindTLS
+	// ErrorKindTimeout is a request that took too long.
+	ErrorKindTimeout
+	// ErrorKindCanceled is a request stopped because the crawl was.
+	ErrorKindCanceled
+	// ErrorKindHTTPStatus is a 429 or 5XX response, still there after the
+	// last attempt.
+	ErrorKindHTTPStatus
+	// ErrorKindBodyTooLarge is a body over the maximum body size. The page is
+	// skipped rather than failed, with an Error of this kind as its Err.
+	ErrorKindBodyTooLarge
+	// ErrorKindDecode is a body that could not be decoded to UTF-8.
+	ErrorKindDecode
+	// ErrorKindExtract is a document the Extractor failed on.
+	ErrorKindExtract
+	// ErrorKindHook is an error returned by a hook.
+	ErrorKindHook
+)
+
+var errorKindNames = [...]string{
+	ErrorKindUnknown:      "unknown",
+	ErrorKindDNS:          "dns",
+	ErrorKindConnection:   "connection",
+	ErrorKindTLS:          "tls",
+	ErrorKindTimeout:      "timeout",
+	ErrorKindCanceled:     "canceled",
+	ErrorKindHTTPStatus:   "http_status",
+	ErrorKindBodyTooLarge: "body_too_large",
+	ErrorKindDecode:       "decode",
+	ErrorKindExtract:      "extract",
+	ErrorKindHook:         "hook",
+}
+
+// String returns the name of the kind, e.g. "timeout", as used in logs and
+// reports.
+func (k ErrorKind) String() string {
+	if k < 0 || int(k) >= len(errorKindNames) {
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+
+	return errorKindNames[k]
+}
+
+// Error returns the name of the kind, to use it as a target of errors.Is.
+func (k ErrorKind) Error() string {
+	return k.String()
+}
+
+// Error is the error of a page that could not be crawled. Attempts is the
+// number of requests made for the page and Retryable tells whether crawling it
+// again later could succeed. StatusCode is only set for ErrorKindHTTPStatus.
+type Error struct {
+	TargetURL  *url.URL
+	Kind       ErrorKind
+	StatusCode int
+	Attempts   int
+	Retryable  bool
+	Err        error
+}
+
+// Error returns the message of Err with the URL of the page.
+func (c Error) Error() string {
+	return fmt.Sprintf("failed to extract links from %s: %s", c.TargetURL.String(), c.Err.Error())
+}
+
+// Unwrap returns Err.
+func (c Error) Unwrap() error {
+	return c.Err
+}
+
+// Is reports whether target is the ErrorKind of the error.
+func (c Error) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind == c.Kind
+}
+
+// newError makes the Error of a page, classifying err to find its kind.
+func newError(targetURL *url.URL, err error) *Error {
+	kind, retryable := classifyError(err)
+	crawlerErr := &Error{TargetURL: targetURL, Kind: kind, Retryable: retryable, Err: err}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		crawlerErr.StatusCode = statusErr.statusCode
+	}
+
+	return crawlerErr
+}
+
+// statusError is returned for the responses whose status code says the server
+// could not serve the page right now, so that they are retried.
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", e.statusCode, http.StatusText(e.statusCode))
+}
+
+// isRetryableStatus tells whether a response with the status code is worth
+// retrying: server errors and rate limiting.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+// classifyError finds the kind of an error and whether retrying could help.
+// Errors it knows nothing about are assumed to be transient.
+func classifyError(err error) (ErrorKind, bool) {
+	var crawlerErr *Error
+	if errors.As(err, &crawlerErr) {
+		return crawlerErr.Kind, crawlerErr.Retryable
+	}
+
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var alertErr tls.AlertError
+	var recordHeaderErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var statusErr *statusError
+	var netErr net.Error
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled, false
+	case errors.As(err, &dnsErr):
+		return ErrorKindDNS, dnsErr.IsTemporary || dnsErr.IsTimeout
+	case errors.As(err, &certErr), errors.As(err, &alertErr), errors.As(err, &recordHeaderErr),
+		errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr), errors.As(err, &certificateInvalidErr):
+		return ErrorKindTLS, false
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorKindTimeout, true
+	case errors.As(err, &statusErr):
+		return ErrorKindHTTPStatus, true
+	case errors.Is(err, errBodyTooLarge):
+		return ErrorKindBodyTooLarge, false
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &opErr):
+		return ErrorKindConnection, true
+	}
+
+	return ErrorKindUnknown, true
+}
+
+// ErrorGroup counts the pages of a host that failed for the same kind of
+// error, Example being one of them.
+type ErrorGroup struct {
+	Kind    ErrorKind
+	Host    string
+	Count   int
+	Example *Error
+}
+
+// ErrorSummary groups the errors of a crawl by kind and host, to be reported
+// once the crawl is over.
+type ErrorSummary struct {
+	groups map[errorGroupKey]*ErrorGroup
+	other  []error
+	m      sync.Mutex
+}
+
+type errorGroupKey struct {
+	kind ErrorKind
+	host string
+}
+
+// NewErrorSummary returns an empty summary.
+func NewErrorSummary() *ErrorSummary {
+	return &ErrorSummary{groups: make(map[errorGroupKey]*ErrorGroup)}
+}
+
+// Observe adds an error to the summary. Errors that are not the Error of a
+// page, e.g. a panic, are kept as they are.
+func (s *ErrorSummary) Observe(err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var crawlerErr *Error
+	if !errors.As(err, &crawlerErr) {
+		s.other = append(s.other, err)
+		return
+	}
+
+	key := errorGroupKey{kind: crawlerErr.Kind, host: crawlerErr.TargetURL.Host}
+	group, ok := s.groups[key]
+	if !ok {
+		group = &ErrorGroup{Kind: key.kind, Host: key.host, Example: crawlerErr}
+		s.groups[key] = group
+	}
+	group.Count++
+}
+
+// Groups returns the groups of errors, the most common first.
+func (s *ErrorSummary) Groups() []ErrorGroup {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	groups := make([]ErrorGroup, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		if groups[i].Kind != groups[j].Kind {
+			return groups[i].Kind < groups[j].Kind
+		}
+		return groups[i].Host < groups[j].Host
+	})
+
+	return groups
+}
+
+// Other returns the errors that are not the Error of a page.
+func (s *ErrorSummary) Other() []error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return append([]error(nil), s.other...)
+}
+
+// Total returns the number of errors observed.
+func (s *ErrorSummary) Total() int {
+	total := len(s.Other())
+	for _, group := range s.Groups() {
+		total += group.Count
+	}
+
+	return total
+}
+
+// WriteTable writes one line per group of errors, the most common first.
+func (s *ErrorSummary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tHOST\tPAGES\tRETRYABLE\tEXAMPLE")
+	for _, group := range s.Groups() {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%t\t%s\n", group.Kind, group.Host, group.Count, group.Example.Retryable, group.Example.TargetURL)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError_Success(t *testing.T) {
+	tests := []struct {
+		err       error
+		kind      ErrorKind
+		retryable bool
+	}{
+		{&net.DNSError{Err: "no such host", Name: "abc.com", IsNotFound: true}, ErrorKindDNS, false},
+		{&net.DNSError{Err: "server misbehaving", Name: "abc.com", IsTemporary: true}, ErrorKindDNS, true},
+		{&tls.CertificateVerificationError{Err: errors.New("expired")}, ErrorKindTLS, false},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), ErrorKindTimeout, true},
+		{context.Canceled, ErrorKindCanceled, false},
+		{&statusError{statusCode: http.StatusServiceUnavailable}, ErrorKindHTTPStatus, true},
+		{fmt.Errorf("%w: more than 10 bytes", errBodyTooLarge), ErrorKindBodyTooLarge, false},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrorKindConnection, true},
+		{io.ErrUnexpectedEOF, ErrorKindConnection, true},
+		{errors.New("something else"), ErrorKindUnknown, true},
+	}
+
+	for _, test := range tests {
+		kind, retryable := classifyError(test.err)
+		assert.Equal(t, test.kind, kind, test.err.Error())
+		assert.Equal(t, test.retryable, retryable, test.err.Error())
+	}
+}
+
+func TestError_Is_Success(t *testing.T) {
+	err := fmt.Errorf("crawl: %w", newError(makeURLFor(t, "https://abc.com"), context.DeadlineExceeded))
+
+	assert.ErrorIs(t, err, ErrorKindTimeout)
+	assert.NotErrorIs(t, err, ErrorKindDNS)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "timeout", ErrorKindTimeout.String())
+}
+
+func TestCrawler_GetLinksForTargetURL_RetryStatus(t *testing.T) {
+	var statusCodes []int
+	fetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		statusCode := statusCodes[0]
+		statusCodes = statusCodes[1:]
+		return NewMemoryFetcher(map[string]*MemoryPage{
+			"https://abc.com": NewMemoryPage(statusCode, "text/html", `<a href="/a">A</a>`),
+		}).Fetch(ctx, request)
+	})
+	crawler := New(WithFetcher(fetcher), WithRetryAttempts(3))
+
+	statusCodes = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	linksForTargetURL, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, linksForTargetURL.StatusCode)
+	assert.Len(t, linksForTargetURL.Timings, 3)
+
+	// The last response is kept, with the error the page would have failed with.
+	statusCodes = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	linksForTargetURL, err = crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, linksForTargetURL.StatusCode)
+	assert.Equal(t, []string{"https://abc.com/a"}, urlStringsOf(linksForTargetURL.OutLinks))
+	crawlerErr := linksForTargetURL.Err
+	assert.ErrorIs(t, crawlerErr, ErrorKindHTTPStatus)
+	assert.Equal(t, http.StatusBadGateway, crawlerErr.StatusCode)
+	assert.Equal(t, 3, crawlerErr.Attempts)
+	assert.True(t, crawlerErr.Retryable)
+
+	// Client errors are pages like any other.
+	statusCodes = []int{http.StatusNotFound}
+	linksForTargetURL, err = crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, linksForTargetURL.StatusCode)
+	assert.Nil(t, linksForTargetURL.Err)
+}
+
+func TestCrawler_GetLinksForTargetURL_NotRetryable(t *testing.T) {
+	var attempts int
+	fetcher := FetcherFunc(func(context.Context, *FetchRequest) (*FetchResponse, error) {
+		attempts++
+		return nil, &net.DNSError{Err: "no such host", Name: "abc.com", IsNotFound: true}
+	})
+	crawler := New(WithFetcher(fetcher), WithRetryAttempts(3))
+
+	_, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.ErrorIs(t, err, ErrorKindDNS)
+	assert.Equal(t, 1, attempts)
+
+	var crawlerErr *Error
+	assert.ErrorAs(t, err, &crawlerErr)
+	assert.Equal(t, 1, crawlerErr.Attempts)
+	assert.False(t, crawlerErr.Retryable)
+}
+
+func TestErrorSummary_Success(t *testing.T) {
+	summary := NewErrorSummary()
+	summary.Observe(newError(makeURLFor(t, "https://abc.com/a"), context.DeadlineExceeded))
+	summary.Observe(newError(makeURLFor(t, "https://abc.com/b"), context.DeadlineExceeded))
+	summary.Observe(newError(makeURLFor(t, "https://cdn.abc.com/c"), context.DeadlineExceeded))
+	summary.Observe(newError(makeURLFor(t, "https://abc.com/d"), &statusError{statusCode: http.StatusInternalServerError}))
+	summary.Observe(&PanicError{Value: "boom"})
+
+	groups := summary.Groups()
+	assert.Len(t, groups, 3)
+	assert.Equal(t, ErrorKindTimeout, groups[0].Kind)
+	assert.Equal(t, "abc.com", groups[0].Host)
+	assert.Equal(t, 2, groups[0].Count)
+	assert.Equal(t, "https://abc.com/a", groups[0].Example.TargetURL.String())
+	assert.Equal(t, ErrorKindTimeout, groups[1].Kind)
+	assert.Equal(t, "cdn.abc.com", groups[1].Host)
+	assert.Equal(t, ErrorKindHTTPStatus, groups[2].Kind)
+	assert.Len(t, summary.Other(), 1)
+	assert.Equal(t, 5, summary.Total())
+
+	var table bytes.Buffer
+	assert.NoError(t, summary.WriteTable(&table))
+	assert.Equal(t, "KIND         HOST         PAGES  RETRYABLE  EXAMPLE\n"+
+		"timeout      abc.com      2      true       https://abc.com/a\n"+
+		"timeout      cdn.abc.com  1      true       https://cdn.abc.com/c\n"+
+		"http_status  abc.com      1      true       https://abc.com/d\n", table.String())
+}
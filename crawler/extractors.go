@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Extractor gets the links out of a document. Links may be relative, the
+// Crawler resolves them against the URL of the document.
+type Extractor interface {
+	Extract(body io.Reader) ([]*Link, error)
+}
+
+// ExtractorFunc makes an Extractor of a function.
+type ExtractorFunc func(body io.Reader) ([]*Link, error)
+
+// Extract calls f.
+func (f ExtractorFunc) Extract(body io.Reader) ([]*Link, error) {
+	return f(body)
+}
+
+// ExtractorRegistry picks the Extractor for a document based on its media type.
+type ExtractorRegistry struct {
+	extractors map[string]Extractor
+	m          sync.RWMutex
+}
+
+// NewExtractorRegistry returns a registry without extractors.
+func NewExtractorRegistry() *ExtractorRegistry {
+	return &ExtractorRegistry{extractors: make(map[string]Extractor)}
+}
+
+// NewDefaultExtractorRegistry returns a registry with the extractors for HTML,
+// plain text, XML, RSS/Atom feeds, CSS and JSON documents.
+func NewDefaultExtractorRegistry() *ExtractorRegistry {
+	registry := NewExtractorRegistry()
+	registry.Register("text/html", HTMLExtractor{})
+	registry.Register("application/xhtml+xml", HTMLExtractor{})
+	registry.Register("text/plain", TextExtractor{})
+	registry.Register("application/xml", XMLExtractor{})
+	registry.Register("text/xml", XMLExtractor{})
+	for _, feedContentType := range feedContentTypes {
+		registry.Register(feedContentType, FeedExtractor{})
+	}
+	registry.Register("text/css", CSSExtractor{})
+	registry.Register("application/json", JSONExtractor{})
+
+	return registry
+}
+
+// Register sets the Extractor for a media type (e.g. "text/html"), replacing
+// the one that was registered before.
+func (r *ExtractorRegistry) Register(mediaType string, extractor Extractor) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.extractors[strings.ToLower(mediaType)] = extractor
+}
+
+// Lookup finds the Extractor for the media type in a Content-Type header. Media
+// types with a structured syntax suffix (e.g. "application/rss+xml") fall back
+// to the extractor of the suffix (e.g. "application/xml").
+func (r *ExtractorRegistry) Lookup(contentType string) (Extractor, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	if extractor, ok := r.extractors[mediaType]; ok {
+		return extractor, true
+	}
+
+	if index := strings.LastIndexByte(mediaType, '+'); index >= 0 {
+		extractor, ok := r.extractors["application/"+mediaType[index+1:]]
+		return extractor, ok
+	}
+
+	return nil, false
+}
+
+var textURLPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// TextExtractor finds absolute HTTP(S) URLs in plain text.
+type TextExtractor struct{}
+
+// Extract never fails but on reading body.
+func (TextExtractor) Extract(body io.Reader) ([]*Link, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []*Link
+	for _, match := range textURLPattern.FindAll(content, -1) {
+		// Punctuation at the end usually belongs to the sentence, not to the URL.
+		rawURL := strings.TrimRight(string(match), ".,;:!?)]}")
+		if u, err := url.Parse(rawURL); err == nil {
+			links = append(links, &Link{URL: u})
+		}
+	}
+
+	return links, nil
+}
+
+// XMLExtractor gets links out of sitemaps, RSS and Atom feeds and any other XML
+// document: the text of <loc> and <link> elements and href/src/url attributes.
+// Sitemap URLs keep their <priority>.
+type XMLExtractor struct{}
+
+// Extract is lenient with malformed XML, but returns the links found so far
+// with the error of a document it cannot read further.
+func (XMLExtractor) Extract(body io.Reader) ([]*Link, error) {
+	var links []*Link
+	var element string
+	var sitemapLink *Link
+
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		// The Crawler already decoded the document to UTF-8.
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return links, nil
+		}
+		if err != nil {
+			return links, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			element = strings.ToLower(token.Name.Local)
+			if element == "url" {
+				sitemapLink = nil
+			}
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Name.Local) {
+				case "href", "src", "url":
+					if u, err := url.Parse(strings.TrimSpace(attr.Value)); err == nil && attr.Value != "" {
+						links = append(links, &Link{URL: u})
+					}
+				}
+			}
+		case xml.EndElement:
+			element = ""
+		case xml.CharData:
+			text := strings.TrimSpace(string(token))
+			if element == "priority" && sitemapLink != nil {
+				if priority, err := strconv.ParseFloat(text, 64); err == nil && priority >= 0 && priority <= 1 {
+					sitemapLink.Priority = priority
+				}
+				continue
+			}
+			if (element != "loc" && element != "link") || text == "" {
+				continue
+			}
+			if u, err := url.Parse(text); err == nil {
+				links = append(links, &Link{URL: u})
+				if element == "loc" {
+					sitemapLink = links[len(links)-1]
+				}
+			}
+		}
+	}
+}
+
+// JSONExtractor collects the string values of a JSON document that look like
+// URLs: absolute HTTP(S) URLs and root-relative paths.
+type JSONExtractor struct{}
+
+// Extract fails on invalid JSON. The order of the links is the one of a walk
+// of the document, keys of objects coming in no particular order.
+func (JSONExtractor) Extract(body io.Reader) ([]*Link, error) {
+	var document interface{}
+	if err := json.NewDecoder(body).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var links []*Link
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			for _, v := range value {
+				walk(v)
+			}
+		case []interface{}:
+			for _, v := range value {
+				walk(v)
+			}
+		case string:
+			if !looksLikeURL(value) {
+				return
+			}
+			if u, err := url.Parse(value); err == nil {
+				links = append(links, &Link{URL: u})
+			}
+		}
+	}
+	walk(document)
+
+	return links, nil
+}
+
+func looksLikeURL(value string) bool {
+	if strings.ContainsAny(value, " \t\n\r") {
+		return false
+	}
+
+	return strings.HasPrefix(value, "http://") ||
+		strings.HasPrefix(value, "https://") ||
+		(strings.HasPrefix(value, "/") && len(value) > 1)
+}
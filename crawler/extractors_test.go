@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExtractorRegistry_Lookup_Success(t *testing.T) {
+	registry := NewDefaultExtractorRegistry()
+
+	extractor, ok := registry.Lookup("text/html; charset=utf-8")
+	assert.True(t, ok)
+	assert.IsType(t, HTMLExtractor{}, extractor)
+
+	extractor, ok = registry.Lookup("application/rss+xml")
+	assert.True(t, ok)
+	assert.IsType(t, FeedExtractor{}, extractor)
+
+	extractor, ok = registry.Lookup("application/vnd.custom+xml")
+	assert.True(t, ok)
+	assert.IsType(t, XMLExtractor{}, extractor)
+
+	extractor, ok = registry.Lookup("application/ld+json")
+	assert.True(t, ok)
+	assert.IsType(t, JSONExtractor{}, extractor)
+
+	_, ok = registry.Lookup("video/mp4")
+	assert.False(t, ok)
+
+	_, ok = registry.Lookup("")
+	assert.False(t, ok)
+}
+
+func TestExtractorRegistry_Register_Success(t *testing.T) {
+	registry := NewDefaultExtractorRegistry()
+	custom := ExtractorFunc(func(body io.Reader) ([]*Link, error) {
+		return nil, errors.New("custom")
+	})
+	registry.Register("Text/HTML", custom)
+	registry.Register("application/pdf", custom)
+
+	for _, contentType := range []string{"text/html", "application/pdf"} {
+		extractor, ok := registry.Lookup(contentType)
+		assert.True(t, ok)
+		_, err := extractor.Extract(strings.NewReader(""))
+		assert.EqualError(t, err, "custom")
+	}
+}
+
+func TestTextExtractor_Extract_Success(t *testing.T) {
+	links, err := TextExtractor{}.Extract(strings.NewReader(`
+		See https://abc.com/path-a, or (http://abc.com/path-b).
+		Not a link: ftp://abc.com/path-c and /path-d
+	`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://abc.com/path-a", "http://abc.com/path-b"}, urlStringsOf(links))
+}
+
+func TestXMLExtractor_Sitemap_Success(t *testing.T) {
+	links, err := XMLExtractor{}.Extract(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+		<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+			<url><loc>https://abc.com/path-a</loc><priority>0.8</priority></url>
+			<url><loc> https://abc.com/path-b </loc></url>
+		</urlset>`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://abc.com/path-a", "https://abc.com/path-b"}, urlStringsOf(links))
+	assert.Equal(t, 0.8, links[0].Priority)
+	assert.Equal(t, 0.0, links[1].Priority)
+}
+
+func TestXMLExtractor_Feeds_Success(t *testing.T) {
+	links, err := XMLExtractor{}.Extract(strings.NewReader(`<?xml version="1.0" encoding="ISO-8859-1"?>
+		<rss version="2.0"><channel>
+			<link>https://abc.com/</link>
+			<item><title>Post</title><link>https://abc.com/post-a</link></item>
+		</channel></rss>`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://abc.com/", "https://abc.com/post-a"}, urlStringsOf(links))
+
+	links, err = XMLExtractor{}.Extract(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom">
+			<link href="https://abc.com/"/>
+			<entry><title>Post</title><link rel="alternate" href="/post-b"/></entry>
+		</feed>`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://abc.com/", "/post-b"}, urlStringsOf(links))
+}
+
+func TestXMLExtractor_Malformed_Error(t *testing.T) {
+	links, err := XMLExtractor{}.Extract(strings.NewReader(`<urlset><url><loc>https://abc.com/path-a</loc></url><`))
+	assert.Error(t, err)
+	assert.Equal(t, []string{"https://abc.com/path-a"}, urlStringsOf(links))
+}
+
+func TestCSSExtractor_Extract_Success(t *testing.T) {
+	links, err := CSSExtractor{}.Extract(strings.NewReader(`
+		@import "/base.css";
+		.a { background-image: url( "/a.png" ); }
+		.b { background: url('/b.png') no-repeat; }
+		.c { background: url(/c.png); }
+	`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/base.css", "/a.png", "/b.png", "/c.png"}, urlStringsOf(links))
+}
+
+func TestJSONExtractor_Extract_Success(t *testing.T) {
+	links, err := JSONExtractor{}.Extract(strings.NewReader(`{
+		"self": "https://abc.com/api",
+		"items": [{"href": "/path-a", "title": "Path A"}, {"href": "/"}],
+		"description": "go to /path-b for more",
+		"count": 2
+	}`))
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"https://abc.com/api", "/path-a"}, urlStringsOf(links))
+}
+
+func TestJSONExtractor_Malformed_Error(t *testing.T) {
+	_, err := JSONExtractor{}.Extract(strings.NewReader(`{"self": `))
+	assert.Error(t, err)
+}
+
+func urlStringsOf(links []*Link) []string {
+	var urls []string
+	for _, link := range links {
+		urls = append(urls, link.URL.String())
+	}
+
+	return urls
+}
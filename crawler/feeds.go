@@ -0,0 +1,378 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStaleFeedAge is how old the last entry of a feed can be before the
+// FeedMonitor reports it as stale.
+const DefaultStaleFeedAge = 90 * 24 * time.Hour
+
+var feedContentTypes = []string{"application/rss+xml", "application/atom+xml"}
+
+// Feeds are often served as generic XML. Those types are only taken for feeds
+// when a page advertised the URL as one.
+var genericXMLContentTypes = []string{"application/xml", "text/xml"}
+
+// Date layouts found in the wild for RSS <pubDate> and Atom <published>/<updated>.
+var feedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// IsFeedContentType reports whether the media type in the Content-Type header
+// is the one of an RSS or Atom feed. Generic XML types are not.
+func IsFeedContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, feedContentType := range feedContentTypes {
+		if mediaType == feedContentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Feed is what ParseFeed gets out of an RSS or Atom document.
+type Feed struct {
+	Title   string
+	Entries []*FeedEntry
+}
+
+// FeedEntry is an item of an RSS feed or an entry of an Atom feed. Published
+// is zero when the date is missing or in an unknown layout.
+type FeedEntry struct {
+	Link      *url.URL
+	Title     string
+	Published time.Time
+}
+
+type rssDocument struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomDocument struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+var errUnknownFeedFormat = errors.New("document is neither an RSS 2.0 nor an Atom feed")
+
+// ParseFeed reads an RSS 2.0 or Atom feed. Entry links may be relative.
+func ParseFeed(body io.Reader) (*Feed, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElementOf(content)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss":
+		var document rssDocument
+		if err := newFeedDecoder(content).Decode(&document); err != nil {
+			return nil, err
+		}
+
+		feed := &Feed{Title: strings.TrimSpace(document.Channel.Title)}
+		for _, item := range document.Channel.Items {
+			feed.addEntry(item.Link, item.Title, item.PubDate)
+		}
+		return feed, nil
+	case "feed":
+		var document atomDocument
+		if err := newFeedDecoder(content).Decode(&document); err != nil {
+			return nil, err
+		}
+
+		feed := &Feed{Title: strings.TrimSpace(document.Title)}
+		for _, entry := range document.Entries {
+			var link string
+			for _, l := range entry.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+
+			published := entry.Published
+			if published == "" {
+				published = entry.Updated
+			}
+			feed.addEntry(link, entry.Title, published)
+		}
+		return feed, nil
+	}
+
+	return nil, errUnknownFeedFormat
+}
+
+func (f *Feed) addEntry(rawURL string, title string, published string) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return
+	}
+
+	link, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+
+	f.Entries = append(f.Entries, &FeedEntry{
+		Link:      link,
+		Title:     strings.TrimSpace(title),
+		Published: parseFeedDate(published),
+	})
+}
+
+func newFeedDecoder(content []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		// The Crawler already decoded the document to UTF-8.
+		return input, nil
+	}
+
+	return decoder
+}
+
+func rootElementOf(content []byte) (string, error) {
+	decoder := newFeedDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local, nil
+		}
+	}
+}
+
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// FeedExtractor gets the entry links out of RSS 2.0 and Atom feeds, using the
+// entry titles as the link text.
+type FeedExtractor struct{}
+
+// Extract fails on documents that are neither RSS nor Atom.
+func (FeedExtractor) Extract(body io.Reader) ([]*Link, error) {
+	feed, err := ParseFeed(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed.links(), nil
+}
+
+func (f *Feed) links() []*Link {
+	var links []*Link
+	for _, entry := range f.Entries {
+		links = append(links, &Link{URL: entry.Link, Text: entry.Title})
+	}
+
+	return links
+}
+
+// FeedHealth is what the FeedMonitor knows about a feed at the end of a crawl.
+type FeedHealth struct {
+	FeedURL       *url.URL
+	Title         string
+	Entries       int
+	LastPublished time.Time
+	ParseError    error
+	Stale         bool
+	BrokenEntries []*url.URL
+}
+
+// FeedMonitor keeps track of the feeds found during a crawl, so it can report
+// feeds that can't be parsed, feeds that haven't been updated for a while and
+// entries that point at pages that are broken (status code 4XX or 5XX).
+type FeedMonitor struct {
+	staleAfter   time.Duration
+	now          func() time.Time
+	feeds        map[string]*FeedHealth
+	entryFeeds   map[string][]*FeedHealth
+	brokenPages  map[string]*url.URL
+	reportedURLs map[string]map[string]bool
+	advertised   map[string]bool
+	m            sync.Mutex
+}
+
+// NewFeedMonitor reports feeds whose last entry is older than staleAfter,
+// DefaultStaleFeedAge if zero.
+func NewFeedMonitor(staleAfter time.Duration) *FeedMonitor {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleFeedAge
+	}
+
+	return &FeedMonitor{
+		staleAfter:   staleAfter,
+		now:          time.Now,
+		feeds:        make(map[string]*FeedHealth),
+		entryFeeds:   make(map[string][]*FeedHealth),
+		brokenPages:  make(map[string]*url.URL),
+		reportedURLs: make(map[string]map[string]bool),
+		advertised:   make(map[string]bool),
+	}
+}
+
+// advertiseFeed records a URL that a page advertises as a feed.
+func (f *FeedMonitor) advertiseFeed(feedURL *url.URL) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.advertised[PageKey(feedURL)] = true
+}
+
+// isFeed tells whether a response is a feed: it is served as one, or as
+// generic XML from a URL advertised as a feed. It is false without a monitor,
+// as feeds are not followed then.
+func (f *FeedMonitor) isFeed(targetURL *url.URL, contentType string) bool {
+	if f == nil {
+		return false
+	}
+	if IsFeedContentType(contentType) {
+		return true
+	}
+	if contentType == "" || !IsAllowedContentType(contentType, genericXMLContentTypes) {
+		return false
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.advertised[PageKey(targetURL)]
+}
+
+// ObserveFeed parses a fetched feed and starts watching its entries.
+func (f *FeedMonitor) ObserveFeed(feedURL *url.URL, body io.Reader) {
+	feed, err := ParseFeed(body)
+	f.observeFeed(feedURL, feed, err)
+}
+
+// observeFeed starts watching the entries of a feed parsed by the caller, err
+// being the error it failed to parse with.
+func (f *FeedMonitor) observeFeed(feedURL *url.URL, feed *Feed, err error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	health := &FeedHealth{FeedURL: feedURL, ParseError: err}
+	f.feeds[feedURL.String()] = health
+	if err != nil {
+		return
+	}
+
+	health.Title = feed.Title
+	health.Entries = len(feed.Entries)
+	for _, entry := range feed.Entries {
+		if entry.Published.After(health.LastPublished) {
+			health.LastPublished = entry.Published
+		}
+
+		entryURL := feedURL.ResolveReference(entry.Link)
+		key := PageKey(entryURL)
+		f.entryFeeds[key] = append(f.entryFeeds[key], health)
+		if brokenURL, ok := f.brokenPages[key]; ok {
+			f.addBrokenEntry(health, brokenURL)
+		}
+	}
+	health.Stale = !health.LastPublished.IsZero() && f.now().Sub(health.LastPublished) > f.staleAfter
+}
+
+// ObservePage takes the result of every page, as feed entries may be crawled
+// before or after the feed that lists them.
+func (f *FeedMonitor) ObservePage(linksForTargetURL *LinksByTargetURL) {
+	if linksForTargetURL.StatusCode < 400 {
+		return
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	key := PageKey(linksForTargetURL.TargetURL)
+	f.brokenPages[key] = linksForTargetURL.TargetURL
+	for _, health := range f.entryFeeds[key] {
+		f.addBrokenEntry(health, linksForTargetURL.TargetURL)
+	}
+}
+
+func (f *FeedMonitor) addBrokenEntry(health *FeedHealth, entryURL *url.URL) {
+	feedKey := health.FeedURL.String()
+	if f.reportedURLs[feedKey] == nil {
+		f.reportedURLs[feedKey] = make(map[string]bool)
+	}
+	if f.reportedURLs[feedKey][entryURL.String()] {
+		return
+	}
+
+	f.reportedURLs[feedKey][entryURL.String()] = true
+	health.BrokenEntries = append(health.BrokenEntries, entryURL)
+}
+
+// Report returns the health of every feed observed, sorted by feed URL.
+func (f *FeedMonitor) Report() []*FeedHealth {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	var report []*FeedHealth
+	for _, health := range f.feeds {
+		report = append(report, health)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].FeedURL.String() < report[j].FeedURL.String()
+	})
+
+	return report
+}
+
+// Healthy reports whether the feed parsed, is not stale and has no broken
+// entries.
+func (h *FeedHealth) Healthy() bool {
+	return h.ParseError == nil && !h.Stale && len(h.BrokenEntries) == 0
+}
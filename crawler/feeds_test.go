@@ -0,0 +1,225 @@
+package crawler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+	<channel>
+		<title>ABC Blog</title>
+		<link>https://abc.com/blog</link>
+		<item>
+			<title>Post A</title>
+			<link>https://abc.com/blog/post-a</link>
+			<pubDate>Mon, 02 Jan 2023 15:04:05 +0000</pubDate>
+		</item>
+		<item>
+			<title>Post B</title>
+			<link>/blog/post-b</link>
+			<pubDate>Tue, 3 Jan 2023 15:04:05 GMT</pubDate>
+		</item>
+	</channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>ABC News</title>
+	<link href="https://abc.com/news"/>
+	<entry>
+		<title>Story A</title>
+		<link rel="edit" href="https://abc.com/api/story-a"/>
+		<link href="https://abc.com/news/story-a"/>
+		<published>2023-01-02T15:04:05Z</published>
+	</entry>
+	<entry>
+		<title>Story B</title>
+		<link rel="alternate" href="https://abc.com/news/story-b"/>
+		<updated>2023-01-04T15:04:05Z</updated>
+	</entry>
+</feed>`
+
+func TestParseFeed_RSS_Success(t *testing.T) {
+	feed, err := ParseFeed(strings.NewReader(rssFeed))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ABC Blog", feed.Title)
+	assert.Len(t, feed.Entries, 2)
+	assert.Equal(t, "https://abc.com/blog/post-a", feed.Entries[0].Link.String())
+	assert.Equal(t, "Post A", feed.Entries[0].Title)
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), feed.Entries[0].Published.UTC())
+	assert.Equal(t, "/blog/post-b", feed.Entries[1].Link.String())
+	assert.Equal(t, time.Date(2023, 1, 3, 15, 4, 5, 0, time.UTC), feed.Entries[1].Published.UTC())
+}
+
+func TestParseFeed_Atom_Success(t *testing.T) {
+	feed, err := ParseFeed(strings.NewReader(atomFeed))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ABC News", feed.Title)
+	assert.Len(t, feed.Entries, 2)
+	assert.Equal(t, "https://abc.com/news/story-a", feed.Entries[0].Link.String())
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), feed.Entries[0].Published)
+	assert.Equal(t, "https://abc.com/news/story-b", feed.Entries[1].Link.String())
+	assert.Equal(t, time.Date(2023, 1, 4, 15, 4, 5, 0, time.UTC), feed.Entries[1].Published)
+}
+
+func TestParseFeed_Invalid_Error(t *testing.T) {
+	_, err := ParseFeed(strings.NewReader(`<html><body></body></html>`))
+	assert.ErrorIs(t, err, errUnknownFeedFormat)
+
+	_, err = ParseFeed(strings.NewReader(`<rss><channel><item>`))
+	assert.Error(t, err)
+}
+
+func TestHTMLExtractor_FeedDiscovery_Success(t *testing.T) {
+	links, err := HTMLExtractor{}.Extract(strings.NewReader(`
+		<link rel="alternate" type="application/rss+xml" href="/blog/rss.xml">
+		<link rel="alternate" type="application/atom+xml" href="/news/atom.xml">
+		<link rel="alternate" hreflang="pt" href="/pt">`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/blog/rss.xml", "/news/atom.xml"}, urlStringsOf(links))
+	assert.Equal(t, "application/rss+xml", links[0].MediaType)
+}
+
+func TestFeedMonitor_Report_Success(t *testing.T) {
+	monitor := NewFeedMonitor(30 * 24 * time.Hour)
+	monitor.now = func() time.Time { return time.Date(2023, 1, 20, 0, 0, 0, 0, time.UTC) }
+
+	// Entries can be crawled before or after the feed that lists them.
+	monitor.ObservePage(&LinksByTargetURL{TargetURL: makeURLFor(t, "https://abc.com/blog/post-a"), StatusCode: http.StatusNotFound})
+	monitor.ObserveFeed(makeURLFor(t, "https://abc.com/blog/rss.xml"), strings.NewReader(rssFeed))
+	monitor.ObservePage(&LinksByTargetURL{TargetURL: makeURLFor(t, "https://abc.com/blog/post-b"), StatusCode: http.StatusOK})
+	monitor.ObserveFeed(makeURLFor(t, "https://abc.com/news/atom.xml"), strings.NewReader(atomFeed))
+	monitor.ObservePage(&LinksByTargetURL{TargetURL: makeURLFor(t, "https://abc.com/news/story-b"), StatusCode: http.StatusGone})
+	monitor.ObserveFeed(makeURLFor(t, "https://abc.com/old/rss.xml"), strings.NewReader(`<rss><channel>`))
+
+	report := monitor.Report()
+	assert.Len(t, report, 3)
+
+	assert.Equal(t, "https://abc.com/blog/rss.xml", report[0].FeedURL.String())
+	assert.Equal(t, 2, report[0].Entries)
+	assert.False(t, report[0].Stale)
+	assert.Equal(t, []string{"https://abc.com/blog/post-a"}, urlsToStrings(report[0].BrokenEntries))
+	assert.False(t, report[0].Healthy())
+
+	assert.Equal(t, "https://abc.com/news/atom.xml", report[1].FeedURL.String())
+	assert.Equal(t, []string{"https://abc.com/news/story-b"}, urlsToStrings(report[1].BrokenEntries))
+
+	assert.Equal(t, "https://abc.com/old/rss.xml", report[2].FeedURL.String())
+	assert.Error(t, report[2].ParseError)
+
+	monitor.now = func() time.Time { return time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC) }
+	monitor.ObserveFeed(makeURLFor(t, "https://abc.com/blog/rss.xml"), strings.NewReader(rssFeed))
+	assert.True(t, monitor.Report()[0].Stale)
+}
+
+func TestCrawler_GetAllLinksFor_FollowFeeds(t *testing.T) {
+	pages := map[string]*MemoryPage{
+		"https://abc.com":              NewMemoryPage(http.StatusOK, "text/html", `<link rel="alternate" type="application/rss+xml" href="/blog/rss.xml">`),
+		"https://abc.com/blog/rss.xml": NewMemoryPage(http.StatusOK, "application/rss+xml", rssFeed),
+		"https://abc.com/blog/post-a":  NewMemoryPage(http.StatusOK, "text/html", ""),
+	}
+
+	var processed []string
+	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
+		processed = append(processed, linksForTargetURL.TargetURL.String())
+	}
+
+	monitor := NewFeedMonitor(0)
+	crawler := New(WithFetcher(NewMemoryFetcher(pages)), WithWorkers(10), WithRetryAttempts(1), WithFeedMonitor(monitor))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), onTargetURLProcessed, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.ElementsMatch(t, []string{
+		"https://abc.com",
+		"https://abc.com/blog/rss.xml",
+		"https://abc.com/blog/post-a",
+		"https://abc.com/blog/post-b",
+	}, processed)
+
+	report := monitor.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "ABC Blog", report[0].Title)
+	assert.Equal(t, []string{"https://abc.com/blog/post-b"}, urlsToStrings(report[0].BrokenEntries))
+
+	// Without a monitor, feeds are not followed.
+	processed = nil
+	crawler = New(WithFetcher(NewMemoryFetcher(pages)), WithWorkers(10), WithRetryAttempts(1))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), onTargetURLProcessed, func(err error) {
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, []string{"https://abc.com"}, processed)
+}
+
+func TestCrawler_GetAllLinksFor_FeedEntryServerError(t *testing.T) {
+	pages := map[string]*MemoryPage{
+		"https://abc.com":              NewMemoryPage(http.StatusOK, "text/html", `<link rel="alternate" type="application/rss+xml" href="/blog/rss.xml">`),
+		"https://abc.com/blog/rss.xml": NewMemoryPage(http.StatusOK, "application/rss+xml", rssFeed),
+		"https://abc.com/blog/post-a":  NewMemoryPage(http.StatusServiceUnavailable, "text/html", ""),
+		"https://abc.com/blog/post-b":  NewMemoryPage(http.StatusOK, "text/html", ""),
+	}
+
+	processed := map[string]*LinksByTargetURL{}
+	monitor := NewFeedMonitor(0)
+	crawler := New(WithFetcher(NewMemoryFetcher(pages)), WithWorkers(10), WithRetryAttempts(2), WithFeedMonitor(monitor))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(linksForTargetURL *LinksByTargetURL) {
+		processed[linksForTargetURL.TargetURL.String()] = linksForTargetURL
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, http.StatusServiceUnavailable, processed["https://abc.com/blog/post-a"].StatusCode)
+	assert.ErrorIs(t, processed["https://abc.com/blog/post-a"].Err, ErrorKindHTTPStatus)
+
+	report := monitor.Report()
+	assert.Len(t, report, 1)
+	assert.Len(t, report[0].BrokenEntries, 1)
+	assert.Equal(t, "https://abc.com/blog/post-a", report[0].BrokenEntries[0].String())
+}
+
+func TestCrawler_GetAllLinksFor_FeedServedAsXML(t *testing.T) {
+	pages := map[string]*MemoryPage{
+		"https://abc.com":              NewMemoryPage(http.StatusOK, "text/html", `<link rel="alternate" type="application/rss+xml" href="/blog/rss.xml"><a href="/data.xml">data</a>`),
+		"https://abc.com/blog/rss.xml": NewMemoryPage(http.StatusOK, "text/xml; charset=utf-8", rssFeed),
+		"https://abc.com/blog/post-a":  NewMemoryPage(http.StatusOK, "text/html", ""),
+		"https://abc.com/data.xml":     NewMemoryPage(http.StatusOK, "application/xml", rssFeed),
+	}
+
+	processed := map[string]*LinksByTargetURL{}
+	monitor := NewFeedMonitor(0)
+	crawler := New(WithFetcher(NewMemoryFetcher(pages)), WithWorkers(10), WithRetryAttempts(1), WithFeedMonitor(monitor))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(linksForTargetURL *LinksByTargetURL) {
+		processed[linksForTargetURL.TargetURL.String()] = linksForTargetURL
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.False(t, processed["https://abc.com/blog/rss.xml"].Skipped())
+	assert.Contains(t, processed, "https://abc.com/blog/post-a")
+
+	// Generic XML that was not advertised as a feed is not taken for one.
+	assert.True(t, processed["https://abc.com/data.xml"].Skipped())
+
+	report := monitor.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "https://abc.com/blog/rss.xml", report[0].FeedURL.String())
+	assert.Equal(t, "ABC Blog", report[0].Title)
+}
+
+func urlsToStrings(urls []*url.URL) []string {
+	var strs []string
+	for _, u := range urls {
+		strs = append(strs, u.String())
+	}
+
+	return strs
+}
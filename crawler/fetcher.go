@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// FetchRequest is a request for a page, made by the Crawler to its Fetcher.
+type FetchRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+}
+
+// NewFetchRequest returns a request without headers, ready for middlewares to
+// add some.
+func NewFetchRequest(method string, targetURL *url.URL) *FetchRequest {
+	return &FetchRequest{Method: method, URL: targetURL, Header: make(http.Header)}
+}
+
+// FetchResponse is what a Fetcher returns for a request. The caller is
+// responsible for closing Body. Duration is the time it took to get the
+// response headers back. RedirectedTo is the URL the response came from when
+// the request was redirected.
+type FetchResponse struct {
+	StatusCode    int
+	Header        http.Header
+	Body          io.ReadCloser
+	ContentLength int64
+	Duration      time.Duration
+	RedirectedTo  *url.URL
+}
+
+// Fetcher is how the Crawler gets pages. Implementations must be safe for
+// concurrent use, as every worker shares the same Fetcher.
+type Fetcher interface {
+	Fetch(ctx context.Context, request *FetchRequest) (*FetchResponse, error)
+}
+
+// FetcherFunc makes a Fetcher of a function.
+type FetcherFunc func(ctx context.Context, request *FetchRequest) (*FetchResponse, error)
+
+// Fetch calls f.
+func (f FetcherFunc) Fetch(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+	return f(ctx, request)
+}
+
+// FetcherMiddleware wraps a Fetcher to add behaviour around it, e.g. logging,
+// caching or injecting headers.
+type FetcherMiddleware func(Fetcher) Fetcher
+
+// ChainFetcher wraps fetcher with the middlewares. The first middleware is the
+// outermost one, so it is the first to see the request.
+func ChainFetcher(fetcher Fetcher, middlewares ...FetcherMiddleware) Fetcher {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fetcher = middlewares[i](fetcher)
+	}
+
+	return fetcher
+}
+
+// HTTPFetcher is the Fetcher that goes over the network, following redirects
+// as its http.Client does.
+type HTTPFetcher struct {
+	httpClient *http.Client
+}
+
+// NewHTTPFetcher sends the requests with httpClient.
+func NewHTTPFetcher(httpClient *http.Client) *HTTPFetcher {
+	return &HTTPFetcher{httpClient: httpClient}
+}
+
+// Fetch sends the request, the context carrying its deadline and the hooks of
+// an httptrace.ClientTrace if any. Any status code is a response.
+func (f *HTTPFetcher) Fetch(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, request.Method, request.URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range request.Header {
+		httpRequest.Header[key] = values
+	}
+
+	start := time.Now()
+	response, err := f.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchResponse := &FetchResponse{
+		StatusCode:    response.StatusCode,
+		Header:        response.Header,
+		Body:          response.Body,
+		ContentLength: response.ContentLength,
+		Duration:      time.Since(start),
+	}
+	if finalURL := response.Request.URL; finalURL.String() != request.URL.String() {
+		fetchResponse.RedirectedTo = finalURL
+	}
+
+	return fetchResponse, nil
+}
+
+// WithHeaders sets the given headers on every request, unless the request
+// already has a value for them.
+func WithHeaders(header http.Header) FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+			for key, values := range header {
+				if request.Header.Get(key) == "" {
+					request.Header[http.CanonicalHeaderKey(key)] = values
+				}
+			}
+
+			return next.Fetch(ctx, request)
+		})
+	}
+}
+
+// WithLogging logs every request, at the info level when it got a response
+// and at the warn level otherwise.
+func WithLogging(logger *slog.Logger) FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+			response, err := next.Fetch(ctx, request)
+			if err != nil {
+				logger.Warn("fetch failed", "method", request.Method, "url", request.URL.String(), "error", err)
+				return nil, err
+			}
+
+			logger.Info("fetch", "method", request.Method, "url", request.URL.String(), "status", response.StatusCode, "duration", response.Duration)
+			return response, nil
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPFetcher_Fetch_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("X-Echo", r.Header.Get("X-Custom"))
+		w.WriteHeader(http.StatusTeapot)
+		_, err := w.Write([]byte("<p>abc</p>"))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	request := NewFetchRequest(http.MethodGet, makeURLFor(t, server.URL))
+	request.Header.Set("X-Custom", "value")
+
+	response, err := NewHTTPFetcher(http.DefaultClient).Fetch(context.Background(), request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, response.StatusCode)
+	assert.Equal(t, "text/html", response.Header.Get("Content-Type"))
+	assert.Equal(t, "value", response.Header.Get("X-Echo"))
+	assert.Equal(t, "<p>abc</p>", string(body))
+	assert.Positive(t, response.Duration)
+}
+
+func TestHTTPFetcher_Fetch_Redirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(http.DefaultClient)
+	response, err := fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, server.URL+"/old")))
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, server.URL+"/new", response.RedirectedTo.String())
+
+	response, err = fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, server.URL+"/new")))
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Nil(t, response.RedirectedTo)
+}
+
+func TestMemoryFetcher_Fetch_Success(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com/path-a": NewMemoryPage(http.StatusOK, "text/html", "<p>abc</p>"),
+	})
+
+	response, err := fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com/path-a")))
+	assert.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "<p>abc</p>", string(body))
+
+	response, err = fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodHead, makeURLFor(t, "https://abc.com/path-a")))
+	assert.NoError(t, err)
+	body, err = io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Empty(t, body)
+	assert.Equal(t, int64(10), response.ContentLength)
+
+	response, err = fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com/path-b")))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Len(t, fetcher.Requests(), 3)
+}
+
+func TestChainFetcher_Success(t *testing.T) {
+	var calls []string
+	middleware := func(name string) FetcherMiddleware {
+		return func(next Fetcher) Fetcher {
+			return FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+				calls = append(calls, name)
+				return next.Fetch(ctx, request)
+			})
+		}
+	}
+
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{})
+	chain := ChainFetcher(fetcher, middleware("a"), middleware("b"), WithHeaders(http.Header{"User-Agent": {"crawler"}}))
+
+	_, err := chain.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com")))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, calls)
+	assert.Equal(t, "crawler", fetcher.Requests()[0].Header.Get("User-Agent"))
+}
+
+func TestWithLogging_Success(t *testing.T) {
+	var output bytes.Buffer
+	fetcher := ChainFetcher(NewMemoryFetcher(map[string]*MemoryPage{}), WithLogging(newTestLogger(&output)))
+
+	_, err := fetcher.Fetch(context.Background(), NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com")))
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "level=INFO msg=fetch method=GET url=https://abc.com status=404")
+}
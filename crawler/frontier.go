@@ -0,0 +1,239 @@
+package crawler
+
+import (
+	"container/heap"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Sitemaps give every URL a priority between 0 and 1, 0.5 when none is set.
+const defaultSitemapPriority = 0.5
+
+// The best-first strategy counts the sitemap priority of a URL as this many
+// in-links, so that 1.0 beats 0.5 by a few links.
+const sitemapPriorityWeight = 10
+
+// Scorer ranks the URLs waiting to be crawled, the higher the score the sooner
+// a URL is crawled. URLs with the same score are crawled in the order they were
+// found. Scores are computed again whenever a URL gets a new in-link.
+type Scorer func(task *Task) float64
+
+// StrategyNames are the crawl strategies NewStrategyScorer accepts.
+var StrategyNames = []string{"bfs", "dfs", "best-first"}
+
+// BFSScorer crawls the pages closest to the target URL first.
+func BFSScorer(task *Task) float64 {
+	return -float64(task.Depth)
+}
+
+// DFSScorer follows links as deep as they go before going back up.
+func DFSScorer(task *Task) float64 {
+	return float64(task.Depth)
+}
+
+// InLinkScorer crawls the pages with the most links pointing at them first,
+// counting the links found so far.
+func InLinkScorer(task *Task) float64 {
+	return float64(task.InLinks)
+}
+
+// SitemapPriorityScorer crawls the pages with the highest sitemap priority first.
+func SitemapPriorityScorer(task *Task) float64 {
+	if task.SitemapPriority == 0 {
+		return defaultSitemapPriority
+	}
+
+	return task.SitemapPriority
+}
+
+// PatternWeightScorer adds up the weights of the patterns matching the URL
+// pattern (see URLPatternOf) of a page. Patterns are path.Match globs, e.g.
+// "*/blog/{n}", and patterns ending with "/**" match every URL under them.
+func PatternWeightScorer(weights map[string]float64) Scorer {
+	return func(task *Task) float64 {
+		pattern := URLPatternOf(task.TargetURL)
+
+		var score float64
+		for weightPattern, weight := range weights {
+			if matchesWeightPattern(weightPattern, pattern) {
+				score += weight
+			}
+		}
+
+		return score
+	}
+}
+
+func matchesWeightPattern(weightPattern string, pattern string) bool {
+	if prefix, ok := strings.CutSuffix(weightPattern, "**"); ok {
+		return strings.HasPrefix(pattern+"/", prefix)
+	}
+
+	matched, _ := path.Match(weightPattern, pattern)
+	return matched
+}
+
+// CombineScorers adds up the scores of several scorers.
+func CombineScorers(scorers ...Scorer) Scorer {
+	return func(task *Task) float64 {
+		var score float64
+		for _, scorer := range scorers {
+			score += scorer(task)
+		}
+
+		return score
+	}
+}
+
+// NewStrategyScorer returns the Scorer of a crawl strategy. best-first ranks
+// pages by in-links, sitemap priority and pattern weights, preferring
+// shallow pages.
+func NewStrategyScorer(strategy string, patternWeights map[string]float64) (Scorer, error) {
+	switch strategy {
+	case "bfs":
+		return BFSScorer, nil
+	case "dfs":
+		return DFSScorer, nil
+	case "best-first":
+		return CombineScorers(
+			InLinkScorer,
+			func(task *Task) float64 { return sitemapPriorityWeight * SitemapPriorityScorer(task) },
+			PatternWeightScorer(patternWeights),
+			BFSScorer,
+		), nil
+	}
+
+	return nil, fmt.Errorf("unknown strategy %q, expected one of %v", strategy, StrategyNames)
+}
+
+// Frontier holds the pages waiting to be crawled, ordered by the score given
+// by its Scorer. Once maxPages pages have been popped, it acts as if it were
+// empty (0 means no limit).
+type Frontier struct {
+	scorer   Scorer
+	maxPages int
+	queue    frontierQueue
+	queued   map[string]*frontierItem
+	sequence int
+	popped   int
+	m        sync.Mutex
+}
+
+type frontierItem struct {
+	task     *Task
+	score    float64
+	sequence int
+	index    int
+}
+
+// NewFrontier orders pages with scorer, BFSScorer if nil, and pops at most
+// maxPages of them, no limit if zero.
+func NewFrontier(scorer Scorer, maxPages int) *Frontier {
+	if scorer == nil {
+		scorer = BFSScorer
+	}
+
+	return &Frontier{scorer: scorer, maxPages: maxPages, queued: make(map[string]*frontierItem)}
+}
+
+// Push queues a page, scoring it once. Ties are broken in the order pages
+// were pushed.
+func (f *Frontier) Push(task *Task) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	item := &frontierItem{task: task, score: f.scorer(task), sequence: f.sequence}
+	f.sequence++
+	heap.Push(&f.queue, item)
+	f.queued[PageKey(task.TargetURL)] = item
+}
+
+// AddInLink records a new link to a page found before. Pages still waiting
+// to be crawled are scored again.
+func (f *Frontier) AddInLink(targetURL *url.URL) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	item, ok := f.queued[PageKey(targetURL)]
+	if !ok {
+		return
+	}
+
+	item.task.InLinks++
+	item.score = f.scorer(item.task)
+	heap.Fix(&f.queue, item.index)
+}
+
+// Pop returns the page with the highest score, or false when there is none
+// left or the page budget is spent.
+func (f *Frontier) Pop() (*Task, bool) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.queue.Len() == 0 || (f.maxPages > 0 && f.popped >= f.maxPages) {
+		return nil, false
+	}
+
+	item := heap.Pop(&f.queue).(*frontierItem)
+	delete(f.queued, PageKey(item.task.TargetURL))
+	f.popped++
+
+	return item.task, true
+}
+
+// Reset drops the pages waiting to be crawled and gives the page budget back.
+func (f *Frontier) Reset() {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.queue = nil
+	f.queued = make(map[string]*frontierItem)
+	f.popped = 0
+}
+
+// Len returns the number of pages waiting to be crawled.
+func (f *Frontier) Len() int {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.queue.Len()
+}
+
+// frontierQueue implements heap.Interface, highest score first.
+type frontierQueue []*frontierItem
+
+func (q frontierQueue) Len() int {
+	return len(q)
+}
+
+func (q frontierQueue) Less(i, j int) bool {
+	if q[i].score != q[j].score {
+		return q[i].score > q[j].score
+	}
+
+	return q[i].sequence < q[j].sequence
+}
+
+func (q frontierQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *frontierQueue) Push(x interface{}) {
+	item := x.(*frontierItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *frontierQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+
+	return item
+}
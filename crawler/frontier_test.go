@@ -0,0 +1,151 @@
+package crawler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func popAll(frontier *Frontier) []string {
+	var urls []string
+	for {
+		task, ok := frontier.Pop()
+		if !ok {
+			return urls
+		}
+		urls = append(urls, task.TargetURL.String())
+	}
+}
+
+func TestFrontier_BFS(t *testing.T) {
+	frontier := NewFrontier(BFSScorer, 0)
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/a/b"), Depth: 2})
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/a"), Depth: 1})
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/c"), Depth: 1})
+
+	assert.Equal(t, 3, frontier.Len())
+	assert.Equal(t, []string{"https://abc.com/a", "https://abc.com/c", "https://abc.com/a/b"}, popAll(frontier))
+	assert.Equal(t, 0, frontier.Len())
+}
+
+func TestFrontier_DFS(t *testing.T) {
+	frontier := NewFrontier(DFSScorer, 0)
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/a"), Depth: 1})
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/a/b/c"), Depth: 3})
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/a/b"), Depth: 2})
+
+	assert.Equal(t, []string{"https://abc.com/a/b/c", "https://abc.com/a/b", "https://abc.com/a"}, popAll(frontier))
+}
+
+func TestFrontier_AddInLink(t *testing.T) {
+	frontier := NewFrontier(InLinkScorer, 0)
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/a"), InLinks: 1})
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/b"), InLinks: 1})
+	frontier.Push(&Task{TargetURL: makeURLFor(t, "https://abc.com/c"), InLinks: 2})
+
+	frontier.AddInLink(makeURLFor(t, "https://abc.com/b"))
+	frontier.AddInLink(makeURLFor(t, "http://abc.com/b?ref=footer"))
+	frontier.AddInLink(makeURLFor(t, "https://abc.com/unknown"))
+
+	assert.Equal(t, []string{"https://abc.com/b", "https://abc.com/c", "https://abc.com/a"}, popAll(frontier))
+}
+
+func TestFrontier_MaxPages(t *testing.T) {
+	frontier := NewFrontier(nil, 2)
+	for _, rawURL := range []string{"https://abc.com/a", "https://abc.com/b", "https://abc.com/c"} {
+		frontier.Push(&Task{TargetURL: makeURLFor(t, rawURL)})
+	}
+
+	assert.Equal(t, []string{"https://abc.com/a", "https://abc.com/b"}, popAll(frontier))
+	assert.Equal(t, 1, frontier.Len())
+}
+
+func TestScorers_Success(t *testing.T) {
+	task := &Task{TargetURL: makeURLFor(t, "https://abc.com/blog/2023/post"), Depth: 2, InLinks: 3}
+
+	assert.Equal(t, 0.5, SitemapPriorityScorer(task))
+	assert.Equal(t, 0.9, SitemapPriorityScorer(&Task{SitemapPriority: 0.9}))
+
+	weights := PatternWeightScorer(map[string]float64{
+		"abc.com/blog/**":     5,
+		"*/blog/{n}/*":        2,
+		"abc.com/shop/**":     -5,
+		"abc.com/blog/{n}/*/": 100,
+	})
+	assert.Equal(t, 7.0, weights(task))
+
+	assert.Equal(t, 3.0+5+7-2, CombineScorers(InLinkScorer, func(*Task) float64 { return 5 }, weights, BFSScorer)(task))
+}
+
+func TestNewStrategyScorer_Success(t *testing.T) {
+	task := &Task{TargetURL: makeURLFor(t, "https://abc.com/blog/post"), Depth: 2, InLinks: 3, SitemapPriority: 0.8}
+
+	for strategy, score := range map[string]float64{"bfs": -2, "dfs": 2, "best-first": 3 + 8 + 1 - 2} {
+		scorer, err := NewStrategyScorer(strategy, map[string]float64{"abc.com/blog/**": 1})
+		assert.NoError(t, err)
+		assert.Equal(t, score, scorer(task), strategy)
+	}
+}
+
+func TestNewStrategyScorer_Error(t *testing.T) {
+	_, err := NewStrategyScorer("random", nil)
+	assert.EqualError(t, err, `unknown strategy "random", expected one of [bfs dfs best-first]`)
+}
+
+func TestCrawler_GetAllLinksFor_Strategies(t *testing.T) {
+	newFetcher := func() Fetcher {
+		return NewMemoryFetcher(map[string]*MemoryPage{
+			"https://abc.com":   NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/b">B</a><a href="/sitemap.xml">Sitemap</a>`),
+			"https://abc.com/a": NewMemoryPage(http.StatusOK, "text/html", `<a href="/c">C</a><a href="/d">D</a>`),
+			"https://abc.com/b": NewMemoryPage(http.StatusOK, "text/html", `<a href="/c">C</a>`),
+			"https://abc.com/sitemap.xml": NewMemoryPage(http.StatusOK, "application/xml", `<urlset>
+				<url><loc>https://abc.com/e</loc><priority>1.0</priority></url>
+			</urlset>`),
+		})
+	}
+
+	tests := map[string][]string{
+		"bfs":        {"/", "/a", "/b", "/sitemap.xml", "/c", "/d", "/e"},
+		"dfs":        {"/", "/a", "/c", "/d", "/b", "/sitemap.xml", "/e"},
+		"best-first": {"/", "/a", "/b", "/sitemap.xml", "/e", "/c", "/d"},
+	}
+	for strategy, expectedPaths := range tests {
+		scorer, err := NewStrategyScorer(strategy, nil)
+		assert.NoError(t, err)
+
+		var paths []string
+		crawler := New(
+			WithFetcher(newFetcher()),
+			WithWorkers(1),
+			WithRetryAttempts(1),
+			WithAllowedContentTypes("text/html", "application/xml"),
+			WithScorer(scorer),
+		)
+		crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(linksForTargetURL *LinksByTargetURL) {
+			paths = append(paths, "/"+linksForTargetURL.TargetURL.Path[min(1, len(linksForTargetURL.TargetURL.Path)):])
+		}, func(err error) {
+			assert.NoError(t, err)
+		})
+
+		assert.Equal(t, expectedPaths, paths, strategy)
+	}
+}
+
+func TestCrawler_GetAllLinksFor_MaxPages(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":   NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/b">B</a>`),
+		"https://abc.com/a": NewMemoryPage(http.StatusOK, "text/html", `<a href="/c">C</a>`),
+	})
+
+	var processed int
+	crawler := New(WithFetcher(fetcher), WithWorkers(1), WithRetryAttempts(1), WithMaxPages(2))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(*LinksByTargetURL) {
+		processed++
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, 2, processed)
+	assert.Len(t, fetcher.Requests(), 2)
+}
@@ -0,0 +1,277 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GraphNode is a page of the site graph. Pages that are linked to but were
+// never processed (e.g. the request failed) have a depth of -1 and no status.
+// Like the crawler, the graph tells pages apart by host and path (see PageKey),
+// so links that only differ by query or fragment lead to the same node, whose
+// URL is their PageURL.
+type GraphNode struct {
+	URL         string
+	StatusCode  int
+	Depth       int
+	ContentType string
+}
+
+// GraphEdge aggregates the Count links from one page to another, From and To
+// being the URLs of their nodes. AnchorText and Rel come from the first link
+// that had them.
+type GraphEdge struct {
+	From       string
+	To         string
+	AnchorText string
+	Rel        string
+	Count      int
+}
+
+// Graph is the link graph of a crawl, built by calling AddPage with every
+// crawled page. It is safe for concurrent use. Nodes and edges are keyed by
+// PageKey.
+type Graph struct {
+	nodes map[string]*GraphNode
+	edges map[[2]string]*GraphEdge
+	m     sync.Mutex
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{nodes: make(map[string]*GraphNode), edges: make(map[[2]string]*GraphEdge)}
+}
+
+// AddPage adds a crawled page and an edge for each of its out-links, creating
+// the nodes of the pages it links to if needed.
+func (g *Graph) AddPage(linksForTargetURL *LinksByTargetURL) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	from, node := g.nodeFor(linksForTargetURL.TargetURL)
+	node.StatusCode = linksForTargetURL.StatusCode
+	node.Depth = linksForTargetURL.Depth
+	node.ContentType = linksForTargetURL.ContentType
+
+	for _, link := range linksForTargetURL.OutLinks {
+		to, _ := g.nodeFor(link.URL)
+
+		edge, ok := g.edges[[2]string{from, to}]
+		if !ok {
+			edge = &GraphEdge{From: from, To: to}
+			g.edges[[2]string{from, to}] = edge
+		}
+		edge.Count++
+		if edge.AnchorText == "" {
+			edge.AnchorText = link.Text
+		}
+		if edge.Rel == "" {
+			edge.Rel = link.Rel
+		}
+	}
+}
+
+func (g *Graph) nodeFor(u *url.URL) (string, *GraphNode) {
+	key := PageKey(u)
+	node, ok := g.nodes[key]
+	if !ok {
+		node = &GraphNode{URL: PageURL(u), Depth: -1}
+		g.nodes[key] = node
+	}
+
+	return key, node
+}
+
+// Nodes returns copies of the nodes sorted by URL.
+func (g *Graph) Nodes() []*GraphNode {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	nodes := make([]*GraphNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodeCopy := *node
+		nodes = append(nodes, &nodeCopy)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].URL < nodes[j].URL })
+
+	return nodes
+}
+
+// Edges returns the edges sorted by source and then target URL.
+func (g *Graph) Edges() []*GraphEdge {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	edges := make([]*GraphEdge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		resolved := *edge
+		resolved.From, resolved.To = g.nodes[edge.From].URL, g.nodes[edge.To].URL
+		edges = append(edges, &resolved)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+
+	return edges
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph crawl {\n")
+	for _, node := range g.Nodes() {
+		fmt.Fprintf(&b, "  %s [status=%d, depth=%d, content_type=%s];\n",
+			dotQuote(node.URL), node.StatusCode, node.Depth, dotQuote(node.ContentType))
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, rel=%s, count=%d];\n",
+			dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.AnchorText), dotQuote(edge.Rel), edge.Count)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+type graphMLKey struct {
+	XMLName struct{} `xml:"key"`
+	ID      string   `xml:"id,attr"`
+	For     string   `xml:"for,attr"`
+	Name    string   `xml:"attr.name,attr"`
+	Type    string   `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	XMLName struct{} `xml:"data"`
+	Key     string   `xml:"key,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	XMLName struct{}      `xml:"node"`
+	ID      string        `xml:"id,attr"`
+	Data    []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	XMLName struct{}      `xml:"edge"`
+	Source  string        `xml:"source,attr"`
+	Target  string        `xml:"target,attr"`
+	Data    []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName struct{}     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// WriteGraphML writes the graph in the GraphML format, which Gephi and yEd can open.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	document := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "status", For: "node", Name: "status", Type: "int"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "content_type", For: "node", Name: "content_type", Type: "string"},
+			{ID: "anchor_text", For: "edge", Name: "anchor_text", Type: "string"},
+			{ID: "rel", For: "edge", Name: "rel", Type: "string"},
+			{ID: "count", For: "edge", Name: "count", Type: "int"},
+		},
+	}
+	document.Graph.ID = "crawl"
+	document.Graph.EdgeDefault = "directed"
+
+	ids := make(map[string]string)
+	for i, node := range g.Nodes() {
+		ids[node.URL] = "n" + strconv.Itoa(i)
+		document.Graph.Nodes = append(document.Graph.Nodes, graphMLNode{
+			ID: ids[node.URL],
+			Data: []graphMLData{
+				{Key: "url", Value: node.URL},
+				{Key: "status", Value: strconv.Itoa(node.StatusCode)},
+				{Key: "depth", Value: strconv.Itoa(node.Depth)},
+				{Key: "content_type", Value: node.ContentType},
+			},
+		})
+	}
+	for _, edge := range g.Edges() {
+		document.Graph.Edges = append(document.Graph.Edges, graphMLEdge{
+			Source: ids[edge.From],
+			Target: ids[edge.To],
+			Data: []graphMLData{
+				{Key: "anchor_text", Value: edge.AnchorText},
+				{Key: "rel", Value: edge.Rel},
+				{Key: "count", Value: strconv.Itoa(edge.Count)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteCSV writes the nodes and the edges of the graph as two CSV files, with
+// the column names Gephi expects when importing a spreadsheet.
+func (g *Graph) WriteCSV(nodesWriter io.Writer, edgesWriter io.Writer) error {
+	nodes := csv.NewWriter(nodesWriter)
+	if err := nodes.Write([]string{"Id", "Label", "Status", "Depth", "ContentType"}); err != nil {
+		return err
+	}
+	ids := make(map[string]string)
+	for i, node := range g.Nodes() {
+		ids[node.URL] = strconv.Itoa(i)
+		record := []string{ids[node.URL], node.URL, strconv.Itoa(node.StatusCode), strconv.Itoa(node.Depth), node.ContentType}
+		if err := nodes.Write(record); err != nil {
+			return err
+		}
+	}
+	nodes.Flush()
+	if err := nodes.Error(); err != nil {
+		return err
+	}
+
+	edges := csv.NewWriter(edgesWriter)
+	if err := edges.Write([]string{"Source", "Target", "Weight", "AnchorText", "Rel"}); err != nil {
+		return err
+	}
+	for _, edge := range g.Edges() {
+		record := []string{ids[edge.From], ids[edge.To], strconv.Itoa(edge.Count), edge.AnchorText, edge.Rel}
+		if err := edges.Write(record); err != nil {
+			return err
+		}
+	}
+	edges.Flush()
+
+	return edges.Error()
+}
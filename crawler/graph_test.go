@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func makeGraphForTest(t *testing.T) *Graph {
+	graph := NewGraph()
+	graph.AddPage(&LinksByTargetURL{
+		TargetURL:   makeURLFor(t, "https://abc.com"),
+		StatusCode:  http.StatusOK,
+		ContentType: "text/html",
+		OutLinks: []*Link{
+			{URL: makeURLFor(t, "https://abc.com/a"), Text: `Path "A"`},
+			{URL: makeURLFor(t, "https://abc.com/a"), Text: "Again", Rel: "nofollow"},
+			{URL: makeURLFor(t, "https://abc.com/b")},
+		},
+	})
+	graph.AddPage(&LinksByTargetURL{
+		TargetURL:   makeURLFor(t, "https://abc.com/a"),
+		StatusCode:  http.StatusNotFound,
+		Depth:       1,
+		ContentType: "text/html",
+		OutLinks:    []*Link{{URL: makeURLFor(t, "https://abc.com"), Text: "Home"}},
+	})
+
+	return graph
+}
+
+func TestGraph_AddPage_Success(t *testing.T) {
+	graph := makeGraphForTest(t)
+
+	nodes := graph.Nodes()
+	assert.Len(t, nodes, 3)
+	assert.Equal(t, GraphNode{URL: "https://abc.com", StatusCode: 200, Depth: 0, ContentType: "text/html"}, *nodes[0])
+	assert.Equal(t, GraphNode{URL: "https://abc.com/a", StatusCode: 404, Depth: 1, ContentType: "text/html"}, *nodes[1])
+	assert.Equal(t, GraphNode{URL: "https://abc.com/b", Depth: -1}, *nodes[2])
+
+	edges := graph.Edges()
+	assert.Len(t, edges, 3)
+	assert.Equal(t, GraphEdge{From: "https://abc.com", To: "https://abc.com/a", AnchorText: `Path "A"`, Rel: "nofollow", Count: 2}, *edges[0])
+	assert.Equal(t, GraphEdge{From: "https://abc.com", To: "https://abc.com/b", Count: 1}, *edges[1])
+	assert.Equal(t, GraphEdge{From: "https://abc.com/a", To: "https://abc.com", AnchorText: "Home", Count: 1}, *edges[2])
+}
+
+func TestGraph_AddPage_SamePage(t *testing.T) {
+	graph := NewGraph()
+	graph.AddPage(&LinksByTargetURL{
+		TargetURL: makeURLFor(t, "https://abc.com/"),
+		OutLinks: []*Link{
+			{URL: makeURLFor(t, "https://abc.com/a?utm_source=x"), Text: "A"},
+			{URL: makeURLFor(t, "https://abc.com/a#reviews")},
+			{URL: makeURLFor(t, "https://abc.com/#top")},
+		},
+	})
+	graph.AddPage(&LinksByTargetURL{TargetURL: makeURLFor(t, "https://abc.com/a"), StatusCode: http.StatusOK, Depth: 1})
+
+	nodes := graph.Nodes()
+	assert.Len(t, nodes, 2)
+	assert.Equal(t, GraphNode{URL: "https://abc.com/a", StatusCode: 200, Depth: 1}, *nodes[1])
+
+	edges := graph.Edges()
+	assert.Len(t, edges, 2)
+	assert.Equal(t, GraphEdge{From: "https://abc.com/", To: "https://abc.com/", Count: 1}, *edges[0])
+	assert.Equal(t, GraphEdge{From: "https://abc.com/", To: "https://abc.com/a", AnchorText: "A", Count: 2}, *edges[1])
+}
+
+func TestGraph_WriteDOT_Success(t *testing.T) {
+	var output bytes.Buffer
+	assert.NoError(t, makeGraphForTest(t).WriteDOT(&output))
+
+	assert.Equal(t, `digraph crawl {
+  "https://abc.com" [status=200, depth=0, content_type="text/html"];
+  "https://abc.com/a" [status=404, depth=1, content_type="text/html"];
+  "https://abc.com/b" [status=0, depth=-1, content_type=""];
+  "https://abc.com" -> "https://abc.com/a" [label="Path \"A\"", rel="nofollow", count=2];
+  "https://abc.com" -> "https://abc.com/b" [label="", rel="", count=1];
+  "https://abc.com/a" -> "https://abc.com" [label="Home", rel="", count=1];
+}
+`, output.String())
+}
+
+func TestGraph_WriteGraphML_Success(t *testing.T) {
+	var output bytes.Buffer
+	assert.NoError(t, makeGraphForTest(t).WriteGraphML(&output))
+
+	assert.Contains(t, output.String(), `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	assert.Contains(t, output.String(), `<key id="status" for="node" attr.name="status" attr.type="int"></key>`)
+	assert.Contains(t, output.String(), `<graph id="crawl" edgedefault="directed">`)
+	assert.Contains(t, output.String(), `<node id="n1">
+      <data key="url">https://abc.com/a</data>
+      <data key="status">404</data>`)
+	assert.Contains(t, output.String(), `<edge source="n0" target="n1">
+      <data key="anchor_text">Path &#34;A&#34;</data>
+      <data key="rel">nofollow</data>
+      <data key="count">2</data>
+    </edge>`)
+}
+
+func TestGraph_WriteCSV_Success(t *testing.T) {
+	var nodes, edges bytes.Buffer
+	assert.NoError(t, makeGraphForTest(t).WriteCSV(&nodes, &edges))
+
+	assert.Equal(t, `Id,Label,Status,Depth,ContentType
+0,https://abc.com,200,0,text/html
+1,https://abc.com/a,404,1,text/html
+2,https://abc.com/b,0,-1,
+`, nodes.String())
+	assert.Equal(t, `Source,Target,Weight,AnchorText,Rel
+0,1,2,"Path ""A""",nofollow
+0,2,1,,
+1,0,1,Home,
+`, edges.String())
+}
@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+)
+
+// ErrSkip is returned by a hook (or wrapped in the error it returns) to skip a
+// page rather than fail it. The page is reported as skipped, the error being
+// its SkipReason.
+var ErrSkip = errors.New("skipped by a hook")
+
+// EnqueueHook is called with every URL found before it is queued, including
+// the seeds. It can change the task (e.g. normalize the URL or attach
+// Metadata) and returns false to drop it.
+type EnqueueHook func(task *Task) bool
+
+// FetchHook is called before every request, e.g. to add headers. Returning an
+// error stops the request: the page is skipped for ErrSkip, failed otherwise.
+type FetchHook func(ctx context.Context, request *FetchRequest) error
+
+// ResponseHook is called with every response, before its body is read.
+// Returning an error drops the response: the page is skipped for ErrSkip,
+// failed otherwise.
+type ResponseHook func(ctx context.Context, request *FetchRequest, response *FetchResponse) error
+
+// PageHook is called with every page crawled, skipped ones included, before
+// it is delivered and its links followed. It can change the page (e.g. drop
+// links or attach Metadata). Returning an error skips the page for ErrSkip,
+// not following any of its links, and fails it otherwise.
+type PageHook func(ctx context.Context, page *LinksByTargetURL) error
+
+// SkipHook is called with every page skipped.
+type SkipHook func(page *LinksByTargetURL)
+
+// ErrorHook is called with every page that failed.
+type ErrorHook func(targetURL *url.URL, err error)
+
+// DoneHook is called once a crawl is over, with the error that ended it, if
+// any.
+type DoneHook func(err error)
+
+// hooks holds the hooks of a Crawler. Each hook point is a chain: hooks are
+// called in the order they were added and the first one to veto stops it.
+type hooks struct {
+	beforeEnqueue []EnqueueHook
+	beforeFetch   []FetchHook
+	afterFetch    []ResponseHook
+	afterExtract  []PageHook
+	onSkip        []SkipHook
+	onError       []ErrorHook
+	onDone        []DoneHook
+	m             sync.RWMutex
+}
+
+// BeforeEnqueue adds a hook called before URLs are queued. Hooks are called
+// from the workers, concurrently, and should be added before crawling.
+func (c *Crawler) BeforeEnqueue(hook EnqueueHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.beforeEnqueue = append(c.hooks.beforeEnqueue, hook)
+}
+
+// BeforeFetch adds a hook called before requests are sent.
+func (c *Crawler) BeforeFetch(hook FetchHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.beforeFetch = append(c.hooks.beforeFetch, hook)
+}
+
+// AfterFetch adds a hook called when responses are received.
+func (c *Crawler) AfterFetch(hook ResponseHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.afterFetch = append(c.hooks.afterFetch, hook)
+}
+
+// AfterExtract adds a hook called once the links of a page are extracted.
+func (c *Crawler) AfterExtract(hook PageHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.afterExtract = append(c.hooks.afterExtract, hook)
+}
+
+// OnSkip adds a hook called for every page skipped.
+func (c *Crawler) OnSkip(hook SkipHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.onSkip = append(c.hooks.onSkip, hook)
+}
+
+// OnError adds a hook called for every page that failed.
+func (c *Crawler) OnError(hook ErrorHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.onError = append(c.hooks.onError, hook)
+}
+
+// OnDone adds a hook called when a crawl is over.
+func (c *Crawler) OnDone(hook DoneHook) {
+	c.hooks.m.Lock()
+	defer c.hooks.m.Unlock()
+
+	c.hooks.onDone = append(c.hooks.onDone, hook)
+}
+
+func (h *hooks) runBeforeEnqueue(task *Task) bool {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.beforeEnqueue {
+		if !hook(task) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *hooks) runBeforeFetch(ctx context.Context, request *FetchRequest) error {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.beforeFetch {
+		if err := hook(ctx, request); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *hooks) runAfterFetch(ctx context.Context, request *FetchRequest, response *FetchResponse) error {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.afterFetch {
+		if err := hook(ctx, request, response); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *hooks) runAfterExtract(ctx context.Context, page *LinksByTargetURL) error {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.afterExtract {
+		if err := hook(ctx, page); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *hooks) runOnSkip(page *LinksByTargetURL) {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.onSkip {
+		hook(page)
+	}
+}
+
+func (h *hooks) runOnError(targetURL *url.URL, err error) {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.onError {
+		hook(targetURL, err)
+	}
+}
+
+func (h *hooks) runOnDone(err error) {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	for _, hook := range h.onDone {
+		hook(err)
+	}
+}
+
+// skipPage turns a page into a skipped one, its links not being followed.
+func skipPage(page *LinksByTargetURL, err error) {
+	page.SkipReason = err.Error()
+	page.Links, page.Resources, page.OutLinks = nil, nil, nil
+}
@@ -0,0 +1,168 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCrawler_BeforeEnqueue_Success(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":   NewMemoryPage(http.StatusOK, "text/html", `<a href="/a?utm_source=x">A</a><a href="/private/b">B</a>`),
+		"https://abc.com/a": NewMemoryPage(http.StatusOK, "text/html", ""),
+	})
+	crawler := New(WithFetcher(fetcher), WithWorkers(4), WithRetryAttempts(1))
+
+	crawler.BeforeEnqueue(func(task *Task) bool {
+		return !strings.HasPrefix(task.TargetURL.Path, "/private")
+	})
+	crawler.BeforeEnqueue(func(task *Task) bool {
+		stripped := *task.TargetURL
+		stripped.RawQuery = ""
+		task.TargetURL = &stripped
+		task.Metadata = map[string]interface{}{"section": "home"}
+		return true
+	})
+
+	var processed []string
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(page *LinksByTargetURL) {
+		processed = append(processed, page.TargetURL.String())
+		assert.Equal(t, "home", page.Metadata["section"])
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, []string{"https://abc.com", "https://abc.com/a"}, processed)
+}
+
+func TestCrawler_BeforeFetch_Success(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com": NewMemoryPage(http.StatusOK, "text/html", `<a href="/admin">admin</a>`),
+	})
+	crawler := New(WithFetcher(fetcher), WithWorkers(4), WithRetryAttempts(1))
+
+	crawler.BeforeFetch(func(_ context.Context, request *FetchRequest) error {
+		if request.URL.Host == "abc.com" {
+			request.Header.Set("Authorization", "Bearer abc")
+		}
+		return nil
+	})
+	crawler.BeforeFetch(func(_ context.Context, request *FetchRequest) error {
+		if request.URL.Path == "/admin" {
+			return fmt.Errorf("admin pages are not crawled: %w", ErrSkip)
+		}
+		return nil
+	})
+
+	var skipped []*LinksByTargetURL
+	crawler.OnSkip(func(page *LinksByTargetURL) {
+		skipped = append(skipped, page)
+	})
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(*LinksByTargetURL) {}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	requests := fetcher.Requests()
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "Bearer abc", requests[0].Header.Get("Authorization"))
+
+	assert.Len(t, skipped, 1)
+	assert.Equal(t, "https://abc.com/admin", skipped[0].TargetURL.String())
+	assert.Equal(t, "admin pages are not crawled: skipped by a hook", skipped[0].SkipReason)
+}
+
+func TestCrawler_AfterFetch_Error(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":   NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/b">B</a>`),
+		"https://abc.com/a": NewMemoryPage(http.StatusOK, "text/html", ""),
+		"https://abc.com/b": NewMemoryPage(http.StatusOK, "text/html", ""),
+	})
+	crawler := New(WithFetcher(fetcher), WithWorkers(4), WithRetryAttempts(1))
+
+	errForbidden := errors.New("forbidden response")
+	crawler.AfterFetch(func(_ context.Context, request *FetchRequest, response *FetchResponse) error {
+		switch request.URL.Path {
+		case "/a":
+			return ErrSkip
+		case "/b":
+			return errForbidden
+		}
+		return nil
+	})
+
+	var failed []*url.URL
+	crawler.OnError(func(targetURL *url.URL, err error) {
+		failed = append(failed, targetURL)
+		assert.ErrorIs(t, err, errForbidden)
+	})
+
+	var errs []error
+	var skipped []string
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(page *LinksByTargetURL) {
+		if page.Skipped() {
+			skipped = append(skipped, page.TargetURL.String())
+		}
+	}, func(err error) {
+		errs = append(errs, err)
+	})
+
+	assert.Equal(t, []string{"https://abc.com/a"}, skipped)
+	assert.Len(t, errs, 1)
+	var crawlerErr *Error
+	assert.ErrorAs(t, errs[0], &crawlerErr)
+	assert.Equal(t, "https://abc.com/b", crawlerErr.TargetURL.String())
+	assert.Equal(t, []string{"https://abc.com/b"}, urlsToStrings(failed))
+}
+
+func TestCrawler_AfterExtract_Success(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":        NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/logout">logout</a><a href="/drafts">drafts</a>`),
+		"https://abc.com/a":      NewMemoryPage(http.StatusOK, "text/html", ""),
+		"https://abc.com/drafts": NewMemoryPage(http.StatusOK, "text/html", `<a href="/drafts/1">1</a>`),
+	})
+	crawler := New(WithFetcher(fetcher), WithWorkers(4), WithRetryAttempts(1))
+
+	crawler.AfterExtract(func(_ context.Context, page *LinksByTargetURL) error {
+		var outLinks []*Link
+		for _, link := range page.OutLinks {
+			if link.URL.Path != "/logout" {
+				outLinks = append(outLinks, link)
+			}
+		}
+		page.OutLinks = outLinks
+		page.Metadata["links"] = len(page.Links)
+		return nil
+	})
+	crawler.AfterExtract(func(_ context.Context, page *LinksByTargetURL) error {
+		if page.TargetURL.Path == "/drafts" {
+			return ErrSkip
+		}
+		return nil
+	})
+
+	var doneErr error
+	var done int
+	crawler.OnDone(func(err error) {
+		done++
+		doneErr = err
+	})
+
+	processed := map[string]*LinksByTargetURL{}
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(page *LinksByTargetURL) {
+		processed[page.TargetURL.String()] = page
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Len(t, processed, 3)
+	assert.Equal(t, 3, processed["https://abc.com"].Metadata["links"])
+	assert.Empty(t, processed["https://abc.com/drafts"].Links)
+	assert.Equal(t, ErrSkip.Error(), processed["https://abc.com/drafts"].SkipReason)
+	assert.Equal(t, 1, done)
+	assert.NoError(t, doneErr)
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+)
+
+// Iterator pulls the results of a crawl one at a time:
+//
+//	iterator, err := c.Iterate(ctx, seeds...)
+//	if err != nil {
+//		return err
+//	}
+//	defer iterator.Close()
+//	for iterator.Next() {
+//		result := iterator.Result()
+//		...
+//	}
+//	return iterator.Err()
+//
+// Pages are only crawled as fast as Next is called.
+type Iterator struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results <-chan Result
+	result  Result
+	err     error
+	closed  bool
+}
+
+// Iterate starts crawling from the seeds like Crawl, the results being pulled
+// from the Iterator.
+func (c *Crawler) Iterate(ctx context.Context, seeds ...*url.URL) (*Iterator, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	results, err := c.Crawl(ctx, seeds...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &Iterator{ctx: ctx, cancel: cancel, results: results}, nil
+}
+
+// Next waits for the result of the next page. It returns false once the crawl
+// is over, Err telling why when it did not run out of pages.
+func (i *Iterator) Next() bool {
+	if i.closed {
+		return false
+	}
+
+	for result := range i.results {
+		if result.TargetURL == nil {
+			i.err = result.Err
+			continue
+		}
+		i.result = result
+		return true
+	}
+
+	if i.err == nil {
+		i.err = i.ctx.Err()
+	}
+	i.closed = true
+	i.cancel()
+
+	return false
+}
+
+// Result returns the result read by the last call to Next.
+func (i *Iterator) Result() Result {
+	return i.result
+}
+
+// Err returns the error that ended the crawl, if any, once Next returned false.
+func (i *Iterator) Err() error {
+	return i.err
+}
+
+// Close stops the crawl and waits for the workers to be done. It only needs to
+// be called when the results are not read until Next returns false.
+func (i *Iterator) Close() {
+	if i.closed {
+		return
+	}
+
+	i.closed = true
+	i.cancel()
+	for range i.results {
+	}
+}
@@ -0,0 +1,165 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSite serves a site whose home page links to n pages.
+func newTestSite(n int) *MemoryFetcher {
+	var links strings.Builder
+	pages := map[string]*MemoryPage{}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&links, `<a href="/%d">%d</a>`, i, i)
+		pages[fmt.Sprintf("https://abc.com/%d", i)] = NewMemoryPage(http.StatusOK, "text/html", "")
+	}
+	pages["https://abc.com"] = NewMemoryPage(http.StatusOK, "text/html", links.String())
+
+	return NewMemoryFetcher(pages)
+}
+
+func TestCrawler_Crawl_Success(t *testing.T) {
+	crawler := New(WithFetcher(newTestSite(5)), WithWorkers(4), WithRetryAttempts(1))
+
+	results, err := crawler.Crawl(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+
+	var processed []string
+	for result := range results {
+		assert.NoError(t, result.Err)
+		assert.Equal(t, result.TargetURL, result.Page.TargetURL)
+		processed = append(processed, result.TargetURL.String())
+	}
+	assert.Len(t, processed, 6)
+	assert.Equal(t, "https://abc.com", processed[0])
+
+	_, err = crawler.Crawl(context.Background())
+	assert.ErrorIs(t, err, errNoSeeds)
+}
+
+func TestCrawler_Crawl_Backpressure(t *testing.T) {
+	fetcher := newTestSite(20)
+	crawler := New(WithFetcher(fetcher), WithWorkers(4), WithRetryAttempts(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results, err := crawler.Crawl(ctx, makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+
+	<-results
+	time.Sleep(50 * time.Millisecond)
+	// The workers wait for their results to be received, so no more than one
+	// page per worker gets fetched in the meantime.
+	assert.LessOrEqual(t, len(fetcher.Requests()), 1+4)
+
+	_, err = crawler.Crawl(ctx, makeURLFor(t, "https://abc.com"))
+	assert.ErrorIs(t, err, errAlreadyCrawling)
+
+	cancel()
+	for range results {
+	}
+}
+
+func TestCrawler_Crawl_Errors(t *testing.T) {
+	fetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		if request.URL.Path == "/boom" {
+			panic("boom")
+		}
+		return NewMemoryFetcher(map[string]*MemoryPage{
+			"https://abc.com": NewMemoryPage(http.StatusOK, "text/html", `<a href="/boom">boom</a>`),
+		}).Fetch(ctx, request)
+	})
+	crawler := New(WithFetcher(fetcher), WithWorkers(2), WithRetryAttempts(1))
+
+	results, err := crawler.Crawl(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+
+	var crawlErr error
+	for result := range results {
+		if result.TargetURL == nil {
+			crawlErr = result.Err
+		}
+	}
+	var panicErr *PanicError
+	assert.ErrorAs(t, crawlErr, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+}
+
+func TestIterator_Success(t *testing.T) {
+	crawler := New(WithFetcher(newTestSite(5)), WithWorkers(4), WithRetryAttempts(1))
+
+	iterator, err := crawler.Iterate(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	defer iterator.Close()
+
+	var processed int
+	for iterator.Next() {
+		assert.NotNil(t, iterator.Result().Page)
+		processed++
+	}
+	assert.NoError(t, iterator.Err())
+	assert.Equal(t, 6, processed)
+	assert.False(t, iterator.Next())
+}
+
+func TestIterator_Close(t *testing.T) {
+	fetcher := newTestSite(20)
+	crawler := New(WithFetcher(fetcher), WithWorkers(4), WithRetryAttempts(1))
+
+	iterator, err := crawler.Iterate(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	assert.True(t, iterator.Next())
+	iterator.Close()
+
+	assert.False(t, iterator.Next())
+	assert.Less(t, len(fetcher.Requests()), 21)
+
+	// Once closed, the crawler can crawl again, without the pages the first
+	// crawl left behind.
+	iterator, err = crawler.Iterate(context.Background(), makeURLFor(t, "https://abc.com/new"))
+	assert.NoError(t, err)
+	var processed []string
+	for iterator.Next() {
+		processed = append(processed, iterator.Result().TargetURL.String())
+	}
+	assert.NoError(t, iterator.Err())
+	assert.Equal(t, []string{"https://abc.com/new"}, processed)
+	iterator.Close()
+}
+
+func TestIterator_Close_PageBudget(t *testing.T) {
+	crawler := New(WithFetcher(newTestSite(20)), WithWorkers(1), WithRetryAttempts(1), WithMaxPages(3))
+
+	iterator, err := crawler.Iterate(context.Background(), makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	for iterator.Next() {
+	}
+	iterator.Close()
+
+	// The second crawl gets a budget of its own.
+	iterator, err = crawler.Iterate(context.Background(), makeURLFor(t, "https://abc.com/new"))
+	assert.NoError(t, err)
+	defer iterator.Close()
+	assert.True(t, iterator.Next())
+	assert.Equal(t, "https://abc.com/new", iterator.Result().TargetURL.String())
+}
+
+func TestIterator_Canceled(t *testing.T) {
+	crawler := New(WithFetcher(newTestSite(20)), WithWorkers(4), WithRetryAttempts(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iterator, err := crawler.Iterate(ctx, makeURLFor(t, "https://abc.com"))
+	assert.NoError(t, err)
+	defer iterator.Close()
+
+	assert.True(t, iterator.Next())
+	cancel()
+	for iterator.Next() {
+	}
+	assert.ErrorIs(t, iterator.Err(), context.Canceled)
+}
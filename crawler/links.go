@@ -0,0 +1,264 @@
+package crawler
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	anchorTag          = "a"
+	linkTag            = "link"
+	styleTag           = "style"
+	titleTag           = "title"
+	bodyTag            = "body"
+	anchorHrefProperty = "href"
+	anchorRelProperty  = "rel"
+	typeProperty       = "type"
+	styleProperty      = "style"
+)
+
+// Link is a link found in a document along with what the document says about
+// it, e.g. the anchor text, the rel attribute and the advertised media type of
+// an HTML anchor or the priority of a sitemap URL (0 when there is none).
+// Resources (stylesheets, images, fonts, etc.) are links that are not
+// navigational.
+type Link struct {
+	URL       *url.URL
+	Text      string
+	Rel       string
+	MediaType string
+	Resource  bool
+	Priority  float64
+}
+
+// ExtractLinksFrom returns the navigational links of an HTML document.
+func ExtractLinksFrom(htmlBody io.Reader) []*url.URL {
+	links, _ := HTMLExtractor{}.Extract(htmlBody)
+
+	var navigationalLinks []*Link
+	for _, link := range links {
+		if !link.Resource {
+			navigationalLinks = append(navigationalLinks, link)
+		}
+	}
+
+	return URLsOf(navigationalLinks)
+}
+
+// URLsOf returns the URLs of links, in the same order.
+func URLsOf(links []*Link) []*url.URL {
+	var urls []*url.URL
+	for _, link := range links {
+		urls = append(urls, link.URL)
+	}
+
+	return urls
+}
+
+// HTMLExtractor gets the links out of HTML documents.
+type HTMLExtractor struct{}
+
+// Extract never fails, as the tokenizer is lenient enough to get the links out
+// of malformed pages, and even out of documents that are not HTML at all. Along
+// with anchors, it returns the RSS/Atom feeds advertised by <link> elements,
+// the stylesheets and the resources referenced by the CSS in <style> elements
+// and style attributes.
+func (HTMLExtractor) Extract(htmlBody io.Reader) ([]*Link, error) {
+	var links []*Link
+	var anchorText strings.Builder
+	var openAnchors []*Link
+	var inStyle bool
+
+	closeAnchors := func() {
+		text := strings.Join(strings.Fields(anchorText.String()), " ")
+		for _, link := range openAnchors {
+			link.Text = text
+		}
+		openAnchors = nil
+		anchorText.Reset()
+	}
+
+	tokenizer := html.NewTokenizer(htmlBody)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			closeAnchors()
+			return links, nil
+		case html.TextToken:
+			if inStyle {
+				links = append(links, ExtractCSSLinks(string(tokenizer.Text()))...)
+			} else if len(openAnchors) > 0 {
+				anchorText.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			switch token := tokenizer.Token(); token.Data {
+			case anchorTag:
+				closeAnchors()
+			case styleTag:
+				inStyle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			for _, attr := range token.Attr {
+				if attr.Key == styleProperty {
+					links = append(links, ExtractCSSLinks(attr.Val)...)
+				}
+			}
+
+			switch token.Data {
+			case anchorTag:
+				closeAnchors()
+
+				anchorLinks := linksFrom(token)
+				links = append(links, anchorLinks...)
+				if tokenType == html.StartTagToken {
+					openAnchors = anchorLinks
+				}
+			case linkTag:
+				for _, link := range linksFrom(token) {
+					switch {
+					case hasRel(link.Rel, "stylesheet"):
+						link.Resource = true
+						links = append(links, link)
+					case hasRel(link.Rel, "alternate") && IsFeedContentType(link.MediaType):
+						links = append(links, link)
+					}
+				}
+			case styleTag:
+				inStyle = tokenType == html.StartTagToken
+			}
+		}
+	}
+}
+
+// ExtractPageInfo returns the title of an HTML document and the URL of its
+// canonical link, if any, as they are found in its head.
+func ExtractPageInfo(htmlBody io.Reader) (string, *url.URL) {
+	var title strings.Builder
+	var canonical *url.URL
+	var inTitle, titleFound bool
+
+	tokenizer := html.NewTokenizer(htmlBody)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(title.String()), " "), canonical
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().Data == titleTag {
+				inTitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case titleTag:
+				inTitle = tokenType == html.StartTagToken && !titleFound
+				titleFound = true
+			case linkTag:
+				for _, link := range linksFrom(token) {
+					if canonical == nil && hasRel(link.Rel, "canonical") {
+						canonical = link.URL
+					}
+				}
+			case bodyTag:
+				return strings.Join(strings.Fields(title.String()), " "), canonical
+			}
+		}
+	}
+}
+
+func hasRel(rel string, value string) bool {
+	for _, field := range strings.Fields(rel) {
+		if field == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func linksFrom(token html.Token) []*Link {
+	var rel, mediaType string
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case anchorRelProperty:
+			rel = strings.Join(strings.Fields(strings.ToLower(attr.Val)), " ")
+		case typeProperty:
+			mediaType = strings.ToLower(strings.TrimSpace(attr.Val))
+		}
+	}
+
+	var links []*Link
+	for _, attr := range token.Attr {
+		if attr.Key != anchorHrefProperty {
+			continue
+		}
+
+		u, err := url.Parse(attr.Val)
+		if err != nil {
+			continue
+		}
+		links = append(links, &Link{URL: u, Rel: rel, MediaType: mediaType})
+	}
+
+	return links
+}
+
+// FilterURLsBySubdomain keeps the links that are on the same host as the domain,
+// resolving relative links against it. Links using schemes other than HTTP(S)
+// are left out.
+func FilterURLsBySubdomain(domain *url.URL, links []*url.URL) []*url.URL {
+	var filteredURLs []*url.URL
+
+	for _, link := range links {
+		if u, ok := resolveInScope(domain, link); ok {
+			filteredURLs = append(filteredURLs, u)
+		}
+	}
+
+	return filteredURLs
+}
+
+// FilterLinksBySubdomain does the same as FilterURLsBySubdomain, keeping what
+// was extracted along with each link.
+func FilterLinksBySubdomain(domain *url.URL, links []*Link) []*Link {
+	var filteredLinks []*Link
+
+	for _, link := range links {
+		if u, ok := resolveInScope(domain, link.URL); ok {
+			filteredLink := *link
+			filteredLink.URL = u
+			filteredLinks = append(filteredLinks, &filteredLink)
+		}
+	}
+
+	return filteredLinks
+}
+
+func resolveInScope(domain *url.URL, link *url.URL) (*url.URL, bool) {
+	if link.Scheme != "" && link.Scheme != "http" && link.Scheme != "https" {
+		return nil, false
+	}
+
+	if link.Host == "" {
+		return domain.ResolveReference(link), true
+	}
+
+	if domain.Host == link.Host {
+		if link.Scheme == "" {
+			return domain.ResolveReference(link), true
+		}
+
+		return link, true
+	}
+
+	return nil, false
+}
@@ -1,4 +1,4 @@
-package main
+package crawler
 
 import (
 	"fmt"
@@ -201,3 +201,85 @@ func makeURLFor(t *testing.T, rawURL string) *url.URL {
 	assert.NoError(t, err)
 	return link
 }
+
+func TestHTMLExtractor_AnchorTextAndRel_Success(t *testing.T) {
+	htmlContent := `
+		<a href="/a" rel="NoFollow  External">Path <b>A</b>
+		</a>
+		<a href="/b"/>
+		<a href="/c">Path C
+		<a href="/d">Path D</a>`
+
+	links, err := HTMLExtractor{}.Extract(strings.NewReader(htmlContent))
+	assert.NoError(t, err)
+	assert.Len(t, links, 4)
+	assert.Equal(t, "Path A", links[0].Text)
+	assert.Equal(t, "nofollow external", links[0].Rel)
+	assert.Equal(t, "", links[1].Text)
+	assert.Equal(t, "Path C", links[2].Text)
+	assert.Equal(t, "Path D", links[3].Text)
+}
+
+func TestFilterLinksBySubdomain_Success(t *testing.T) {
+	startURL := makeURLFor(t, "https://abc.com")
+	links := FilterLinksBySubdomain(startURL, []*Link{
+		{URL: makeURLFor(t, "/path-a"), Text: "Path A"},
+		{URL: makeURLFor(t, "https://bca.com/path-b")},
+	})
+
+	assert.Len(t, links, 1)
+	assert.Equal(t, "https://abc.com/path-a", links[0].URL.String())
+	assert.Equal(t, "Path A", links[0].Text)
+}
+
+func TestHTMLExtractor_StylesAndStylesheets_Success(t *testing.T) {
+	htmlContent := `
+		<!DOCTYPE html>
+		<html>
+			<head>
+				<link rel="stylesheet" href="/main.css">
+				<link rel="icon" href="/favicon.ico">
+				<style>
+					.hero { background-image: url("/hero.png"); }
+					a[href="/not-a-link"] { color: red; }
+				</style>
+			</head>
+			<body style="background: url(/body.png)">
+				<a href="/a" style="background: url('/icon.png')">Path A</a>
+			</body>
+		</html>`
+
+	links, err := HTMLExtractor{}.Extract(strings.NewReader(htmlContent))
+	assert.NoError(t, err)
+
+	var resources []string
+	var navigational []string
+	for _, link := range links {
+		if link.Resource {
+			resources = append(resources, link.URL.String())
+		} else {
+			navigational = append(navigational, link.URL.String())
+		}
+	}
+	assert.Equal(t, []string{"/main.css", "/hero.png", "/body.png", "/icon.png"}, resources)
+	assert.Equal(t, []string{"/a"}, navigational)
+	assert.Equal(t, []*url.URL{makeURLFor(t, "/a")}, ExtractLinksFrom(strings.NewReader(htmlContent)))
+}
+
+func TestExtractPageInfo_Success(t *testing.T) {
+	title, canonical := ExtractPageInfo(strings.NewReader(`
+		<html><head>
+			<title>
+				ABC   Home
+			</title>
+			<link rel="canonical" href="/home">
+		</head>
+		<body><title>Not this one</title><link rel="canonical" href="/nope"></body></html>`))
+
+	assert.Equal(t, "ABC Home", title)
+	assert.Equal(t, "/home", canonical.String())
+
+	title, canonical = ExtractPageInfo(strings.NewReader(`<p>no head</p>`))
+	assert.Empty(t, title)
+	assert.Nil(t, canonical)
+}
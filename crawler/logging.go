@@ -0,0 +1,73 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// LogLevels and LogFormats are the values NewLogger accepts.
+var (
+	LogLevels  = []string{"debug", "info", "warn", "error"}
+	LogFormats = []string{"text", "json"}
+)
+
+type contextKey int
+
+const (
+	workerIDKey contextKey = iota
+	loggerKey
+)
+
+// NewLogger creates a logger writing to w, as text or JSON, the lines at level
+// or above.
+func NewLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var logLevel slog.Level
+	if err := logLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, expected one of %v", level, LogLevels)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format %q, expected one of %v", format, LogFormats)
+}
+
+// discardLogger is the logger of the Crawler and the WorkerPool when they are
+// given none.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// ContextWithWorkerID is how the WorkerPool tells a task which worker runs it.
+func ContextWithWorkerID(ctx context.Context, workerID int) context.Context {
+	return context.WithValue(ctx, workerIDKey, workerID)
+}
+
+// WorkerIDFrom returns the ID of the worker running the task of ctx, if any.
+func WorkerIDFrom(ctx context.Context) (int, bool) {
+	workerID, ok := ctx.Value(workerIDKey).(int)
+	return workerID, ok
+}
+
+// ContextWithLogger attaches a logger to ctx, e.g. one with the attributes of
+// the page being crawled.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// LoggerFrom returns the logger attached to ctx, or fallback when there is none.
+func LoggerFrom(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
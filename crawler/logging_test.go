@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// newTestLogger logs debug lines and above as text to w, without the time so
+// that they can be compared.
+func newTestLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+}
+
+func TestNewLogger_Success(t *testing.T) {
+	var output bytes.Buffer
+	logger, err := NewLogger(&output, "warn", "json")
+	assert.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown", "url", "https://abc.com")
+	assert.NotContains(t, output.String(), "hidden")
+	assert.Contains(t, output.String(), `"level":"WARN","msg":"shown","url":"https://abc.com"}`)
+
+	output.Reset()
+	logger, err = NewLogger(&output, "DEBUG", "text")
+	assert.NoError(t, err)
+
+	logger.Debug("shown", "depth", 1)
+	assert.Contains(t, output.String(), "level=DEBUG msg=shown depth=1")
+}
+
+func TestNewLogger_Errors(t *testing.T) {
+	_, err := NewLogger(io.Discard, "verbose", "text")
+	assert.EqualError(t, err, `unknown log level "verbose", expected one of [debug info warn error]`)
+
+	_, err = NewLogger(io.Discard, "info", "xml")
+	assert.EqualError(t, err, `unknown log format "xml", expected one of [text json]`)
+}
+
+func TestLoggerFrom_Success(t *testing.T) {
+	fallback := discardLogger()
+	assert.Same(t, fallback, LoggerFrom(context.Background(), fallback))
+
+	logger := discardLogger()
+	ctx := ContextWithLogger(ContextWithWorkerID(context.Background(), 3), logger)
+	assert.Same(t, logger, LoggerFrom(ctx, fallback))
+
+	workerID, ok := WorkerIDFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, 3, workerID)
+
+	_, ok = WorkerIDFrom(context.Background())
+	assert.False(t, ok)
+}
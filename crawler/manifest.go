@@ -0,0 +1,385 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The change likelihood of a page counts as this many levels of depth, so
+// that pages that often change are crawled before the stable ones around them.
+const changeLikelihoodWeight = 10
+
+// ManifestEntry is what a Manifest records of a page: enough to tell whether
+// it changed and, when it did not, to reuse its links. Crawls and Changes
+// count the crawls that reached the page and those that found it changed,
+// ChangedAt being the time of the last change. Feed is kept for feeds, to
+// report on them when they did not change.
+type ManifestEntry struct {
+	URL           string        `json:"url"`
+	Depth         int           `json:"depth"`
+	StatusCode    int           `json:"status_code,omitempty"`
+	ContentType   string        `json:"content_type,omitempty"`
+	ContentLength int64         `json:"content_length,omitempty"`
+	ETag          string        `json:"etag,omitempty"`
+	LastModified  string        `json:"last_modified,omitempty"`
+	ContentHash   string        `json:"content_hash,omitempty"`
+	RedirectedTo  string        `json:"redirected_to,omitempty"`
+	Title         string        `json:"title,omitempty"`
+	Canonical     string        `json:"canonical,omitempty"`
+	Links         []string      `json:"links,omitempty"`
+	Resources     []string      `json:"resources,omitempty"`
+	SkipReason    string        `json:"skip_reason,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	Feed          *ManifestFeed `json:"feed,omitempty"`
+	CrawledAt     time.Time     `json:"crawled_at"`
+	ChangedAt     time.Time     `json:"changed_at"`
+	Crawls        int           `json:"crawls"`
+	Changes       int           `json:"changes"`
+}
+
+// NewManifestEntry records the result of a page, previous being its entry in
+// the manifest of the previous crawl, if any. A page that failed keeps the
+// validators and links of its previous entry, so that the next crawl can still
+// make a conditional request for it.
+func NewManifestEntry(result Result, previous *ManifestEntry, now time.Time) *ManifestEntry {
+	entry := &ManifestEntry{URL: result.TargetURL.String(), CrawledAt: now}
+	if result.Err != nil {
+		if previous != nil {
+			*entry = *previous
+			entry.CrawledAt = now
+		}
+		entry.Error = result.Err.Error()
+	} else {
+		page := result.Page
+		entry.Depth = page.Depth
+		entry.StatusCode = page.StatusCode
+		entry.ContentType = page.ContentType
+		entry.ContentLength = page.ContentLength
+		entry.ETag = page.ETag
+		entry.LastModified = page.LastModified
+		entry.ContentHash = page.ContentHash
+		entry.Title = page.Title
+		if page.RedirectedTo != nil {
+			entry.RedirectedTo = page.RedirectedTo.String()
+		}
+		if page.Canonical != nil {
+			entry.Canonical = page.Canonical.String()
+		}
+		entry.Links = urlStrings(page.Links)
+		entry.Resources = urlStrings(page.Resources)
+		entry.SkipReason = page.SkipReason
+		if page.Feed != nil {
+			entry.Feed = newManifestFeed(page.Feed)
+		}
+		if page.NotModified && previous != nil {
+			entry.ContentHash = previous.ContentHash
+		}
+	}
+
+	entry.Crawls, entry.Changes, entry.ChangedAt = 1, 1, now
+	if previous != nil {
+		entry.Crawls = previous.Crawls + 1
+		entry.Changes, entry.ChangedAt = previous.Changes, previous.ChangedAt
+		if entry.changedSince(previous) {
+			entry.Changes++
+			entry.ChangedAt = now
+		}
+	}
+
+	return entry
+}
+
+// changedSince tells whether the page is not what it was when previous was
+// recorded: its content, status, redirect or error changed.
+func (e *ManifestEntry) changedSince(previous *ManifestEntry) bool {
+	return e.StatusCode != previous.StatusCode || e.ContentHash != previous.ContentHash ||
+		e.RedirectedTo != previous.RedirectedTo || e.SkipReason != previous.SkipReason || e.Error != previous.Error
+}
+
+// page rebuilds the page of the entry for a 304 Not Modified response.
+func (e *ManifestEntry) page(targetURL *url.URL, response *FetchResponse) *LinksByTargetURL {
+	page := &LinksByTargetURL{
+		TargetURL:     targetURL,
+		StatusCode:    e.StatusCode,
+		Duration:      response.Duration,
+		ContentType:   e.ContentType,
+		ContentLength: e.ContentLength,
+		SkipReason:    e.SkipReason,
+		ETag:          e.ETag,
+		LastModified:  e.LastModified,
+		ContentHash:   e.ContentHash,
+		NotModified:   true,
+		Title:         e.Title,
+	}
+	if redirectedTo, err := url.Parse(e.RedirectedTo); err == nil && e.RedirectedTo != "" {
+		page.RedirectedTo = redirectedTo
+	}
+	if canonical, err := url.Parse(e.Canonical); err == nil && e.Canonical != "" {
+		page.Canonical = canonical
+	}
+	if etag := response.Header.Get("ETag"); etag != "" {
+		page.ETag = etag
+	}
+	if lastModified := response.Header.Get("Last-Modified"); lastModified != "" {
+		page.LastModified = lastModified
+	}
+
+	for _, rawURL := range e.Links {
+		if link, err := url.Parse(rawURL); err == nil {
+			page.Links = append(page.Links, link)
+			page.OutLinks = append(page.OutLinks, &Link{URL: link})
+		}
+	}
+	for _, rawURL := range e.Resources {
+		if resource, err := url.Parse(rawURL); err == nil {
+			page.Resources = append(page.Resources, resource)
+			page.OutLinks = append(page.OutLinks, &Link{URL: resource, Resource: true})
+		}
+	}
+	if e.Feed != nil {
+		page.Feed = e.Feed.feed()
+	}
+
+	return page
+}
+
+// ManifestFeed is what a ManifestEntry records of a feed.
+type ManifestFeed struct {
+	Title   string              `json:"title,omitempty"`
+	Entries []ManifestFeedEntry `json:"entries,omitempty"`
+}
+
+// ManifestFeedEntry is what a ManifestFeed records of a FeedEntry.
+type ManifestFeedEntry struct {
+	Link      string    `json:"link"`
+	Title     string    `json:"title,omitempty"`
+	Published time.Time `json:"published,omitempty"`
+}
+
+func newManifestFeed(feed *Feed) *ManifestFeed {
+	manifestFeed := &ManifestFeed{Title: feed.Title}
+	for _, entry := range feed.Entries {
+		manifestFeed.Entries = append(manifestFeed.Entries, ManifestFeedEntry{
+			Link:      entry.Link.String(),
+			Title:     entry.Title,
+			Published: entry.Published,
+		})
+	}
+
+	return manifestFeed
+}
+
+// feed rebuilds the Feed, leaving out the entries whose link does not parse.
+func (f *ManifestFeed) feed() *Feed {
+	feed := &Feed{Title: f.Title}
+	for _, entry := range f.Entries {
+		if link, err := url.Parse(entry.Link); err == nil {
+			feed.Entries = append(feed.Entries, &FeedEntry{Link: link, Title: entry.Title, Published: entry.Published})
+		}
+	}
+
+	return feed
+}
+
+// changeLikelihood estimates how likely the page is to have changed since it
+// was recorded from the share of crawls that found it changed, smoothed so
+// that a page seen once is not deemed certain to change.
+func (e *ManifestEntry) changeLikelihood() float64 {
+	return float64(e.Changes+1) / float64(e.Crawls+2)
+}
+
+// Manifest records every page a crawl reached, keyed by normalized URL (see
+// NormalizeURL). It is written as JSON Lines, one ManifestEntry per line, and
+// given to the next crawl to recrawl incrementally (see WithPreviousManifest).
+type Manifest struct {
+	entries map[string]*ManifestEntry
+	m       sync.RWMutex
+}
+
+// NewManifest returns an empty manifest, to Add the entries of a crawl to.
+func NewManifest() *Manifest {
+	return &Manifest{entries: make(map[string]*ManifestEntry)}
+}
+
+// ReadManifest reads a manifest written by Manifest.Write.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	manifest := NewManifest()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid manifest entry on line %d: %w", line, err)
+		}
+		targetURL, err := url.Parse(entry.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest entry on line %d: %w", line, err)
+		}
+		manifest.entries[NormalizeURL(targetURL)] = &entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Add records an entry, replacing the one of the same page if any.
+func (m *Manifest) Add(entry *ManifestEntry) error {
+	targetURL, err := url.Parse(entry.URL)
+	if err != nil {
+		return err
+	}
+
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.entries[NormalizeURL(targetURL)] = entry
+
+	return nil
+}
+
+// Lookup returns the entry of a page, if any. A nil manifest has no entries.
+func (m *Manifest) Lookup(targetURL *url.URL) (*ManifestEntry, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	m.m.RLock()
+	defer m.m.RUnlock()
+	entry, ok := m.entries[NormalizeURL(targetURL)]
+
+	return entry, ok
+}
+
+// Len returns the number of entries.
+func (m *Manifest) Len() int {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	return len(m.entries)
+}
+
+// Entries returns the entries sorted by URL.
+func (m *Manifest) Entries() []*ManifestEntry {
+	m.m.RLock()
+	defer m.m.RUnlock()
+
+	entries := make([]*ManifestEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URL < entries[j].URL
+	})
+
+	return entries
+}
+
+// Write writes the manifest as JSON Lines, sorted by URL.
+func (m *Manifest) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	for _, entry := range m.Entries() {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// setConditionalHeaders makes request conditional on the validators the
+// manifest has for its page.
+func (m *Manifest) setConditionalHeaders(request *FetchRequest) {
+	entry, ok := m.Lookup(request.URL)
+	if !ok || entry.Error != "" {
+		return
+	}
+
+	if entry.ETag != "" {
+		request.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		request.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// ChangeLikelihoodScorer crawls first the pages that changed most often in
+// the crawls recorded by previous. Pages it does not know are new, so they are
+// crawled as if they were sure to have changed.
+func ChangeLikelihoodScorer(previous *Manifest) Scorer {
+	return func(task *Task) float64 {
+		entry, ok := previous.Lookup(task.TargetURL)
+		if !ok {
+			return changeLikelihoodWeight
+		}
+
+		return changeLikelihoodWeight * entry.changeLikelihood()
+	}
+}
+
+// ChangeSet lists the URLs of the pages that are new, were removed, were
+// modified or are unchanged since the previous crawl. Removed pages are those
+// the crawl did not reach, e.g. because no page links to them anymore.
+type ChangeSet struct {
+	New       []string `json:"new"`
+	Removed   []string `json:"removed"`
+	Modified  []string `json:"modified"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// NewChangeSet compares the manifest of a crawl with the one of the previous
+// crawl.
+func NewChangeSet(previous *Manifest, current *Manifest) *ChangeSet {
+	changeSet := &ChangeSet{New: []string{}, Removed: []string{}, Modified: []string{}, Unchanged: []string{}}
+
+	for _, entry := range current.Entries() {
+		targetURL, _ := url.Parse(entry.URL)
+		previousEntry, ok := previous.Lookup(targetURL)
+		switch {
+		case !ok:
+			changeSet.New = append(changeSet.New, entry.URL)
+		case entry.changedSince(previousEntry):
+			changeSet.Modified = append(changeSet.Modified, entry.URL)
+		default:
+			changeSet.Unchanged = append(changeSet.Unchanged, entry.URL)
+		}
+	}
+
+	for _, entry := range previous.Entries() {
+		targetURL, _ := url.Parse(entry.URL)
+		if _, ok := current.Lookup(targetURL); !ok {
+			changeSet.Removed = append(changeSet.Removed, entry.URL)
+		}
+	}
+
+	return changeSet
+}
+
+// WriteJSON writes the change set as an indented JSON object.
+func (s *ChangeSet) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(s)
+}
+
+func urlStrings(urls []*url.URL) []string {
+	var strs []string
+	for _, u := range urls {
+		strs = append(strs, u.String())
+	}
+
+	return strs
+}
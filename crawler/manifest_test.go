@@ -0,0 +1,196 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// versionedSite serves pages from a map of URL to body, with the body as ETag,
+// answering the conditional requests for unchanged pages with a 304. Pages are
+// HTML unless contentTypes says otherwise.
+type versionedSite struct {
+	pages        map[string]string
+	contentTypes map[string]string
+	conditional  []string
+	m            sync.Mutex
+}
+
+func (s *versionedSite) Fetch(_ context.Context, request *FetchRequest) (*FetchResponse, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	body, ok := s.pages[request.URL.String()]
+	if !ok {
+		return &FetchResponse{StatusCode: http.StatusNotFound, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+
+	etag := `"` + body + `"`
+	if ifNoneMatch := request.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		s.conditional = append(s.conditional, request.URL.String())
+		if ifNoneMatch == etag {
+			return &FetchResponse{StatusCode: http.StatusNotModified, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+	}
+
+	contentType, ok := s.contentTypes[request.URL.String()]
+	if !ok {
+		contentType = "text/html"
+	}
+
+	return &FetchResponse{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {contentType}, "Etag": {etag}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+func crawlManifest(t *testing.T, site Fetcher, previous *Manifest, now time.Time, extraOpts ...Option) *Manifest {
+	opts := append([]Option{WithFetcher(site), WithWorkers(4), WithRetryAttempts(1)}, extraOpts...)
+	if previous != nil {
+		opts = append(opts, WithPreviousManifest(previous))
+	}
+	crawler := New(opts...)
+
+	results, err := crawler.Crawl(context.Background(), makeURLFor(t, "https://abc.com/"))
+	assert.NoError(t, err)
+
+	manifest := NewManifest()
+	for result := range results {
+		previousEntry, _ := previous.Lookup(result.TargetURL)
+		assert.NoError(t, manifest.Add(NewManifestEntry(result, previousEntry, now)))
+	}
+
+	return manifest
+}
+
+func TestCrawler_Crawl_Incremental(t *testing.T) {
+	site := &versionedSite{pages: map[string]string{
+		"https://abc.com/":    `<a href="/a">A</a><a href="/b">B</a>`,
+		"https://abc.com/a":   `<a href="/a/1">1</a>`,
+		"https://abc.com/a/1": `first`,
+		"https://abc.com/b":   `b`,
+	}}
+	firstCrawl := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := crawlManifest(t, site, nil, firstCrawl)
+	assert.Equal(t, 4, previous.Len())
+	assert.Empty(t, site.conditional)
+
+	site.pages["https://abc.com/"] = `<a href="/a">A</a><a href="/c">C</a>`
+	site.pages["https://abc.com/a/1"] = `second`
+	site.pages["https://abc.com/c"] = `c`
+	secondCrawl := firstCrawl.Add(24 * time.Hour)
+	current := crawlManifest(t, site, previous, secondCrawl)
+
+	// /a was not modified, yet its link to /a/1 was followed.
+	assert.ElementsMatch(t, []string{"https://abc.com/", "https://abc.com/a", "https://abc.com/a/1"}, site.conditional)
+	entry, ok := current.Lookup(makeURLFor(t, "https://abc.com/a"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"https://abc.com/a/1"}, entry.Links)
+	assert.Equal(t, 2, entry.Crawls)
+	assert.Equal(t, 1, entry.Changes)
+	assert.Equal(t, firstCrawl, entry.ChangedAt)
+
+	entry, _ = current.Lookup(makeURLFor(t, "https://abc.com/a/1"))
+	assert.Equal(t, 2, entry.Changes)
+	assert.Equal(t, secondCrawl, entry.ChangedAt)
+
+	changeSet := NewChangeSet(previous, current)
+	assert.Equal(t, []string{"https://abc.com/c"}, changeSet.New)
+	assert.Equal(t, []string{"https://abc.com/b"}, changeSet.Removed)
+	assert.Equal(t, []string{"https://abc.com/", "https://abc.com/a/1"}, changeSet.Modified)
+	assert.Equal(t, []string{"https://abc.com/a"}, changeSet.Unchanged)
+}
+
+func TestCrawler_Crawl_IncrementalFeed(t *testing.T) {
+	site := &versionedSite{
+		pages: map[string]string{
+			"https://abc.com/":             `<link rel="alternate" type="application/rss+xml" href="/blog/rss.xml">`,
+			"https://abc.com/blog/rss.xml": rssFeed,
+			"https://abc.com/blog/post-a":  `a`,
+		},
+		contentTypes: map[string]string{"https://abc.com/blog/rss.xml": "application/rss+xml"},
+	}
+	firstCrawl := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := crawlManifest(t, site, nil, firstCrawl, WithFeedMonitor(NewFeedMonitor(0)))
+
+	var buf bytes.Buffer
+	assert.NoError(t, previous.Write(&buf))
+	previous, err := ReadManifest(&buf)
+	assert.NoError(t, err)
+
+	// The feed was not modified, yet it is still reported with its entries.
+	monitor := NewFeedMonitor(0)
+	crawlManifest(t, site, previous, firstCrawl.Add(24*time.Hour), WithFeedMonitor(monitor))
+	assert.Contains(t, site.conditional, "https://abc.com/blog/rss.xml")
+
+	report := monitor.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "ABC Blog", report[0].Title)
+	assert.Equal(t, 2, report[0].Entries)
+	assert.Equal(t, time.Date(2023, 1, 3, 15, 4, 5, 0, time.UTC), report[0].LastPublished.UTC())
+	assert.Equal(t, []string{"https://abc.com/blog/post-b"}, urlsToStrings(report[0].BrokenEntries))
+}
+
+func TestNewManifestEntry_Error(t *testing.T) {
+	now := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	previous := &ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, ETag: `"v1"`, Links: []string{"https://abc.com/a"}, Crawls: 3, Changes: 1}
+
+	entry := NewManifestEntry(Result{TargetURL: makeURLFor(t, "https://abc.com/"), Err: errors.New("timeout")}, previous, now)
+
+	assert.Equal(t, `"v1"`, entry.ETag)
+	assert.Equal(t, []string{"https://abc.com/a"}, entry.Links)
+	assert.Equal(t, "timeout", entry.Error)
+	assert.Equal(t, 4, entry.Crawls)
+	assert.Equal(t, 2, entry.Changes)
+	assert.Equal(t, now, entry.CrawledAt)
+
+	// The validators of failed pages are not used.
+	manifest := NewManifest()
+	assert.NoError(t, manifest.Add(entry))
+	request := NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com/"))
+	manifest.setConditionalHeaders(request)
+	assert.Empty(t, request.Header.Get("If-None-Match"))
+}
+
+func TestManifest_ReadWrite_Success(t *testing.T) {
+	manifest := NewManifest()
+	assert.NoError(t, manifest.Add(&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusOK, Crawls: 1, Changes: 1}))
+	assert.NoError(t, manifest.Add(&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusNotFound, Crawls: 4}))
+
+	var buffer bytes.Buffer
+	assert.NoError(t, manifest.Write(&buffer))
+	assert.Equal(t, 2, strings.Count(buffer.String(), "\n"))
+	assert.True(t, strings.HasPrefix(buffer.String(), `{"url":"https://abc.com/a",`))
+
+	read, err := ReadManifest(&buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, manifest.Entries(), read.Entries())
+
+	// Pages are looked up by normalized URL.
+	entry, ok := read.Lookup(makeURLFor(t, "https://ABC.com:443/a#top"))
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, entry.StatusCode)
+
+	_, err = ReadManifest(strings.NewReader("{\"url\":\"https://abc.com\"}\nnot json\n"))
+	assert.EqualError(t, err, "invalid manifest entry on line 2: invalid character 'o' in literal null (expecting 'u')")
+}
+
+func TestChangeLikelihoodScorer_Success(t *testing.T) {
+	manifest := NewManifest()
+	assert.NoError(t, manifest.Add(&ManifestEntry{URL: "https://abc.com/news", Crawls: 8, Changes: 8}))
+	assert.NoError(t, manifest.Add(&ManifestEntry{URL: "https://abc.com/about", Crawls: 8, Changes: 0}))
+	scorer := ChangeLikelihoodScorer(manifest)
+
+	assert.Equal(t, 10.0, scorer(&Task{TargetURL: makeURLFor(t, "https://abc.com/new")}))
+	assert.Equal(t, 9.0, scorer(&Task{TargetURL: makeURLFor(t, "https://abc.com/news")}))
+	assert.Equal(t, 1.0, scorer(&Task{TargetURL: makeURLFor(t, "https://abc.com/about")}))
+}
@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MemoryPage is a canned response served by the MemoryFetcher.
+type MemoryPage struct {
+	statusCode int
+	header     http.Header
+	body       string
+}
+
+// NewMemoryPage returns a page without a Content-Type header when contentType
+// is empty.
+func NewMemoryPage(statusCode int, contentType string, body string) *MemoryPage {
+	header := make(http.Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	return &MemoryPage{statusCode: statusCode, header: header, body: body}
+}
+
+// MemoryFetcher serves pages from a map keyed by URL, which makes it possible
+// to test the Crawler without a network. Unknown URLs get an empty 404 and
+// every request is recorded so tests can assert on them.
+type MemoryFetcher struct {
+	pages    map[string]*MemoryPage
+	requests []*FetchRequest
+	m        sync.Mutex
+}
+
+// NewMemoryFetcher serves pages, keyed by the string of their URL.
+func NewMemoryFetcher(pages map[string]*MemoryPage) *MemoryFetcher {
+	return &MemoryFetcher{pages: pages}
+}
+
+// Fetch serves the page of the request URL, without a body for HEAD requests.
+func (f *MemoryFetcher) Fetch(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.m.Lock()
+	f.requests = append(f.requests, request)
+	page, ok := f.pages[request.URL.String()]
+	f.m.Unlock()
+
+	if !ok {
+		page = &MemoryPage{statusCode: http.StatusNotFound, header: make(http.Header)}
+	}
+
+	body := page.body
+	if request.Method == http.MethodHead {
+		body = ""
+	}
+
+	return &FetchResponse{
+		StatusCode:    page.statusCode,
+		Header:        page.header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(page.body)),
+	}, nil
+}
+
+// Requests returns the requests received so far, in order.
+func (f *MemoryFetcher) Requests() []*FetchRequest {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return append([]*FetchRequest(nil), f.requests...)
+}
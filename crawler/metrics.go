@@ -0,0 +1,376 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fetch latency buckets, in seconds.
+var defaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsRegistry holds metrics and writes them in the Prometheus text
+// exposition format, in the order they were registered.
+type MetricsRegistry struct {
+	metrics []*registeredMetric
+	m       sync.Mutex
+}
+
+type registeredMetric struct {
+	name       string
+	help       string
+	metricType string
+	samples    func() []metricSample
+}
+
+type metricSample struct {
+	suffix string
+	labels [][2]string
+	value  float64
+}
+
+// NewMetricsRegistry returns a registry without metrics.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{}
+}
+
+func (r *MetricsRegistry) register(name string, help string, metricType string, samples func() []metricSample) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.metrics = append(r.metrics, &registeredMetric{name: name, help: help, metricType: metricType, samples: samples})
+}
+
+// Counter is a value that only goes up. It is safe for concurrent use.
+type Counter struct {
+	value atomic.Uint64
+}
+
+// NewCounter registers a counter. name should end with _total, as Prometheus
+// expects.
+func (r *MetricsRegistry) NewCounter(name string, help string) *Counter {
+	counter := &Counter{}
+	r.register(name, help, "counter", func() []metricSample {
+		return []metricSample{{value: float64(counter.Value())}}
+	})
+
+	return counter
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add adds n to the counter.
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+// CounterVec is a set of counters told apart by the value of one label.
+type CounterVec struct {
+	label    string
+	counters map[string]*Counter
+	m        sync.Mutex
+}
+
+// NewCounterVec registers a set of counters labeled with label. Counters are
+// written sorted by label value, and only once they were used.
+func (r *MetricsRegistry) NewCounterVec(name string, help string, label string) *CounterVec {
+	counterVec := &CounterVec{label: label, counters: make(map[string]*Counter)}
+	r.register(name, help, "counter", func() []metricSample {
+		counterVec.m.Lock()
+		defer counterVec.m.Unlock()
+
+		var samples []metricSample
+		for _, labelValue := range sortedKeys(counterVec.counters) {
+			samples = append(samples, metricSample{
+				labels: [][2]string{{label, labelValue}},
+				value:  float64(counterVec.counters[labelValue].Value()),
+			})
+		}
+		return samples
+	})
+
+	return counterVec
+}
+
+// WithLabelValue returns the counter of a label value, creating it at zero if
+// needed.
+func (v *CounterVec) WithLabelValue(labelValue string) *Counter {
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	counter, ok := v.counters[labelValue]
+	if !ok {
+		counter = &Counter{}
+		v.counters[labelValue] = counter
+	}
+
+	return counter
+}
+
+// Gauge is a value that goes up and down. It is safe for concurrent use.
+type Gauge struct {
+	value atomic.Int64
+}
+
+// NewGauge registers a gauge.
+func (r *MetricsRegistry) NewGauge(name string, help string) *Gauge {
+	gauge := &Gauge{}
+	r.register(name, help, "gauge", func() []metricSample {
+		return []metricSample{{value: float64(gauge.Value())}}
+	})
+
+	return gauge
+}
+
+// Inc adds one to the gauge.
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+// Dec subtracts one from the gauge.
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+// Set sets the value of the gauge.
+func (g *Gauge) Set(value int64) {
+	g.value.Store(value)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() int64 {
+	return g.value.Load()
+}
+
+// NewGaugeFunc registers a gauge whose value is read from value whenever the
+// metrics are written.
+func (r *MetricsRegistry) NewGaugeFunc(name string, help string, value func() float64) {
+	r.register(name, help, "gauge", func() []metricSample {
+		return []metricSample{{value: value()}}
+	})
+}
+
+// NewGaugeVecFunc is NewGaugeFunc for a set of gauges told apart by the value
+// of one label.
+func (r *MetricsRegistry) NewGaugeVecFunc(name string, help string, label string, values func() map[string]float64) {
+	r.register(name, help, "gauge", func() []metricSample {
+		byLabelValue := values()
+
+		var samples []metricSample
+		for _, labelValue := range sortedKeys(byLabelValue) {
+			samples = append(samples, metricSample{labels: [][2]string{{label, labelValue}}, value: byLabelValue[labelValue]})
+		}
+		return samples
+	})
+}
+
+// Histogram counts observations in cumulative buckets, as Prometheus expects.
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+	m       sync.Mutex
+}
+
+// NewHistogram registers a histogram with the upper bounds of its buckets,
+// which must be sorted. The +Inf bucket is implied.
+func (r *MetricsRegistry) NewHistogram(name string, help string, buckets []float64) *Histogram {
+	histogram := &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	r.register(name, help, "histogram", histogram.samples)
+
+	return histogram
+}
+
+// Observe counts a value in every bucket it fits in.
+func (h *Histogram) Observe(value float64) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for i, bucket := range h.buckets {
+		if value <= bucket {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) samples() []metricSample {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var samples []metricSample
+	for i, bucket := range h.buckets {
+		samples = append(samples, metricSample{suffix: "_bucket", labels: [][2]string{{"le", formatMetricValue(bucket)}}, value: float64(h.counts[i])})
+	}
+	samples = append(samples,
+		metricSample{suffix: "_bucket", labels: [][2]string{{"le", "+Inf"}}, value: float64(h.count)},
+		metricSample{suffix: "_sum", value: h.sum},
+		metricSample{suffix: "_count", value: float64(h.count)},
+	)
+
+	return samples
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format. It
+// implements io.WriterTo.
+func (r *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	r.m.Lock()
+	metrics := append([]*registeredMetric(nil), r.metrics...)
+	r.m.Unlock()
+
+	var b strings.Builder
+	for _, metric := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", metric.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(metric.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", metric.name, metric.metricType)
+		for _, sample := range metric.samples() {
+			b.WriteString(metric.name + sample.suffix)
+			if len(sample.labels) > 0 {
+				var labels []string
+				for _, label := range sample.labels {
+					labels = append(labels, label[0]+`="`+escapeLabelValue(label[1])+`"`)
+				}
+				b.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+			b.WriteString(" " + formatMetricValue(sample.value) + "\n")
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Metrics are the metrics of a crawl. Its Middleware measures the requests,
+// the Crawler given it with WithMetrics the rest.
+type Metrics struct {
+	registry        *MetricsRegistry
+	pagesFetched    *Counter
+	pagesSkipped    *Counter
+	pageErrors      *Counter
+	retries         *Counter
+	requestsFailed  *Counter
+	responses       *CounterVec
+	bytesDownloaded *Counter
+	inFlight        *Gauge
+	fetchDuration   *Histogram
+	// Set by the Crawler.
+	queued func() int
+}
+
+// NewMetrics registers the metrics of a crawl, all prefixed with crawler_.
+func NewMetrics() *Metrics {
+	registry := NewMetricsRegistry()
+
+	return &Metrics{
+		registry:        registry,
+		pagesFetched:    registry.NewCounter("crawler_pages_fetched_total", "Pages fetched and processed, skipped ones included."),
+		pagesSkipped:    registry.NewCounter("crawler_pages_skipped_total", "Pages whose links were not extracted (content type, size, etc.)."),
+		pageErrors:      registry.NewCounter("crawler_page_errors_total", "Pages that could not be processed."),
+		retries:         registry.NewCounter("crawler_retries_total", "Requests retried after an error."),
+		requestsFailed:  registry.NewCounter("crawler_requests_failed_total", "Requests that got no response."),
+		responses:       registry.NewCounterVec("crawler_responses_total", "Responses by status code.", "code"),
+		bytesDownloaded: registry.NewCounter("crawler_downloaded_bytes_total", "Bytes of response bodies read."),
+		inFlight:        registry.NewGauge("crawler_requests_in_flight", "Requests waiting for a response."),
+		fetchDuration:   registry.NewHistogram("crawler_fetch_duration_seconds", "Time to get the response headers back.", defaultLatencyBuckets),
+	}
+}
+
+// Queued returns the number of pages waiting to be crawled.
+func (m *Metrics) Queued() int {
+	if m.queued == nil {
+		return 0
+	}
+
+	return m.queued()
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper, e.g. on /metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.registry.ServeHTTP(w, r)
+}
+
+// Middleware measures the requests: the ones in flight and failed, the
+// responses by status code, their latency and the bytes downloaded.
+func (m *Metrics) Middleware() FetcherMiddleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+			m.inFlight.Inc()
+			start := time.Now()
+			response, err := next.Fetch(ctx, request)
+			m.inFlight.Dec()
+			if err != nil {
+				m.requestsFailed.Inc()
+				return nil, err
+			}
+
+			duration := response.Duration
+			if duration <= 0 {
+				duration = time.Since(start)
+			}
+			m.fetchDuration.Observe(duration.Seconds())
+			m.responses.WithLabelValue(strconv.Itoa(response.StatusCode)).Inc()
+			response.Body = &countingReadCloser{ReadCloser: response.Body, counter: m.bytesDownloaded}
+
+			return response, nil
+		})
+	}
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	counter *Counter
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.counter.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRegistry_WriteTo(t *testing.T) {
+	registry := NewMetricsRegistry()
+	counter := registry.NewCounter("pages_total", "Pages crawled.")
+	counterVec := registry.NewCounterVec("responses_total", "Responses by status code.", "code")
+	gauge := registry.NewGauge("in_flight", "Requests\nin flight.")
+	registry.NewGaugeFunc("queue_depth", "Pages queued.", func() float64 { return 12 })
+	registry.NewGaugeVecFunc("limit", "Limit per host.", "host", func() map[string]float64 {
+		return map[string]float64{`b"\.com`: 1.5, "a.com": 2}
+	})
+	histogram := registry.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1})
+
+	counter.Inc()
+	counter.Add(2)
+	counterVec.WithLabelValue("404").Inc()
+	counterVec.WithLabelValue("200").Add(5)
+	gauge.Inc()
+	gauge.Inc()
+	gauge.Dec()
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+
+	var b strings.Builder
+	n, err := registry.WriteTo(&b)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(b.Len()), n)
+	assert.Equal(t, `# HELP pages_total Pages crawled.
+# TYPE pages_total counter
+pages_total 3
+# HELP responses_total Responses by status code.
+# TYPE responses_total counter
+responses_total{code="200"} 5
+responses_total{code="404"} 1
+# HELP in_flight Requests\nin flight.
+# TYPE in_flight gauge
+in_flight 1
+# HELP queue_depth Pages queued.
+# TYPE queue_depth gauge
+queue_depth 12
+# HELP limit Limit per host.
+# TYPE limit gauge
+limit{host="a.com"} 2
+limit{host="b\"\\.com"} 1.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+`, b.String())
+}
+
+func TestCrawlerMetrics_Middleware(t *testing.T) {
+	metrics := NewMetrics()
+	var calls int
+	fetcher := metrics.Middleware()(FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		calls++
+		assert.Equal(t, int64(1), metrics.inFlight.Value())
+		if calls == 2 {
+			return nil, errors.New("connection refused")
+		}
+		return &FetchResponse{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("hello")), Duration: 200 * time.Millisecond}, nil
+	}))
+
+	request := NewFetchRequest(http.MethodGet, makeURLFor(t, "https://abc.com"))
+	response, err := fetcher.Fetch(context.Background(), request)
+	assert.NoError(t, err)
+	_, _ = io.ReadAll(response.Body)
+	_, err = fetcher.Fetch(context.Background(), request)
+	assert.Error(t, err)
+
+	assert.Equal(t, int64(0), metrics.inFlight.Value())
+	assert.Equal(t, uint64(5), metrics.bytesDownloaded.Value())
+	assert.Equal(t, uint64(1), metrics.requestsFailed.Value())
+	assert.Equal(t, uint64(1), metrics.responses.WithLabelValue("200").Value())
+	assert.Equal(t, uint64(1), metrics.fetchDuration.count)
+	assert.Equal(t, 0.2, metrics.fetchDuration.sum)
+}
+
+func TestCrawler_GetAllLinksFor_Metrics(t *testing.T) {
+	var attempts int
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":       NewMemoryPage(http.StatusOK, "text/html", `<a href="/a">A</a><a href="/flaky">Flaky</a><a href="/b.zip">B</a>`),
+		"https://abc.com/a":     NewMemoryPage(http.StatusOK, "text/html", ``),
+		"https://abc.com/flaky": NewMemoryPage(http.StatusOK, "text/html", ``),
+	})
+	flakyFetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		if request.URL.Path == "/flaky" {
+			attempts++
+			if attempts == 1 {
+				return nil, errors.New("connection reset")
+			}
+		}
+		return fetcher.Fetch(ctx, request)
+	})
+
+	metrics := NewMetrics()
+	crawler := New(
+		WithFetcher(flakyFetcher),
+		WithWorkers(1),
+		WithRetryAttempts(2),
+		WithMetrics(metrics),
+		WithAdaptiveConcurrency(NewAdaptiveConcurrency(AdaptiveConcurrencyParams{})),
+	)
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(*LinksByTargetURL) {}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+	response, err := http.Get(server.URL + "/metrics")
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", response.Header.Get("Content-Type"))
+	for _, line := range []string{
+		"crawler_pages_fetched_total 4",
+		"crawler_pages_skipped_total 1",
+		"crawler_page_errors_total 0",
+		"crawler_retries_total 1",
+		"crawler_requests_failed_total 1",
+		`crawler_responses_total{code="200"} 3`,
+		"crawler_downloaded_bytes_total 66",
+		"crawler_requests_in_flight 0",
+		"crawler_fetch_duration_seconds_count 3",
+		"crawler_queue_depth 0",
+		"crawler_workers 1",
+		"crawler_active_workers 0",
+		`crawler_host_concurrency_limit{host="abc.com"} 4`,
+	} {
+		assert.Contains(t, string(body), line+"\n")
+	}
+}
@@ -0,0 +1,359 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultHistorySize is the number of run summaries a Monitor keeps unless
+// MonitorParams.HistorySize is set.
+const DefaultHistorySize = 100
+
+// AlertKind is the kind of change between two runs a Monitor alerts on.
+type AlertKind string
+
+const (
+	// AlertBrokenLink is a page that fails or answers with an error status
+	// while it did not before, or that is new and already broken.
+	AlertBrokenLink AlertKind = "broken_link"
+	// AlertStatusChange is any other change of the status of a page.
+	AlertStatusChange AlertKind = "status_change"
+	// AlertDisappeared is a page the previous run reached but not this one.
+	AlertDisappeared AlertKind = "disappeared"
+)
+
+// Alert is a change found by a run of a Monitor. Old and New are the statuses
+// of the page (see CrawlDiff.StatusChanges), and LinkedFrom the pages linking
+// to a broken one or using it as a resource.
+type Alert struct {
+	Kind       AlertKind `json:"kind"`
+	URL        string    `json:"url"`
+	Old        string    `json:"old,omitempty"`
+	New        string    `json:"new,omitempty"`
+	LinkedFrom []string  `json:"linked_from,omitempty"`
+}
+
+// NewAlerts compares the manifest of a crawl with the one of the previous
+// crawl, broken links first.
+func NewAlerts(previous *Manifest, current *Manifest) []Alert {
+	return newAlerts(previous, current, referrers(current))
+}
+
+// newAlerts is NewAlerts, linkedFrom being the referrers of the pages of the
+// current crawl.
+func newAlerts(previous *Manifest, current *Manifest, linkedFrom map[string][]string) []Alert {
+	diff := DiffManifests(previous, current)
+
+	var brokenLinks, statusChanges, disappeared []Alert
+	for _, rawURL := range diff.Added {
+		targetURL, _ := url.Parse(rawURL)
+		if entry, _ := current.Lookup(targetURL); entry.broken() {
+			brokenLinks = append(brokenLinks, Alert{Kind: AlertBrokenLink, URL: rawURL, New: statusOf(entry), LinkedFrom: linkedFrom[normalizedURLOf(rawURL)]})
+		}
+	}
+	for _, change := range diff.StatusChanges {
+		targetURL, _ := url.Parse(change.URL)
+		previousEntry, _ := previous.Lookup(targetURL)
+		entry, _ := current.Lookup(targetURL)
+		alert := Alert{Kind: AlertStatusChange, URL: change.URL, Old: change.Old, New: change.New}
+		if entry.broken() && !previousEntry.broken() {
+			alert.Kind = AlertBrokenLink
+			alert.LinkedFrom = linkedFrom[normalizedURLOf(change.URL)]
+			brokenLinks = append(brokenLinks, alert)
+			continue
+		}
+		statusChanges = append(statusChanges, alert)
+	}
+	for _, rawURL := range diff.Removed {
+		targetURL, _ := url.Parse(rawURL)
+		previousEntry, _ := previous.Lookup(targetURL)
+		disappeared = append(disappeared, Alert{Kind: AlertDisappeared, URL: rawURL, Old: statusOf(previousEntry)})
+	}
+
+	return append(append(brokenLinks, statusChanges...), disappeared...)
+}
+
+// sharedPages returns the entries of the manifest whose page other has too.
+func sharedPages(manifest *Manifest, other *Manifest) *Manifest {
+	shared := NewManifest()
+	for _, entry := range manifest.Entries() {
+		targetURL, _ := url.Parse(entry.URL)
+		if _, ok := other.Lookup(targetURL); ok {
+			shared.entries[NormalizeURL(targetURL)] = entry
+		}
+	}
+
+	return shared
+}
+
+// mergeManifests returns the entries of current along with those of the pages
+// of previous that current did not reach.
+func mergeManifests(previous *Manifest, current *Manifest) *Manifest {
+	merged := NewManifest()
+	for _, manifest := range []*Manifest{previous, current} {
+		for _, entry := range manifest.Entries() {
+			_ = merged.Add(entry)
+		}
+	}
+
+	return merged
+}
+
+// broken tells whether the page failed or answered with an error status.
+func (e *ManifestEntry) broken() bool {
+	return e.Error != "" || e.StatusCode >= 400
+}
+
+// RunSummary is the outcome of a run of a Monitor. Error is set when the
+// crawl failed, in which case there are no alerts.
+type RunSummary struct {
+	Site        string    `json:"site"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Pages       int       `json:"pages"`
+	BrokenPages int       `json:"broken_pages"`
+	Alerts      []Alert   `json:"alerts"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// RunHistory keeps the summaries of the last runs of a Monitor in a JSON Lines
+// file, oldest first.
+type RunHistory struct {
+	path string
+	size int
+}
+
+// NewRunHistory keeps the last size summaries in the file at path.
+func NewRunHistory(path string, size int) *RunHistory {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	return &RunHistory{path: path, size: size}
+}
+
+// Read returns the summaries kept, none if the file does not exist yet.
+func (h *RunHistory) Read() ([]*RunSummary, error) {
+	file, err := os.Open(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var summaries []*RunSummary
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var summary RunSummary
+		if err = json.Unmarshal(scanner.Bytes(), &summary); err != nil {
+			return nil, fmt.Errorf("invalid run summary on line %d of %s: %w", line, h.path, err)
+		}
+		summaries = append(summaries, &summary)
+	}
+
+	return summaries, scanner.Err()
+}
+
+// Append adds a summary, dropping the oldest ones beyond the size of the
+// history.
+func (h *RunHistory) Append(summary *RunSummary) error {
+	summaries, err := h.Read()
+	if err != nil {
+		return err
+	}
+	summaries = append(summaries, summary)
+	summaries = summaries[max(0, len(summaries)-h.size):]
+
+	return writeFileAtomically(h.path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, summary := range summaries {
+			if err := encoder.Encode(summary); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CrawlFunc crawls the monitored site, recrawling incrementally from previous
+// when it is not nil (see WithPreviousManifest).
+type CrawlFunc func(ctx context.Context, previous *Manifest) (*Manifest, error)
+
+// MonitorParams configures a Monitor. Site names the monitored site in the
+// run summaries. The manifest of the last run and the history of the runs are
+// kept in Dir, so that a restarted Monitor compares its first run with the
+// last run of the previous one. Partial tells that a run may not reach every
+// page, e.g. when the crawl has a page budget (see WithMaxPages): each run
+// covering a different part of the site, a run is then only compared with
+// what was last seen of the pages it reached, so that no page is taken for
+// added or disappeared.
+type MonitorParams struct {
+	Site        string
+	Schedule    Schedule
+	Dir         string
+	HistorySize int
+	RunAtStart  bool
+	Partial     bool
+	Notifiers   []Notifier
+	Logger      *slog.Logger
+}
+
+// Monitor crawls a site on a schedule and compares each run with the previous
+// one, notifying the alerts it finds.
+type Monitor struct {
+	crawl    CrawlFunc
+	params   MonitorParams
+	history  *RunHistory
+	previous *Manifest
+	now      func() time.Time
+}
+
+// NewMonitor creates params.Dir if needed and reads the manifest of the last
+// run kept there, if any.
+func NewMonitor(crawl CrawlFunc, params MonitorParams) (*Monitor, error) {
+	if params.Schedule == nil {
+		return nil, errors.New("a monitor needs a schedule")
+	}
+	if params.Logger == nil {
+		params.Logger = discardLogger()
+	}
+	if err := os.MkdirAll(params.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	monitor := &Monitor{
+		crawl:   crawl,
+		params:  params,
+		history: NewRunHistory(filepath.Join(params.Dir, "history.jsonl"), params.HistorySize),
+		now:     time.Now,
+	}
+
+	file, err := os.Open(monitor.manifestPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return monitor, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if monitor.previous, err = ReadManifest(file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", monitor.manifestPath(), err)
+	}
+
+	return monitor, nil
+}
+
+// Run runs the monitor on its schedule until ctx is canceled. A run that
+// lasts past the next start of the schedule makes the monitor skip it.
+func (m *Monitor) Run(ctx context.Context) error {
+	if m.params.RunAtStart {
+		m.runAndLog(ctx)
+	}
+
+	for ctx.Err() == nil {
+		next := m.params.Schedule.Next(m.now())
+		if next.IsZero() {
+			return errors.New("the schedule never runs")
+		}
+		m.params.Logger.Info("next run", "site", m.params.Site, "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(next.Sub(m.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		m.runAndLog(ctx)
+	}
+
+	return nil
+}
+
+func (m *Monitor) runAndLog(ctx context.Context) {
+	summary, err := m.RunOnce(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		m.params.Logger.Error("monitor run failed", "site", m.params.Site, "error", err)
+	}
+	if summary != nil {
+		m.params.Logger.Info("monitor run", "site", summary.Site, "pages", summary.Pages, "broken", summary.BrokenPages,
+			"alerts", len(summary.Alerts), "duration", summary.FinishedAt.Sub(summary.StartedAt), "error", summary.Error)
+	}
+}
+
+// RunOnce crawls the site, compares the crawl with the previous run, records
+// the summary in the history and notifies it when it has alerts or the crawl
+// failed. The first run has nothing to compare with, so it never alerts.
+// Nothing is recorded when ctx is canceled during the crawl.
+func (m *Monitor) RunOnce(ctx context.Context) (*RunSummary, error) {
+	summary := &RunSummary{Site: m.params.Site, StartedAt: m.now(), Alerts: []Alert{}}
+	manifest, err := m.crawl(ctx, m.previous)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	summary.FinishedAt = m.now()
+
+	var errs []error
+	if err != nil {
+		summary.Error = err.Error()
+	} else {
+		summary.Pages = manifest.Len()
+		for _, entry := range manifest.Entries() {
+			if entry.broken() {
+				summary.BrokenPages++
+			}
+		}
+		switch {
+		case m.previous != nil && m.params.Partial:
+			summary.Alerts = newAlerts(sharedPages(m.previous, manifest), sharedPages(manifest, m.previous), referrers(manifest))
+			manifest = mergeManifests(m.previous, manifest)
+		case m.previous != nil:
+			summary.Alerts = NewAlerts(m.previous, manifest)
+		}
+
+		m.previous = manifest
+		if err = writeFileAtomically(m.manifestPath(), manifest.Write); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s: %w", m.manifestPath(), err))
+		}
+	}
+
+	if err = m.history.Append(summary); err != nil {
+		errs = append(errs, fmt.Errorf("failed to record the run: %w", err))
+	}
+
+	if len(summary.Alerts) > 0 || summary.Error != "" {
+		for _, notifier := range m.params.Notifiers {
+			if err = notifier.Notify(ctx, summary); err != nil {
+				errs = append(errs, fmt.Errorf("failed to notify: %w", err))
+			}
+		}
+	}
+
+	return summary, errors.Join(errs...)
+}
+
+func (m *Monitor) manifestPath() string {
+	return filepath.Join(m.params.Dir, "manifest.jsonl")
+}
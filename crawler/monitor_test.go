@@ -0,0 +1,217 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type scheduleFunc func(t time.Time) time.Time
+
+func (f scheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+func TestNewAlerts_Success(t *testing.T) {
+	previous := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Links: []string{"https://abc.com/a", "https://abc.com/b", "https://abc.com/c"}},
+		&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusOK},
+		&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusNotFound},
+		&ManifestEntry{URL: "https://abc.com/c", StatusCode: http.StatusOK},
+		&ManifestEntry{URL: "https://abc.com/old", StatusCode: http.StatusOK},
+	)
+	current := newTestManifest(t,
+		&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Links: []string{"https://abc.com/a", "https://abc.com/b", "https://abc.com/c", "https://abc.com/new"}},
+		&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusOK, Error: "timeout"},
+		&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusGone},
+		&ManifestEntry{URL: "https://abc.com/c", StatusCode: http.StatusOK, Links: []string{"https://abc.com/new"}},
+		&ManifestEntry{URL: "https://abc.com/new", StatusCode: http.StatusInternalServerError},
+		&ManifestEntry{URL: "https://abc.com/fine", StatusCode: http.StatusOK},
+	)
+
+	assert.Equal(t, []Alert{
+		{Kind: AlertBrokenLink, URL: "https://abc.com/new", New: "500", LinkedFrom: []string{"https://abc.com/", "https://abc.com/c"}},
+		{Kind: AlertBrokenLink, URL: "https://abc.com/a", Old: "200", New: "error: timeout", LinkedFrom: []string{"https://abc.com/"}},
+		{Kind: AlertStatusChange, URL: "https://abc.com/b", Old: "404", New: "410"},
+		{Kind: AlertDisappeared, URL: "https://abc.com/old", Old: "200"},
+	}, NewAlerts(previous, current))
+	assert.Empty(t, NewAlerts(current, current))
+}
+
+func TestMonitor_RunOnce_Success(t *testing.T) {
+	site := &versionedSite{pages: map[string]string{
+		"https://abc.com/":  `<a href="/a">A</a><a href="/b">B</a>`,
+		"https://abc.com/a": `a`,
+		"https://abc.com/b": `b`,
+	}}
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	crawl := func(_ context.Context, previous *Manifest) (*Manifest, error) {
+		return crawlManifest(t, site, previous, now), nil
+	}
+
+	var notified []*RunSummary
+	params := MonitorParams{
+		Site:        "abc.com",
+		Schedule:    scheduleFunc(func(t time.Time) time.Time { return t.Add(time.Hour) }),
+		Dir:         t.TempDir(),
+		HistorySize: 2,
+		Notifiers: []Notifier{NotifierFunc(func(_ context.Context, summary *RunSummary) error {
+			notified = append(notified, summary)
+			return nil
+		})},
+	}
+	monitor, err := NewMonitor(crawl, params)
+	assert.NoError(t, err)
+
+	// The first run has nothing to compare with.
+	summary, err := monitor.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Pages)
+	assert.Empty(t, summary.Alerts)
+	assert.Empty(t, notified)
+
+	delete(site.pages, "https://abc.com/a")
+	site.pages["https://abc.com/"] = `<a href="/a">A</a>`
+	summary, err = monitor.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Pages)
+	assert.Equal(t, 1, summary.BrokenPages)
+	assert.Equal(t, []Alert{
+		{Kind: AlertBrokenLink, URL: "https://abc.com/a", Old: "200", New: "404", LinkedFrom: []string{"https://abc.com/"}},
+		{Kind: AlertDisappeared, URL: "https://abc.com/b", Old: "200"},
+	}, summary.Alerts)
+	assert.Equal(t, []*RunSummary{summary}, notified)
+
+	// A restarted monitor compares with the last run of the previous one.
+	monitor, err = NewMonitor(crawl, params)
+	assert.NoError(t, err)
+	summary, err = monitor.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Alerts)
+	assert.Len(t, notified, 1)
+
+	history, err := NewRunHistory(filepath.Join(params.Dir, "history.jsonl"), 0).Read()
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Len(t, history[0].Alerts, 2)
+	assert.Empty(t, history[1].Alerts)
+}
+
+func TestMonitor_RunOnce_Partial(t *testing.T) {
+	runs := []*Manifest{
+		newTestManifest(t,
+			&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Links: []string{"https://abc.com/a", "https://abc.com/b", "https://abc.com/c"}},
+			&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusOK},
+			&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusOK},
+		),
+		newTestManifest(t,
+			&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Links: []string{"https://abc.com/a", "https://abc.com/b", "https://abc.com/c"}},
+			&ManifestEntry{URL: "https://abc.com/b", StatusCode: http.StatusInternalServerError},
+			&ManifestEntry{URL: "https://abc.com/c", StatusCode: http.StatusNotFound},
+		),
+		newTestManifest(t,
+			&ManifestEntry{URL: "https://abc.com/", StatusCode: http.StatusOK, Links: []string{"https://abc.com/a", "https://abc.com/b", "https://abc.com/c"}},
+			&ManifestEntry{URL: "https://abc.com/a", StatusCode: http.StatusNotFound},
+		),
+	}
+	crawl := func(_ context.Context, _ *Manifest) (*Manifest, error) {
+		manifest := runs[0]
+		runs = runs[1:]
+		return manifest, nil
+	}
+
+	monitor, err := NewMonitor(crawl, MonitorParams{
+		Schedule: scheduleFunc(func(t time.Time) time.Time { return t.Add(time.Hour) }),
+		Dir:      t.TempDir(),
+		Partial:  true,
+	})
+	assert.NoError(t, err)
+	_, err = monitor.RunOnce(context.Background())
+	assert.NoError(t, err)
+
+	// The pages the run did not reach neither disappeared nor were added.
+	summary, err := monitor.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, summary.Pages)
+	assert.Equal(t, []Alert{
+		{Kind: AlertBrokenLink, URL: "https://abc.com/b", Old: "200", New: "500", LinkedFrom: []string{"https://abc.com/"}},
+	}, summary.Alerts)
+
+	// A page is compared with what was last seen of it, however many runs ago.
+	summary, err = monitor.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, summary.Pages)
+	assert.Equal(t, []Alert{
+		{Kind: AlertBrokenLink, URL: "https://abc.com/a", Old: "200", New: "404", LinkedFrom: []string{"https://abc.com/"}},
+	}, summary.Alerts)
+}
+
+func TestMonitor_RunOnce_Error(t *testing.T) {
+	errNotify := errors.New("notifier down")
+	var notified []*RunSummary
+	monitor, err := NewMonitor(func(context.Context, *Manifest) (*Manifest, error) {
+		return nil, errors.New("no route to host")
+	}, MonitorParams{
+		Site:     "abc.com",
+		Schedule: scheduleFunc(func(t time.Time) time.Time { return t.Add(time.Hour) }),
+		Dir:      t.TempDir(),
+		Notifiers: []Notifier{NotifierFunc(func(_ context.Context, summary *RunSummary) error {
+			notified = append(notified, summary)
+			return errNotify
+		})},
+	})
+	assert.NoError(t, err)
+
+	summary, err := monitor.RunOnce(context.Background())
+	assert.ErrorIs(t, err, errNotify)
+	assert.Equal(t, "no route to host", summary.Error)
+	assert.Equal(t, []*RunSummary{summary}, notified)
+}
+
+func TestMonitor_Run_Success(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs int
+	monitor, err := NewMonitor(func(context.Context, *Manifest) (*Manifest, error) {
+		runs++
+		if runs == 3 {
+			cancel()
+		}
+		return NewManifest(), nil
+	}, MonitorParams{
+		Schedule:   scheduleFunc(func(t time.Time) time.Time { return t.Add(10 * time.Millisecond) }),
+		Dir:        t.TempDir(),
+		RunAtStart: true,
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, monitor.Run(ctx))
+	assert.Equal(t, 3, runs)
+
+	// The canceled run is not recorded.
+	history, err := monitor.history.Read()
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestRunHistory_Append_Success(t *testing.T) {
+	history := NewRunHistory(filepath.Join(t.TempDir(), "history.jsonl"), 3)
+	summaries, err := history.Read()
+	assert.NoError(t, err)
+	assert.Empty(t, summaries)
+
+	for pages := 1; pages <= 5; pages++ {
+		assert.NoError(t, history.Append(&RunSummary{Site: "abc.com", Pages: pages, Alerts: []Alert{}}))
+	}
+
+	summaries, err = history.Read()
+	assert.NoError(t, err)
+	assert.Len(t, summaries, 3)
+	assert.Equal(t, 3, summaries[0].Pages)
+	assert.Equal(t, 5, summaries[2].Pages)
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notifier sends the summary of a run of a Monitor that has alerts or failed.
+type Notifier interface {
+	Notify(ctx context.Context, summary *RunSummary) error
+}
+
+// NotifierFunc makes a Notifier of a function.
+type NotifierFunc func(ctx context.Context, summary *RunSummary) error
+
+// Notify calls f.
+func (f NotifierFunc) Notify(ctx context.Context, summary *RunSummary) error {
+	return f(ctx, summary)
+}
+
+// WriterNotifier writes the alerts for humans, one per line.
+type WriterNotifier struct {
+	w io.Writer
+}
+
+// NewWriterNotifier writes the alerts to w.
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+// Notify writes a line about the run, then one per alert.
+func (n *WriterNotifier) Notify(_ context.Context, summary *RunSummary) error {
+	var b strings.Builder
+	if summary.Error != "" {
+		fmt.Fprintf(&b, "%s: crawl of %s failed: %s\n", summary.StartedAt.Format(time.RFC3339), summary.Site, summary.Error)
+	} else {
+		fmt.Fprintf(&b, "%s: %d alerts for %s\n", summary.StartedAt.Format(time.RFC3339), len(summary.Alerts), summary.Site)
+	}
+	for _, alert := range summary.Alerts {
+		fmt.Fprintf(&b, "  %s %s", alert.Kind, alert.URL)
+		switch {
+		case alert.Old != "" && alert.New != "":
+			fmt.Fprintf(&b, ": %s -> %s", alert.Old, alert.New)
+		case alert.New != "":
+			fmt.Fprintf(&b, ": %s", alert.New)
+		case alert.Old != "":
+			fmt.Fprintf(&b, ": was %s", alert.Old)
+		}
+		if len(alert.LinkedFrom) > 0 {
+			fmt.Fprintf(&b, " (linked from %s)", strings.Join(alert.LinkedFrom, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(n.w, b.String())
+	return err
+}
+
+// FileNotifier appends the summaries to a file, one JSON line per run.
+type FileNotifier struct {
+	path string
+}
+
+// NewFileNotifier appends to the file at path, creating it if needed.
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Notify appends the summary as a JSON line.
+func (n *FileNotifier) Notify(_ context.Context, summary *RunSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(n.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// WebhookNotifier posts the summaries as JSON to a URL. Responses with a
+// status other than 2xx are errors.
+type WebhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier posts to url with httpClient, http.DefaultClient if nil.
+func NewWebhookNotifier(url string, httpClient *http.Client) *WebhookNotifier {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &WebhookNotifier{url: url, httpClient: httpClient}
+}
+
+// Notify posts the summary, canceling the request with ctx.
+func (n *WebhookNotifier) Notify(ctx context.Context, summary *RunSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", n.url, response.Status)
+	}
+
+	return nil
+}
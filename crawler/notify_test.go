@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRunSummary() *RunSummary {
+	return &RunSummary{
+		Site:       "abc.com",
+		StartedAt:  time.Date(2023, 1, 2, 6, 0, 0, 0, time.UTC),
+		FinishedAt: time.Date(2023, 1, 2, 6, 1, 0, 0, time.UTC),
+		Pages:      3,
+		Alerts: []Alert{
+			{Kind: AlertBrokenLink, URL: "https://abc.com/a", Old: "200", New: "404", LinkedFrom: []string{"https://abc.com/"}},
+			{Kind: AlertDisappeared, URL: "https://abc.com/b", Old: "200"},
+		},
+	}
+}
+
+func TestWriterNotifier_Notify_Success(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, NewWriterNotifier(&buffer).Notify(context.Background(), newTestRunSummary()))
+
+	assert.Equal(t, `2023-01-02T06:00:00Z: 2 alerts for abc.com
+  broken_link https://abc.com/a: 200 -> 404 (linked from https://abc.com/)
+  disappeared https://abc.com/b: was 200
+`, buffer.String())
+}
+
+func TestFileNotifier_Notify_Success(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.jsonl")
+	notifier := NewFileNotifier(path)
+
+	assert.NoError(t, notifier.Notify(context.Background(), newTestRunSummary()))
+	assert.NoError(t, notifier.Notify(context.Background(), &RunSummary{Site: "abc.com", Error: "no route to host"}))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Len(t, lines, 2)
+
+	var summary RunSummary
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &summary))
+	assert.Equal(t, *newTestRunSummary(), summary)
+	assert.Contains(t, lines[1], `"error":"no route to host"`)
+}
+
+func TestWebhookNotifier_Notify_Success(t *testing.T) {
+	var received []*RunSummary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var summary RunSummary
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&summary))
+		received = append(received, &summary)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, NewWebhookNotifier(server.URL, server.Client()).Notify(context.Background(), newTestRunSummary()))
+	assert.Equal(t, []*RunSummary{newTestRunSummary()}, received)
+}
+
+func TestWebhookNotifier_Notify_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL+"/hook", nil).Notify(context.Background(), newTestRunSummary())
+	assert.EqualError(t, err, "webhook "+server.URL+"/hook answered 502 Bad Gateway")
+}
@@ -0,0 +1,180 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	progressRefreshInterval = 250 * time.Millisecond
+	// DefaultProgressPlainInterval is the time between two status lines when
+	// the output is not a terminal.
+	DefaultProgressPlainInterval = 10 * time.Second
+
+	// Moves the cursor to the start of the line and clears it.
+	clearLine = "\r\033[K"
+)
+
+// IsTerminal reports whether file is a terminal rather than a regular file or
+// a pipe.
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// ProgressDisplay shows how a crawl is going, reading its Metrics. On a
+// terminal, a status line is redrawn in place every progressRefreshInterval.
+// Anywhere else, a new status line is written every plainInterval.
+type ProgressDisplay struct {
+	out         io.Writer
+	interactive bool
+	interval    time.Duration
+	metrics     *Metrics
+	now         func() time.Time
+	start       time.Time
+	last        progressSample
+	line        string
+	stop        chan struct{}
+	done        chan struct{}
+	m           sync.Mutex
+}
+
+type progressSample struct {
+	at    time.Time
+	pages uint64
+	bytes uint64
+}
+
+// NewProgressDisplay shows the progress on out, in place if it is a terminal.
+func NewProgressDisplay(out io.Writer, metrics *Metrics, plainInterval time.Duration) *ProgressDisplay {
+	if plainInterval <= 0 {
+		plainInterval = DefaultProgressPlainInterval
+	}
+
+	p := &ProgressDisplay{out: out, interval: plainInterval, metrics: metrics, now: time.Now}
+	if file, ok := out.(*os.File); ok && IsTerminal(file) {
+		p.interactive = true
+		p.interval = progressRefreshInterval
+	}
+
+	return p
+}
+
+// Start shows the progress until Stop is called.
+func (p *ProgressDisplay) Start() {
+	p.start = p.now()
+	p.last = progressSample{at: p.start}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.update()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop writes the final status line.
+func (p *ProgressDisplay) Stop() {
+	close(p.stop)
+	<-p.done
+
+	p.update()
+	if p.interactive {
+		p.m.Lock()
+		defer p.m.Unlock()
+		fmt.Fprintln(p.out)
+		p.line = ""
+	}
+}
+
+func (p *ProgressDisplay) update() {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	now := p.now()
+	sample := progressSample{
+		at:    now,
+		pages: p.metrics.pagesFetched.Value() + p.metrics.pageErrors.Value(),
+		bytes: p.metrics.bytesDownloaded.Value(),
+	}
+
+	var pagesPerSecond, bytesPerSecond float64
+	if elapsed := sample.at.Sub(p.last.at).Seconds(); elapsed > 0 {
+		pagesPerSecond = float64(sample.pages-p.last.pages) / elapsed
+		bytesPerSecond = float64(sample.bytes-p.last.bytes) / elapsed
+	}
+	p.last = sample
+
+	p.line = fmt.Sprintf("pages: %d, queued: %d, in flight: %d, errors: %d, %.1f pages/s, %s/s, elapsed: %s",
+		sample.pages, p.metrics.Queued(), p.metrics.inFlight.Value(), p.metrics.pageErrors.Value(),
+		pagesPerSecond, formatBytes(bytesPerSecond), now.Sub(p.start).Round(time.Second))
+
+	if p.interactive {
+		fmt.Fprint(p.out, clearLine+p.line)
+	} else {
+		fmt.Fprintln(p.out, p.line)
+	}
+}
+
+// LogWriter wraps the writer of the logs so that, on a terminal, log lines
+// don't get mixed up with the status line: it is cleared before every write
+// and drawn again after it.
+func (p *ProgressDisplay) LogWriter(w io.Writer) io.Writer {
+	if !p.interactive {
+		return w
+	}
+
+	return progressLogWriter{progress: p, w: w}
+}
+
+type progressLogWriter struct {
+	progress *ProgressDisplay
+	w        io.Writer
+}
+
+func (l progressLogWriter) Write(b []byte) (int, error) {
+	l.progress.m.Lock()
+	defer l.progress.m.Unlock()
+
+	if l.progress.line != "" {
+		fmt.Fprint(l.progress.out, clearLine)
+	}
+	n, err := l.w.Write(b)
+	if l.progress.line != "" {
+		fmt.Fprint(l.progress.out, l.progress.line)
+	}
+
+	return n, err
+}
+
+func formatBytes(bytes float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	unit := 0
+	for bytes >= 1000 && unit < len(units)-1 {
+		bytes /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", bytes, units[unit])
+	}
+
+	return fmt.Sprintf("%.1f %s", bytes, units[unit])
+}
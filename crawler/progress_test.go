@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsTerminal_RegularFile(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	assert.False(t, IsTerminal(file))
+	assert.False(t, NewProgressDisplay(file, NewMetrics(), 0).interactive)
+}
+
+func newTestProgressDisplay(out *bytes.Buffer) (*ProgressDisplay, *Metrics, *time.Time) {
+	metrics := NewMetrics()
+	metrics.queued = func() int { return 7 }
+
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	progress := NewProgressDisplay(out, metrics, time.Hour)
+	progress.now = func() time.Time { return now }
+
+	return progress, metrics, &now
+}
+
+func TestProgressDisplay_Plain(t *testing.T) {
+	var out bytes.Buffer
+	progress, metrics, now := newTestProgressDisplay(&out)
+	progress.Start()
+
+	metrics.pagesFetched.Add(18)
+	metrics.pageErrors.Add(2)
+	metrics.bytesDownloaded.Add(3_000_000)
+	metrics.inFlight.Inc()
+	*now = now.Add(10 * time.Second)
+	progress.update()
+
+	metrics.pagesFetched.Add(5)
+	*now = now.Add(95 * time.Second)
+	progress.Stop()
+
+	assert.Equal(t, "pages: 20, queued: 7, in flight: 1, errors: 2, 2.0 pages/s, 300.0 KB/s, elapsed: 10s\n"+
+		"pages: 25, queued: 7, in flight: 1, errors: 2, 0.1 pages/s, 0 B/s, elapsed: 1m45s\n", out.String())
+}
+
+func TestProgressDisplay_Interactive(t *testing.T) {
+	var out bytes.Buffer
+	progress, metrics, now := newTestProgressDisplay(&out)
+	progress.interactive = true
+	var logs bytes.Buffer
+	logWriter := progress.LogWriter(&logs)
+	progress.Start()
+
+	_, _ = logWriter.Write([]byte("before the first status line\n"))
+	metrics.pagesFetched.Add(4)
+	*now = now.Add(2 * time.Second)
+	progress.update()
+	_, _ = logWriter.Write([]byte("a log line\n"))
+	progress.Stop()
+
+	line := "pages: 4, queued: 7, in flight: 0, errors: 0, 2.0 pages/s, 0 B/s, elapsed: 2s"
+	finalLine := "pages: 4, queued: 7, in flight: 0, errors: 0, 0.0 pages/s, 0 B/s, elapsed: 2s"
+	assert.Equal(t, clearLine+line+clearLine+line+clearLine+finalLine+"\n", out.String())
+	assert.Equal(t, "before the first status line\na log line\n", logs.String())
+}
+
+func TestFormatBytes_Success(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "999 B", formatBytes(999))
+	assert.Equal(t, "1.5 KB", formatBytes(1500))
+	assert.Equal(t, "12.3 MB", formatBytes(12_345_678))
+	assert.Equal(t, "2000.0 TB", formatBytes(2e15))
+}
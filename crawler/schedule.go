@@ -0,0 +1,170 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when the runs of a Monitor start.
+type Schedule interface {
+	// Next returns the first start after t, or the zero time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule parses a cron expression with five fields (minute, hour, day
+// of month, month and day of week), e.g. "*/15 * * * *" or "0 6 * * 1-5", or
+// one of the shorthands @hourly, @daily, @weekly, @monthly and @every <duration>.
+// Fields are lists of values, ranges and steps; Sunday is both 0 and 7. Like
+// cron, a time matches when either of the day fields does, unless one is *.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rawInterval, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rawInterval))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: the interval must be at least a second", spec)
+		}
+		return intervalSchedule(interval), nil
+	}
+
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	var schedule cronSchedule
+	var err error
+	for i, field := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&schedule.minutes, 0, 59},
+		{&schedule.hours, 0, 23},
+		{&schedule.daysOfMonth, 1, 31},
+		{&schedule.months, 1, 12},
+		{&schedule.daysOfWeek, 0, 7},
+	} {
+		if *field.bits, err = parseCronField(fields[i], field.min, field.max); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+	if schedule.daysOfWeek&(1<<7) != 0 {
+		schedule.daysOfWeek |= 1
+	}
+	schedule.anyDayOfMonth = fields[2] == "*"
+	schedule.anyDayOfWeek = fields[4] == "*"
+
+	return &schedule, nil
+}
+
+// parseCronField returns the values of a field as a bit set.
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangeSpec, rawStep, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(rawStep); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+		}
+
+		first, last := min, max
+		if rangeSpec != "*" {
+			rawFirst, rawLast, isRange := strings.Cut(rangeSpec, "-")
+			var err error
+			if first, err = strconv.Atoi(rawFirst); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", item)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(rawLast); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", item)
+				}
+			} else if hasStep {
+				last = max
+			}
+		}
+		if first < min || last > max || first > last {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", item, min, max)
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << value
+		}
+	}
+
+	return bits, nil
+}
+
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+type cronSchedule struct {
+	minutes       uint64
+	hours         uint64
+	daysOfMonth   uint64
+	months        uint64
+	daysOfWeek    uint64
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// Next looks for the first matching minute after t in the location of t,
+// skipping whole months, days and hours that do not match. Schedules that
+// never match, e.g. on February 30, give up after a few years.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	giveUp := t.AddDate(5, 0, 0)
+
+	for t.Before(giveUp) {
+		if s.months&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hours&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minutes&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth&(1<<t.Day()) != 0
+	dayOfWeek := s.daysOfWeek&(1<<int(t.Weekday())) != 0
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
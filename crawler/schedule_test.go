@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule_Success(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2023, 3, 15, 10, 7, 30, 0, time.UTC)
+
+	for spec, expected := range map[string]time.Time{
+		"*/15 * * * *":     time.Date(2023, 3, 15, 10, 15, 0, 0, time.UTC),
+		"5,40 * * * *":     time.Date(2023, 3, 15, 10, 40, 0, 0, time.UTC),
+		"0 6 * * 1-5":      time.Date(2023, 3, 16, 6, 0, 0, 0, time.UTC),
+		"30 2 * * 7":       time.Date(2023, 3, 19, 2, 30, 0, 0, time.UTC),
+		"0 0 1 * *":        time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * 5":        time.Date(2023, 3, 17, 0, 0, 0, 0, time.UTC),
+		"0 12 29 2 *":      time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		"10-20/5 10 * * *": time.Date(2023, 3, 15, 10, 10, 0, 0, time.UTC),
+		"@hourly":          time.Date(2023, 3, 15, 11, 0, 0, 0, time.UTC),
+		"@daily":           time.Date(2023, 3, 16, 0, 0, 0, 0, time.UTC),
+		"@weekly":          time.Date(2023, 3, 19, 0, 0, 0, 0, time.UTC),
+		"@every 90s":       time.Date(2023, 3, 15, 10, 9, 0, 0, time.UTC),
+	} {
+		schedule, err := ParseSchedule(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected, schedule.Next(now), spec)
+	}
+}
+
+func TestParseSchedule_Never(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)).IsZero())
+}
+
+func TestParseSchedule_Error(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"* * * *":       `invalid schedule "* * * *": expected 5 fields, got 4`,
+		"60 * * * *":    `invalid schedule "60 * * * *": "60" is out of the range 0-59`,
+		"* * 0 * *":     `invalid schedule "* * 0 * *": "0" is out of the range 1-31`,
+		"*/0 * * * *":   `invalid schedule "*/0 * * * *": invalid step in "*/0"`,
+		"a * * * *":     `invalid schedule "a * * * *": invalid value in "a"`,
+		"5-1 * * * *":   `invalid schedule "5-1 * * * *": "5-1" is out of the range 0-59`,
+		"@every 10ms":   `invalid schedule "@every 10ms": the interval must be at least a second`,
+		"@every minute": `invalid schedule "@every minute": time: invalid duration "minute"`,
+	} {
+		_, err := ParseSchedule(spec)
+		assert.EqualError(t, err, expected, spec)
+	}
+}
@@ -0,0 +1,315 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultSlowestPages is the number of pages listed by the timing report of
+// the command line.
+const DefaultSlowestPages = 10
+
+// RequestTiming breaks down the time spent on one attempt at a request. Wait
+// is the time before the request went out, e.g. waiting for a slot of the host
+// or reading the cache, and is not part of Total. DNS, Connect and
+// TLSHandshake are zero when a connection was reused or when the Fetcher
+// doesn't go over the network. TimeToFirstByte is measured from the moment the
+// request asked for a connection, or from the start of the attempt without
+// network, Download from the first byte of the response to the end of its
+// body. Err is the error of a failed attempt.
+type RequestTiming struct {
+	Method           string
+	Attempt          int
+	Wait             time.Duration
+	DNS              time.Duration
+	Connect          time.Duration
+	TLSHandshake     time.Duration
+	TimeToFirstByte  time.Duration
+	Download         time.Duration
+	Total            time.Duration
+	ReusedConnection bool
+	Err              error
+}
+
+// requestTrace records a RequestTiming through the hooks of httptrace, which
+// the HTTPFetcher calls as the request goes. Hooks can be called from several
+// goroutines, e.g. when dialing more than one address at once.
+type requestTrace struct {
+	timing       *RequestTiming
+	start        time.Time
+	sent         time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	firstByte    time.Time
+	m            sync.Mutex
+}
+
+func newRequestTrace(method string, attempt int) *requestTrace {
+	return &requestTrace{timing: &RequestTiming{Method: method, Attempt: attempt}, start: time.Now()}
+}
+
+func (r *requestTrace) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			if r.sent.IsZero() {
+				r.sent = time.Now()
+				r.timing.Wait = r.sent.Sub(r.start)
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			r.timing.DNS = time.Since(r.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_ string, _ string, err error) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			if err == nil && r.timing.Connect == 0 {
+				r.timing.Connect = time.Since(r.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			r.m.Lock()
+			defer r.m.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			r.timing.TLSHandshake = time.Since(r.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.m.Lock()
+			defer r.m.Unlock()
+			r.timing.ReusedConnection = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			r.m.Lock()
+			defer r.m.Unlock()
+			r.firstByte = time.Now()
+		},
+	})
+}
+
+// responded records the end of an attempt that got a response. Fetchers that
+// don't go over the network call no hook, the duration of their response is
+// taken as the time to first byte then.
+func (r *requestTrace) responded(response *FetchResponse) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.firstByte.IsZero() {
+		r.timing.TimeToFirstByte = response.Duration
+		if r.timing.TimeToFirstByte <= 0 {
+			r.timing.TimeToFirstByte = time.Since(r.sentAt())
+		}
+		r.firstByte = r.sentAt().Add(r.timing.TimeToFirstByte)
+	} else {
+		r.timing.TimeToFirstByte = r.firstByte.Sub(r.sentAt())
+	}
+	r.timing.Total = r.timing.TimeToFirstByte
+}
+
+func (r *requestTrace) failed(err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.timing.Err = err
+	r.timing.Total = time.Since(r.sentAt())
+}
+
+// sentAt returns when the request went out, the start of the attempt for
+// Fetchers that don't go over the network.
+func (r *requestTrace) sentAt() time.Time {
+	if r.sent.IsZero() {
+		return r.start
+	}
+
+	return r.sent
+}
+
+// downloaded records the end of the body of the response.
+func (r *requestTrace) downloaded() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.timing.Download = time.Since(r.firstByte)
+	r.timing.Total = r.timing.TimeToFirstByte + r.timing.Download
+}
+
+// timingsOf returns copies of the timings of traces, as hooks of an attempt
+// can still be called once it is over.
+func timingsOf(traces []*requestTrace) []*RequestTiming {
+	timings := make([]*RequestTiming, 0, len(traces))
+	for _, trace := range traces {
+		trace.m.Lock()
+		timing := *trace.timing
+		trace.m.Unlock()
+		timings = append(timings, &timing)
+	}
+
+	return timings
+}
+
+// PageTiming is the time spent on a page, every attempt at every request
+// included.
+type PageTiming struct {
+	TargetURL *url.URL
+	Pattern   string
+	Total     time.Duration
+	Timings   []*RequestTiming
+}
+
+// PatternTiming sums up the time spent on the pages of a URL pattern (see
+// URLPatternOf).
+type PatternTiming struct {
+	Pattern string
+	Pages   int
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// TimingReport collects the timings of the pages of a crawl to find the
+// slowest ones.
+type TimingReport struct {
+	pages []*PageTiming
+	m     sync.Mutex
+}
+
+// NewTimingReport returns an empty report.
+func NewTimingReport() *TimingReport {
+	return &TimingReport{}
+}
+
+// ObservePage adds the timings of a page. Pages that were not requested, e.g.
+// because of their extension, are left out.
+func (r *TimingReport) ObservePage(linksForTargetURL *LinksByTargetURL) {
+	if len(linksForTargetURL.Timings) == 0 {
+		return
+	}
+
+	page := &PageTiming{
+		TargetURL: linksForTargetURL.TargetURL,
+		Pattern:   URLPatternOf(linksForTargetURL.TargetURL),
+		Timings:   linksForTargetURL.Timings,
+	}
+	for _, timing := range page.Timings {
+		page.Total += timing.Total
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.pages = append(r.pages, page)
+}
+
+// Slowest returns the n slowest pages, slowest first.
+func (r *TimingReport) Slowest(n int) []*PageTiming {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	pages := append([]*PageTiming(nil), r.pages...)
+	sort.SliceStable(pages, func(i, j int) bool {
+		if pages[i].Total != pages[j].Total {
+			return pages[i].Total > pages[j].Total
+		}
+		return pages[i].TargetURL.String() < pages[j].TargetURL.String()
+	})
+
+	return pages[:min(n, len(pages))]
+}
+
+// Patterns returns the percentiles of the page times of every URL pattern,
+// the slowest patterns (by p90) first.
+func (r *TimingReport) Patterns() []*PatternTiming {
+	r.m.Lock()
+	totalsByPattern := make(map[string][]time.Duration)
+	for _, page := range r.pages {
+		totalsByPattern[page.Pattern] = append(totalsByPattern[page.Pattern], page.Total)
+	}
+	r.m.Unlock()
+
+	patterns := make([]*PatternTiming, 0, len(totalsByPattern))
+	for _, pattern := range sortedKeys(totalsByPattern) {
+		totals := totalsByPattern[pattern]
+		sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+		patterns = append(patterns, &PatternTiming{
+			Pattern: pattern,
+			Pages:   len(totals),
+			P50:     percentileOf(totals, 50),
+			P90:     percentileOf(totals, 90),
+			P99:     percentileOf(totals, 99),
+			Max:     totals[len(totals)-1],
+		})
+	}
+	sort.SliceStable(patterns, func(i, j int) bool { return patterns[i].P90 > patterns[j].P90 })
+
+	return patterns
+}
+
+// percentileOf returns the pth percentile of sorted with the nearest-rank
+// method.
+func percentileOf(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+
+	return sorted[max(rank, 1)-1]
+}
+
+// WriteTable writes the n slowest pages, with the breakdown of their last
+// attempt, then the percentiles of every URL pattern.
+func (r *TimingReport) WriteTable(w io.Writer, n int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tTOTAL\tATTEMPTS\tWAIT\tDNS\tCONNECT\tTLS\tTTFB\tDOWNLOAD")
+	for _, page := range r.Slowest(n) {
+		last := page.Timings[len(page.Timings)-1]
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", page.TargetURL, roundDuration(page.Total), len(page.Timings),
+			roundDuration(last.Wait), roundDuration(last.DNS), roundDuration(last.Connect), roundDuration(last.TLSHandshake),
+			roundDuration(last.TimeToFirstByte), roundDuration(last.Download))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATTERN\tPAGES\tP50\tP90\tP99\tMAX")
+	for _, pattern := range r.Patterns() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", pattern.Pattern, pattern.Pages,
+			roundDuration(pattern.P50), roundDuration(pattern.P90), roundDuration(pattern.P99), roundDuration(pattern.Max))
+	}
+
+	return tw.Flush()
+}
+
+func roundDuration(duration time.Duration) time.Duration {
+	if duration < time.Millisecond {
+		return duration.Round(time.Microsecond)
+	}
+
+	return duration.Round(time.Millisecond)
+}
@@ -0,0 +1,148 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCrawler_GetLinksForTargetURL_Timings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`<a href="/a">A</a>`))
+	}))
+	defer server.Close()
+
+	var failed bool
+	httpFetcher := NewHTTPFetcher(server.Client())
+	fetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("connection reset")
+		}
+		return httpFetcher.Fetch(ctx, request)
+	})
+
+	crawler := New(WithFetcher(fetcher), WithRetryAttempts(2))
+	linksForTargetURL, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, server.URL))
+	assert.NoError(t, err)
+
+	assert.Len(t, linksForTargetURL.Timings, 2)
+	failedAttempt, attempt := linksForTargetURL.Timings[0], linksForTargetURL.Timings[1]
+	assert.Equal(t, 1, failedAttempt.Attempt)
+	assert.EqualError(t, failedAttempt.Err, "connection reset")
+
+	assert.Equal(t, http.MethodGet, attempt.Method)
+	assert.Equal(t, 2, attempt.Attempt)
+	assert.NoError(t, attempt.Err)
+	assert.False(t, attempt.ReusedConnection)
+	assert.Positive(t, attempt.Connect)
+	assert.Positive(t, attempt.TLSHandshake)
+	assert.GreaterOrEqual(t, attempt.TimeToFirstByte, attempt.Connect+attempt.TLSHandshake)
+	assert.GreaterOrEqual(t, attempt.Download, 20*time.Millisecond)
+	assert.Equal(t, attempt.TimeToFirstByte+attempt.Download, attempt.Total)
+}
+
+func TestCrawler_GetLinksForTargetURL_TimingsWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<a href="/a">A</a>`))
+	}))
+	defer server.Close()
+
+	// Stands for a request waiting for a slot of its host.
+	httpFetcher := NewHTTPFetcher(server.Client())
+	fetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		time.Sleep(200 * time.Millisecond)
+		return httpFetcher.Fetch(ctx, request)
+	})
+
+	crawler := New(WithFetcher(fetcher), WithRetryAttempts(1))
+	linksForTargetURL, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, server.URL))
+	assert.NoError(t, err)
+
+	assert.Len(t, linksForTargetURL.Timings, 1)
+	attempt := linksForTargetURL.Timings[0]
+	assert.GreaterOrEqual(t, attempt.Wait, 200*time.Millisecond)
+	assert.Less(t, attempt.TimeToFirstByte, 200*time.Millisecond)
+	assert.Equal(t, attempt.TimeToFirstByte+attempt.Download, attempt.Total)
+}
+
+func TestCrawler_GetLinksForTargetURL_TimingsWithoutNetwork(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com/image.png": NewMemoryPage(http.StatusOK, "image/png", ``),
+	})
+
+	crawler := New(WithFetcher(fetcher), WithRetryAttempts(1), WithHeadBeforeGet(true), WithDeniedExtensions())
+	linksForTargetURL, err := crawler.GetLinksForTargetURL(context.Background(), makeURLFor(t, "https://abc.com/image.png"))
+	assert.NoError(t, err)
+
+	assert.True(t, linksForTargetURL.Skipped())
+	assert.Len(t, linksForTargetURL.Timings, 1)
+	assert.Equal(t, http.MethodHead, linksForTargetURL.Timings[0].Method)
+	assert.Zero(t, linksForTargetURL.Timings[0].Connect)
+	assert.Equal(t, linksForTargetURL.Timings[0].TimeToFirstByte, linksForTargetURL.Timings[0].Total)
+}
+
+func TestTimingReport_Success(t *testing.T) {
+	report := NewTimingReport()
+	observe := func(rawURL string, totals ...time.Duration) {
+		linksForTargetURL := &LinksByTargetURL{TargetURL: makeURLFor(t, rawURL)}
+		for i, total := range totals {
+			linksForTargetURL.Timings = append(linksForTargetURL.Timings, &RequestTiming{
+				Method:          http.MethodGet,
+				Attempt:         i + 1,
+				TimeToFirstByte: total / 2,
+				Download:        total / 2,
+				Total:           total,
+			})
+		}
+		report.ObservePage(linksForTargetURL)
+	}
+
+	observe("https://abc.com/posts/1", 100*time.Millisecond)
+	observe("https://abc.com/posts/2", 300*time.Millisecond)
+	observe("https://abc.com/posts/3", 200*time.Millisecond, 500*time.Millisecond)
+	observe("https://abc.com/about", 50*time.Millisecond)
+	observe("https://abc.com/denied.png")
+
+	slowest := report.Slowest(2)
+	assert.Len(t, slowest, 2)
+	assert.Equal(t, "https://abc.com/posts/3", slowest[0].TargetURL.String())
+	assert.Equal(t, 700*time.Millisecond, slowest[0].Total)
+	assert.Equal(t, "https://abc.com/posts/2", slowest[1].TargetURL.String())
+	assert.Len(t, report.Slowest(10), 4)
+
+	assert.Equal(t, []*PatternTiming{
+		{Pattern: "abc.com/posts/{n}", Pages: 3, P50: 300 * time.Millisecond, P90: 700 * time.Millisecond, P99: 700 * time.Millisecond, Max: 700 * time.Millisecond},
+		{Pattern: "abc.com/about", Pages: 1, P50: 50 * time.Millisecond, P90: 50 * time.Millisecond, P99: 50 * time.Millisecond, Max: 50 * time.Millisecond},
+	}, report.Patterns())
+
+	var output bytes.Buffer
+	assert.NoError(t, report.WriteTable(&output, 2))
+	assert.Equal(t, "URL                      TOTAL  ATTEMPTS  WAIT  DNS  CONNECT  TLS  TTFB   DOWNLOAD\n"+
+		"https://abc.com/posts/3  700ms  2         0s    0s   0s       0s   250ms  250ms\n"+
+		"https://abc.com/posts/2  300ms  1         0s    0s   0s       0s   150ms  150ms\n"+
+		"\n"+
+		"PATTERN            PAGES  P50    P90    P99    MAX\n"+
+		"abc.com/posts/{n}  3      300ms  700ms  700ms  700ms\n"+
+		"abc.com/about      1      50ms   50ms   50ms   50ms\n", output.String())
+}
+
+func TestPercentileOf_Success(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, time.Duration(1), percentileOf(sorted, 0))
+	assert.Equal(t, time.Duration(5), percentileOf(sorted, 50))
+	assert.Equal(t, time.Duration(9), percentileOf(sorted, 90))
+	assert.Equal(t, time.Duration(10), percentileOf(sorted, 99))
+	assert.Equal(t, time.Duration(1), percentileOf(sorted[:1], 99))
+}
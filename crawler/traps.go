@@ -0,0 +1,249 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Defaults of the TrapDetectorParams.
+const (
+	DefaultMaxRepeatedSegments = 3
+	DefaultMaxPathDepth        = 20
+	DefaultMaxPathLength       = 2000
+	DefaultMaxQueryVariations  = 100
+	DefaultMaxPagesPerPattern  = 5000
+
+	// Traps found through a single URL (path too long or too deep) suppress
+	// every URL under the first segments of its pattern.
+	trapPrefixSegments = 3
+)
+
+var (
+	numberPattern     = regexp.MustCompile(`[0-9]+`)
+	identifierPattern = regexp.MustCompile(`^[0-9a-zA-Z_-]{16,}$`)
+)
+
+// TrapDetectorParams holds the thresholds of the TrapDetector. Zero values
+// use the defaults. MaxPathLength only counts the path: long query strings
+// are caught by MaxQueryVariations.
+type TrapDetectorParams struct {
+	MaxRepeatedSegments int
+	MaxPathDepth        int
+	MaxPathLength       int
+	MaxQueryVariations  int
+	MaxPagesPerPattern  int
+}
+
+// Trap is a URL pattern the TrapDetector stopped crawling. Patterns ending
+// with "/**" match every URL under them. Suppressed counts the pages that were
+// dropped because of it, including the one that revealed the trap when it was
+// not crawled yet.
+type Trap struct {
+	Pattern    string
+	Reason     string
+	Example    string
+	Suppressed int
+}
+
+// TrapDetector spots spider traps (calendars, faceted navigation, session IDs
+// in paths, etc.) from the URLs about to be crawled, using heuristics over
+// URL patterns. A pattern is the host and path of a URL with numbers replaced
+// by {n} and long identifiers by {id}, e.g. abc.com/calendar/{n}/{n}.
+type TrapDetector struct {
+	params          TrapDetectorParams
+	traps           map[string]*Trap
+	pagesPerPattern map[string]int
+	queriesPerPath  map[string]map[string]bool
+	m               sync.Mutex
+}
+
+// NewTrapDetector returns a detector that has seen no URL yet.
+func NewTrapDetector(params TrapDetectorParams) *TrapDetector {
+	if params.MaxRepeatedSegments <= 0 {
+		params.MaxRepeatedSegments = DefaultMaxRepeatedSegments
+	}
+	if params.MaxPathDepth <= 0 {
+		params.MaxPathDepth = DefaultMaxPathDepth
+	}
+	if params.MaxPathLength <= 0 {
+		params.MaxPathLength = DefaultMaxPathLength
+	}
+	if params.MaxQueryVariations <= 0 {
+		params.MaxQueryVariations = DefaultMaxQueryVariations
+	}
+	if params.MaxPagesPerPattern <= 0 {
+		params.MaxPagesPerPattern = DefaultMaxPagesPerPattern
+	}
+
+	return &TrapDetector{
+		params:          params,
+		traps:           make(map[string]*Trap),
+		pagesPerPattern: make(map[string]int),
+		queriesPerPath:  make(map[string]map[string]bool),
+	}
+}
+
+// Allow reports whether a URL should be crawled. It is meant to be called for
+// every link, newPage telling whether the crawler had not seen its page yet
+// (see Crawler.MarkPageAsVisited): a page counts once for its pattern, but
+// each of its query strings counts as a variation.
+func (d *TrapDetector) Allow(targetURL *url.URL, newPage bool) bool {
+	segments := pathSegments(targetURL.Path)
+	templates := templatesOf(segments)
+	pattern := patternOf(targetURL.Host, templates)
+
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	for _, trap := range d.traps {
+		if trap.matches(pattern) {
+			if newPage {
+				trap.Suppressed++
+			}
+			return false
+		}
+	}
+
+	if newPage {
+		if tripped := tooLongSegment(segments, d.params.MaxPathLength); tripped >= 0 {
+			return d.trap(targetURL, prefixPatternOf(targetURL.Host, templates, max(trapPrefixSegments, tripped+1)), fmt.Sprintf("path is longer than %d characters", d.params.MaxPathLength), 1)
+		}
+
+		if len(segments) > d.params.MaxPathDepth {
+			return d.trap(targetURL, prefixPatternOf(targetURL.Host, templates, trapPrefixSegments), fmt.Sprintf("path is deeper than %d segments", d.params.MaxPathDepth), 1)
+		}
+
+		occurrences := make(map[string]int)
+		for _, segment := range segments {
+			occurrences[segment]++
+			if occurrences[segment] > d.params.MaxRepeatedSegments {
+				first := indexOf(segments, segment)
+				return d.trap(targetURL, prefixPatternOf(targetURL.Host, templates, first+1), fmt.Sprintf("path segment %q repeats more than %d times", segment, d.params.MaxRepeatedSegments), 1)
+			}
+		}
+
+		d.pagesPerPattern[pattern]++
+		if d.pagesPerPattern[pattern] > d.params.MaxPagesPerPattern {
+			return d.trap(targetURL, pattern, fmt.Sprintf("more than %d pages match the pattern", d.params.MaxPagesPerPattern), 1)
+		}
+	}
+
+	if targetURL.RawQuery != "" {
+		queries, ok := d.queriesPerPath[pattern]
+		if !ok {
+			queries = make(map[string]bool)
+			d.queriesPerPath[pattern] = queries
+		}
+		queries[targetURL.RawQuery] = true
+		if len(queries) > d.params.MaxQueryVariations {
+			delete(d.queriesPerPath, pattern)
+			suppressed := 0
+			if newPage {
+				suppressed = 1
+			}
+			return d.trap(targetURL, pattern, fmt.Sprintf("more than %d query string variations", d.params.MaxQueryVariations), suppressed)
+		}
+	}
+
+	return true
+}
+
+func (d *TrapDetector) trap(targetURL *url.URL, pattern string, reason string, suppressed int) bool {
+	d.traps[pattern] = &Trap{Pattern: pattern, Reason: reason, Example: targetURL.String(), Suppressed: suppressed}
+	return false
+}
+
+// Report returns the traps detected so far, sorted by pattern.
+func (d *TrapDetector) Report() []*Trap {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	var traps []*Trap
+	for _, trap := range d.traps {
+		trapCopy := *trap
+		traps = append(traps, &trapCopy)
+	}
+	sort.Slice(traps, func(i, j int) bool { return traps[i].Pattern < traps[j].Pattern })
+
+	return traps
+}
+
+func (t *Trap) matches(pattern string) bool {
+	if prefix, ok := strings.CutSuffix(t.Pattern, "**"); ok {
+		return strings.HasPrefix(pattern+"/", prefix)
+	}
+
+	return t.Pattern == pattern
+}
+
+func pathSegments(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
+
+func templatesOf(segments []string) []string {
+	templates := make([]string, len(segments))
+	for i, segment := range segments {
+		if identifierPattern.MatchString(segment) && numberPattern.MatchString(segment) {
+			templates[i] = "{id}"
+		} else {
+			templates[i] = numberPattern.ReplaceAllString(segment, "{n}")
+		}
+	}
+
+	return templates
+}
+
+// URLPatternOf returns the pattern of a URL, as used by the TrapDetector.
+func URLPatternOf(targetURL *url.URL) string {
+	return patternOf(targetURL.Host, templatesOf(pathSegments(targetURL.Path)))
+}
+
+func patternOf(host string, templates []string) string {
+	return host + "/" + strings.Join(templates, "/")
+}
+
+// prefixPatternOf returns the pattern of every URL under the first segments of
+// templates. It keeps at least one segment, so that a trap never takes a whole
+// host, and is the exact pattern of the root.
+func prefixPatternOf(host string, templates []string, segments int) string {
+	if len(templates) == 0 {
+		return patternOf(host, templates)
+	}
+
+	return patternOf(host, templates[:min(max(segments, 1), len(templates))]) + "/**"
+}
+
+// tooLongSegment returns the index of the segment with which the path goes
+// past maxLength characters, -1 if it does not.
+func tooLongSegment(segments []string, maxLength int) int {
+	length := 0
+	for i, segment := range segments {
+		length += len("/") + len(segment)
+		if length > maxLength {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,159 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestURLPatternOf_Success(t *testing.T) {
+	assert.Equal(t, "abc.com/", URLPatternOf(makeURLFor(t, "https://abc.com")))
+	assert.Equal(t, "abc.com/calendar/{n}/{n}", URLPatternOf(makeURLFor(t, "https://abc.com/calendar/2023/01?view=month")))
+	assert.Equal(t, "abc.com/blog/page-{n}", URLPatternOf(makeURLFor(t, "https://abc.com/blog/page-12/")))
+	assert.Equal(t, "abc.com/session/{id}/cart", URLPatternOf(makeURLFor(t, "https://abc.com/session/9f86d081884c7d65/cart")))
+	assert.Equal(t, "abc.com/a-very-long-slug-without-ids", URLPatternOf(makeURLFor(t, "https://abc.com/a-very-long-slug-without-ids")))
+}
+
+func TestTrapDetector_RepeatingSegments(t *testing.T) {
+	detector := NewTrapDetector(TrapDetectorParams{MaxRepeatedSegments: 2})
+
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/a/b/a/b"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/a/b/a/b/a/b"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/a/c"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/c/a"), true))
+
+	report := detector.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "abc.com/a/**", report[0].Pattern)
+	assert.Equal(t, `path segment "a" repeats more than 2 times`, report[0].Reason)
+	assert.Equal(t, "https://abc.com/a/b/a/b/a/b", report[0].Example)
+	assert.Equal(t, 2, report[0].Suppressed)
+}
+
+func TestTrapDetector_DepthAndLength(t *testing.T) {
+	detector := NewTrapDetector(TrapDetectorParams{MaxPathDepth: 4, MaxPathLength: 20})
+	longSegment := strings.Repeat("y", 20)
+
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/a/b/c/d"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/a/b/c/d/e"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/a/b/c/x"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/a/b/x"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/x?"+strings.Repeat("q", 40)), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/"+longSegment), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/"+longSegment+"/a"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/x"), true))
+
+	report := detector.Report()
+	assert.Len(t, report, 2)
+	assert.Equal(t, "abc.com/a/b/c/**", report[0].Pattern)
+	assert.Equal(t, "path is deeper than 4 segments", report[0].Reason)
+	assert.Equal(t, "abc.com/"+longSegment+"/**", report[1].Pattern)
+	assert.Equal(t, "path is longer than 20 characters", report[1].Reason)
+}
+
+func TestTrapDetector_LongQueryAtRoot(t *testing.T) {
+	detector := NewTrapDetector(TrapDetectorParams{MaxPathLength: 20, MaxQueryVariations: 1})
+
+	// A long query string is no reason to stop crawling the host.
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/?utm="+strings.Repeat("q", 2000)), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/a"), true))
+	assert.Empty(t, detector.Report())
+
+	// Too many of them only stop the root.
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/?utm="+strings.Repeat("r", 2000)), false))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/b"), true))
+	assert.Len(t, detector.Report(), 1)
+	assert.Equal(t, "abc.com/", detector.Report()[0].Pattern)
+}
+
+func TestTrapDetector_PagesPerPattern(t *testing.T) {
+	detector := NewTrapDetector(TrapDetectorParams{MaxPagesPerPattern: 3})
+
+	for day := 1; day <= 3; day++ {
+		assert.True(t, detector.Allow(makeURLFor(t, fmt.Sprintf("https://abc.com/calendar/2023/01/%02d", day)), true))
+	}
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/calendar/2023/01/04"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/calendar/2024/12/31"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/calendar/2023/01"), true))
+
+	report := detector.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "abc.com/calendar/{n}/{n}/{n}", report[0].Pattern)
+	assert.Equal(t, 2, report[0].Suppressed)
+}
+
+func TestTrapDetector_QueryVariations(t *testing.T) {
+	detector := NewTrapDetector(TrapDetectorParams{MaxQueryVariations: 2})
+
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/shop/1?color=red"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/shop/2?color=red&size=m"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/shop/3?size=m&color=red"), true))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/shop/4"), true))
+
+	report := detector.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "abc.com/shop/{n}", report[0].Pattern)
+	assert.Equal(t, "more than 2 query string variations", report[0].Reason)
+}
+
+func TestTrapDetector_QueryVariationsOfSeenPages(t *testing.T) {
+	detector := NewTrapDetector(TrapDetectorParams{MaxQueryVariations: 2, MaxPagesPerPattern: 1})
+
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/list?sort=a"), true))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/list?sort=b"), false))
+	assert.True(t, detector.Allow(makeURLFor(t, "https://abc.com/list#top"), false))
+	assert.False(t, detector.Allow(makeURLFor(t, "https://abc.com/list?sort=c"), false))
+
+	report := detector.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "abc.com/list", report[0].Pattern)
+	assert.Equal(t, "more than 2 query string variations", report[0].Reason)
+	assert.Equal(t, 0, report[0].Suppressed)
+}
+
+func TestCrawler_GetAllLinksFor_SpiderTrapInQuery(t *testing.T) {
+	fetcher := NewMemoryFetcher(map[string]*MemoryPage{
+		"https://abc.com":             NewMemoryPage(http.StatusOK, "text/html", `<a href="/list?sort=a">a</a><a href="/list?sort=b">b</a><a href="/list?sort=c">c</a>`),
+		"https://abc.com/list?sort=a": NewMemoryPage(http.StatusOK, "text/html", ""),
+	})
+
+	var processed []string
+	detector := NewTrapDetector(TrapDetectorParams{MaxQueryVariations: 2})
+	crawler := New(WithFetcher(fetcher), WithWorkers(1), WithRetryAttempts(1), WithTrapDetector(detector))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com"), func(page *LinksByTargetURL) {
+		processed = append(processed, page.TargetURL.String())
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.ElementsMatch(t, []string{"https://abc.com", "https://abc.com/list?sort=a"}, processed)
+	assert.Len(t, detector.Report(), 1)
+	assert.Equal(t, "abc.com/list", detector.Report()[0].Pattern)
+}
+
+func TestCrawler_GetAllLinksFor_SpiderTrap(t *testing.T) {
+	// Every calendar page links to the next day, forever.
+	fetcher := FetcherFunc(func(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+		var day int
+		_, _ = fmt.Sscanf(request.URL.Path, "/calendar/%d", &day)
+		return NewMemoryFetcher(map[string]*MemoryPage{
+			request.URL.String(): NewMemoryPage(http.StatusOK, "text/html", fmt.Sprintf(`<a href="/calendar/%d">next</a>`, day+1)),
+		}).Fetch(ctx, request)
+	})
+
+	var processed int
+	detector := NewTrapDetector(TrapDetectorParams{MaxPagesPerPattern: 10})
+	crawler := New(WithFetcher(fetcher), WithWorkers(2), WithRetryAttempts(1), WithTrapDetector(detector))
+	crawler.GetAllLinksFor(context.Background(), makeURLFor(t, "https://abc.com/calendar/0"), func(*LinksByTargetURL) {
+		processed++
+	}, func(err error) {
+		assert.NoError(t, err)
+	})
+
+	assert.Equal(t, 11, processed)
+	assert.Len(t, detector.Report(), 1)
+	assert.Equal(t, "abc.com/calendar/{n}", detector.Report()[0].Pattern)
+}
@@ -0,0 +1,322 @@
+package crawler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	// DefaultBloomFalsePositiveRate and DefaultBloomInitialCapacity are used
+	// by NewBloomVisitedSet for invalid values.
+	DefaultBloomFalsePositiveRate = 0.001
+	DefaultBloomInitialCapacity   = 1 << 16
+
+	// Every new filter of a BloomVisitedSet holds bloomGrowthFactor times more
+	// keys than the previous one, with a false positive rate bloomTighteningRatio
+	// times smaller, so that the overall rate stays under the configured one.
+	bloomGrowthFactor    = 2
+	bloomTighteningRatio = 0.5
+
+	diskSlotSize       = 16
+	diskInitialSlots   = 1 << 16
+	diskReadBufferSize = 4096 * diskSlotSize
+)
+
+// VisitedSet remembers the pages seen during a crawl. Add reports whether the
+// key was new. Implementations don't have to be safe for concurrent use, the
+// Crawler serializes the calls.
+type VisitedSet interface {
+	Add(key string) bool
+	Close() error
+}
+
+// VisitedSetTypes are the names NewVisitedSet accepts.
+var VisitedSetTypes = []string{"map", "bloom", "disk"}
+
+// NewVisitedSet creates a VisitedSet by name. falsePositiveRate only applies
+// to the bloom set and dir to the disk one.
+func NewVisitedSet(setType string, falsePositiveRate float64, dir string) (VisitedSet, error) {
+	switch setType {
+	case "map":
+		return NewMapVisitedSet(), nil
+	case "bloom":
+		return NewBloomVisitedSet(DefaultBloomInitialCapacity, falsePositiveRate), nil
+	case "disk":
+		return NewDiskVisitedSet(dir)
+	}
+
+	return nil, fmt.Errorf("unknown visited set %q, expected one of %v", setType, VisitedSetTypes)
+}
+
+// MapVisitedSet keeps every key in memory. It is exact, but its memory usage
+// grows with the length of the URLs.
+type MapVisitedSet struct {
+	keys map[string]struct{}
+}
+
+// NewMapVisitedSet returns an empty set.
+func NewMapVisitedSet() *MapVisitedSet {
+	return &MapVisitedSet{keys: make(map[string]struct{})}
+}
+
+// Add reports whether key was new.
+func (s *MapVisitedSet) Add(key string) bool {
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+
+	s.keys[key] = struct{}{}
+	return true
+}
+
+// Close does nothing.
+func (s *MapVisitedSet) Close() error {
+	return nil
+}
+
+// BloomVisitedSet is a scalable Bloom filter: a chain of Bloom filters where a
+// new, larger one is added whenever the last one is full. It uses a few bits
+// per key no matter how long the URLs are, at the cost of reporting some new
+// keys as already seen (and so skipping their pages) at falsePositiveRate.
+type BloomVisitedSet struct {
+	filters           []*bloomFilter
+	capacity          int
+	falsePositiveRate float64
+	seeds             [2]maphash.Seed
+}
+
+type bloomFilter struct {
+	bits      []uint64
+	numOfBits uint64
+	numOfHash int
+	capacity  int
+	count     int
+}
+
+// NewBloomVisitedSet starts with a filter holding initialCapacity keys. The
+// rate must be between 0 and 1 excluded.
+func NewBloomVisitedSet(initialCapacity int, falsePositiveRate float64) *BloomVisitedSet {
+	if initialCapacity <= 0 {
+		initialCapacity = DefaultBloomInitialCapacity
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = DefaultBloomFalsePositiveRate
+	}
+
+	s := &BloomVisitedSet{
+		capacity:          initialCapacity,
+		falsePositiveRate: falsePositiveRate * (1 - bloomTighteningRatio),
+		seeds:             [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+	s.filters = append(s.filters, newBloomFilter(s.capacity, s.falsePositiveRate))
+
+	return s
+}
+
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	numOfBits := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	numOfHash := int(math.Ceil(math.Log2(1 / falsePositiveRate)))
+
+	return &bloomFilter{
+		bits:      make([]uint64, (numOfBits+63)/64),
+		numOfBits: numOfBits,
+		numOfHash: numOfHash,
+		capacity:  capacity,
+	}
+}
+
+// Add reports whether key was new, which is wrong for a small share of new
+// keys.
+func (s *BloomVisitedSet) Add(key string) bool {
+	// Double hashing: the i-th position of a key is h1 + i*h2.
+	h1 := maphash.String(s.seeds[0], key)
+	h2 := maphash.String(s.seeds[1], key) | 1
+
+	for _, filter := range s.filters {
+		if filter.contains(h1, h2) {
+			return false
+		}
+	}
+
+	last := s.filters[len(s.filters)-1]
+	if last.count >= last.capacity {
+		s.capacity *= bloomGrowthFactor
+		s.falsePositiveRate *= bloomTighteningRatio
+		last = newBloomFilter(s.capacity, s.falsePositiveRate)
+		s.filters = append(s.filters, last)
+	}
+	last.add(h1, h2)
+
+	return true
+}
+
+// Close does nothing.
+func (s *BloomVisitedSet) Close() error {
+	return nil
+}
+
+func (f *bloomFilter) contains(h1 uint64, h2 uint64) bool {
+	for i := 0; i < f.numOfHash; i++ {
+		bit := (h1 + uint64(i)*h2) % f.numOfBits
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (f *bloomFilter) add(h1 uint64, h2 uint64) {
+	for i := 0; i < f.numOfHash; i++ {
+		bit := (h1 + uint64(i)*h2) % f.numOfBits
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+// DiskVisitedSet keeps a 128-bit hash of every key in an open addressing hash
+// table stored in a temporary file, so its memory usage doesn't grow with the
+// crawl. The table doubles in size when it is half full. I/O errors make Add
+// report keys as already seen, to never crawl a page twice, and are returned
+// by Close, which also removes the file.
+type DiskVisitedSet struct {
+	dir      string
+	seeds    [2]maphash.Seed
+	file     *os.File
+	numSlots uint64
+	count    uint64
+	err      error
+}
+
+// NewDiskVisitedSet creates its file in dir, the default directory for
+// temporary files if empty.
+func NewDiskVisitedSet(dir string) (*DiskVisitedSet, error) {
+	s := &DiskVisitedSet{dir: dir, seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}}
+
+	file, err := s.createTable(diskInitialSlots)
+	if err != nil {
+		return nil, err
+	}
+	s.file = file
+	s.numSlots = diskInitialSlots
+
+	return s, nil
+}
+
+func (s *DiskVisitedSet) createTable(numSlots uint64) (*os.File, error) {
+	file, err := os.CreateTemp(s.dir, "visited-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the visited set file: %w", err)
+	}
+
+	// The file is sparse, unused slots are zeroes.
+	if err = file.Truncate(int64(numSlots * diskSlotSize)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create the visited set file: %w", err)
+	}
+
+	return file, nil
+}
+
+// Add reports whether key was new. Two keys of the same 128-bit hash are the
+// same key.
+func (s *DiskVisitedSet) Add(key string) bool {
+	if s.err != nil {
+		return false
+	}
+
+	var slot [diskSlotSize]byte
+	binary.BigEndian.PutUint64(slot[:8], maphash.String(s.seeds[0], key))
+	binary.BigEndian.PutUint64(slot[8:], maphash.String(s.seeds[1], key))
+	// An all-zero slot is an empty one.
+	slot[0] |= 0x80
+
+	added, err := insertSlot(s.file, s.numSlots, slot)
+	if err != nil {
+		s.err = err
+		return false
+	}
+	if !added {
+		return false
+	}
+
+	s.count++
+	if s.count*2 >= s.numSlots {
+		if err = s.grow(); err != nil {
+			s.err = err
+		}
+	}
+
+	return true
+}
+
+// insertSlot adds a hash to the table using linear probing and reports whether
+// it wasn't there already.
+func insertSlot(file *os.File, numSlots uint64, slot [diskSlotSize]byte) (bool, error) {
+	var current [diskSlotSize]byte
+	for i := binary.BigEndian.Uint64(slot[8:]) % numSlots; ; i = (i + 1) % numSlots {
+		if _, err := file.ReadAt(current[:], int64(i*diskSlotSize)); err != nil {
+			return false, fmt.Errorf("failed to read the visited set file: %w", err)
+		}
+		if current == slot {
+			return false, nil
+		}
+		if current == [diskSlotSize]byte{} {
+			if _, err := file.WriteAt(slot[:], int64(i*diskSlotSize)); err != nil {
+				return false, fmt.Errorf("failed to write the visited set file: %w", err)
+			}
+			return true, nil
+		}
+	}
+}
+
+func (s *DiskVisitedSet) grow() error {
+	numSlots := s.numSlots * 2
+	file, err := s.createTable(numSlots)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, diskReadBufferSize)
+	for offset := int64(0); offset < int64(s.numSlots*diskSlotSize); offset += diskReadBufferSize {
+		n, err := s.file.ReadAt(buffer, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			file.Close()
+			os.Remove(file.Name())
+			return fmt.Errorf("failed to read the visited set file: %w", err)
+		}
+
+		for i := 0; i+diskSlotSize <= n; i += diskSlotSize {
+			slot := [diskSlotSize]byte(buffer[i : i+diskSlotSize])
+			if slot == [diskSlotSize]byte{} {
+				continue
+			}
+			if _, err = insertSlot(file, numSlots, slot); err != nil {
+				file.Close()
+				os.Remove(file.Name())
+				return err
+			}
+		}
+	}
+
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = file
+	s.numSlots = numSlots
+
+	return nil
+}
+
+// Close removes the file, returning the first I/O error of Add if any.
+func (s *DiskVisitedSet) Close() error {
+	closeErr := s.file.Close()
+	removeErr := os.Remove(s.file.Name())
+
+	return errors.Join(s.err, closeErr, removeErr)
+}
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNewVisitedSet_Success(t *testing.T) {
+	for _, setType := range VisitedSetTypes {
+		visitedSet, err := NewVisitedSet(setType, 0.01, t.TempDir())
+		assert.NoError(t, err)
+		assert.True(t, visitedSet.Add("abc.com/"))
+		assert.False(t, visitedSet.Add("abc.com/"))
+		assert.NoError(t, visitedSet.Close())
+	}
+}
+
+func TestNewVisitedSet_Error(t *testing.T) {
+	_, err := NewVisitedSet("tree", 0.01, "")
+	assert.EqualError(t, err, `unknown visited set "tree", expected one of [map bloom disk]`)
+}
+
+func TestMapVisitedSet_Add(t *testing.T) {
+	visitedSet := NewMapVisitedSet()
+
+	assert.True(t, visitedSet.Add("abc.com/a"))
+	assert.True(t, visitedSet.Add("abc.com/b"))
+	assert.False(t, visitedSet.Add("abc.com/a"))
+}
+
+func TestBloomVisitedSet_Add(t *testing.T) {
+	visitedSet := NewBloomVisitedSet(1000, 0.01)
+
+	var falsePositives int
+	for i := 0; i < 50_000; i++ {
+		if !visitedSet.Add(fmt.Sprintf("abc.com/page/%d", i)) {
+			falsePositives++
+		}
+	}
+	for i := 0; i < 50_000; i++ {
+		assert.False(t, visitedSet.Add(fmt.Sprintf("abc.com/page/%d", i)))
+	}
+
+	assert.Greater(t, len(visitedSet.filters), 1)
+	assert.Less(t, float64(falsePositives)/50_000, 0.01)
+}
+
+func TestDiskVisitedSet_Add(t *testing.T) {
+	dir := t.TempDir()
+	visitedSet, err := NewDiskVisitedSet(dir)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3*diskInitialSlots; i++ {
+		assert.True(t, visitedSet.Add(fmt.Sprintf("abc.com/page/%d", i)))
+	}
+	for i := 0; i < 3*diskInitialSlots; i++ {
+		assert.False(t, visitedSet.Add(fmt.Sprintf("abc.com/page/%d", i)))
+	}
+	assert.Equal(t, uint64(8*diskInitialSlots), visitedSet.numSlots)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Len(t, files, 1)
+
+	assert.NoError(t, visitedSet.Close())
+	files, _ = filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files)
+}
+
+func TestDiskVisitedSet_Error(t *testing.T) {
+	_, err := NewDiskVisitedSet(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// BenchmarkVisitedSet adds b.N URLs to every VisitedSet, reporting the heap
+// used per URL along with the usual time per operation.
+func BenchmarkVisitedSet(b *testing.B) {
+	sets := map[string]func(b *testing.B) VisitedSet{
+		"map":   func(*testing.B) VisitedSet { return NewMapVisitedSet() },
+		"bloom": func(*testing.B) VisitedSet { return NewBloomVisitedSet(0, DefaultBloomFalsePositiveRate) },
+		"disk": func(b *testing.B) VisitedSet {
+			visitedSet, err := NewDiskVisitedSet(b.TempDir())
+			if err != nil {
+				b.Fatal(err)
+			}
+			return visitedSet
+		},
+	}
+
+	for _, setType := range VisitedSetTypes {
+		b.Run(setType, func(b *testing.B) {
+			keys := make([]string, b.N)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("www.example.com/articles/%d/some-article-title", i)
+			}
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			visitedSet := sets[setType](b)
+			b.ResetTimer()
+			for _, key := range keys {
+				visitedSet.Add(key)
+			}
+			b.StopTimer()
+
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.HeapAlloc-min(after.HeapAlloc, before.HeapAlloc))/float64(b.N), "heap-B/key")
+
+			runtime.KeepAlive(keys)
+			runtime.KeepAlive(visitedSet)
+			if err := visitedSet.Close(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
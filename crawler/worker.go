@@ -0,0 +1,221 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// WorkerPool runs tasks of type T on a number of workers that can be changed
+// with Resize at any time. Tasks can be added while others are being
+// processed, ProcessTasks returns once there are none left.
+type WorkerPool[T any] struct {
+	numOfWorkers   int
+	runningWorkers int
+	activeWorkers  int
+	tasks          []T
+	pending        int
+	errs           []error
+	lastWorkerID   int
+	logger         *slog.Logger
+	m              sync.Mutex
+	cond           *sync.Cond
+	// Set while ProcessTasks runs, so that Resize can start new workers.
+	wg          *sync.WaitGroup
+	startWorker func(workerID int)
+}
+
+// PanicError is the error of a task that panicked, along with the stack of the
+// worker at that moment.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error returns the value the task panicked with, without the stack.
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", p.Value)
+}
+
+// NewWorkerPool returns a pool of numOfWorkers workers, at least one, that
+// start with ProcessTasks.
+func NewWorkerPool[T any](numOfWorkers int) *WorkerPool[T] {
+	p := &WorkerPool[T]{numOfWorkers: max(numOfWorkers, 1), logger: discardLogger()}
+	p.cond = sync.NewCond(&p.m)
+
+	return p
+}
+
+// SetLogger sets the logger of the pool. Workers started before keep the
+// logger they started with.
+func (p *WorkerPool[T]) SetLogger(logger *slog.Logger) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.logger = logger
+}
+
+// Resize changes the number of workers, which is never less than one. New
+// workers start right away, extra ones stop once they are done with their
+// current task.
+func (p *WorkerPool[T]) Resize(numOfWorkers int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if numOfWorkers = max(numOfWorkers, 1); numOfWorkers != p.numOfWorkers {
+		p.logger.Info("worker pool resized", "from", p.numOfWorkers, "to", numOfWorkers)
+		p.numOfWorkers = numOfWorkers
+	}
+	// Without pending tasks, ProcessTasks is over or about to be.
+	if p.startWorker == nil || p.pending == 0 {
+		return
+	}
+
+	for p.runningWorkers < p.numOfWorkers {
+		p.runningWorkers++
+		p.lastWorkerID++
+		p.wg.Add(1)
+		go p.startWorker(p.lastWorkerID)
+	}
+	p.cond.Broadcast()
+}
+
+// Size returns the number of workers.
+func (p *WorkerPool[T]) Size() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.numOfWorkers
+}
+
+// Active returns the number of workers processing a task.
+func (p *WorkerPool[T]) Active() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.activeWorkers
+}
+
+// AddTask queues a task. Tasks can be added before ProcessTasks or by the
+// tasks themselves.
+func (p *WorkerPool[T]) AddTask(task T) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.tasks = append(p.tasks, task)
+	p.pending++
+	p.cond.Signal()
+}
+
+// ProcessTasks calls processTask for every task until there are none left or
+// ctx is done, in which case the tasks still waiting are dropped. Each task gets
+// its own context, canceled when it returns, holding the ID of its worker (see
+// WorkerIDFrom). The errors of the tasks, including the ones that panicked, are
+// returned joined together.
+func (p *WorkerPool[T]) ProcessTasks(ctx context.Context, processTask func(context.Context, T) error) error {
+	stop := context.AfterFunc(ctx, func() {
+		p.m.Lock()
+		defer p.m.Unlock()
+		p.cond.Broadcast()
+	})
+	defer stop()
+
+	wg := &sync.WaitGroup{}
+	p.m.Lock()
+	p.wg = wg
+	p.startWorker = func(workerID int) {
+		defer wg.Done()
+		p.work(ContextWithWorkerID(ctx, workerID), processTask)
+	}
+	p.m.Unlock()
+	p.Resize(p.Size())
+
+	wg.Wait()
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.wg, p.startWorker, p.runningWorkers, p.lastWorkerID = nil, nil, 0, 0
+	errs := p.errs
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+	p.tasks, p.pending, p.errs = nil, 0, nil
+
+	return errors.Join(errs...)
+}
+
+func (p *WorkerPool[T]) work(ctx context.Context, processTask func(context.Context, T) error) {
+	workerID, _ := WorkerIDFrom(ctx)
+	p.m.Lock()
+	logger := p.logger
+	p.m.Unlock()
+	logger.Debug("worker started", "worker_id", workerID)
+	defer logger.Debug("worker stopped", "worker_id", workerID)
+
+	for {
+		task, ok := p.nextTask(ctx)
+		if !ok {
+			return
+		}
+
+		err := runTask(ctx, task, processTask)
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			logger.Error("task panicked", "worker_id", workerID, "error", panicErr, "stack", string(panicErr.Stack))
+		}
+
+		p.m.Lock()
+		if err != nil {
+			p.errs = append(p.errs, err)
+		}
+		p.pending--
+		p.activeWorkers--
+		if p.pending == 0 {
+			p.cond.Broadcast()
+		}
+		p.m.Unlock()
+	}
+}
+
+// nextTask waits for a task. There are no more tasks once the queue is empty
+// and no task is being processed, as only those could add new ones. Workers
+// beyond the size of the pool get no task, so they stop.
+func (p *WorkerPool[T]) nextTask(ctx context.Context) (T, bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for len(p.tasks) == 0 && p.pending > 0 && ctx.Err() == nil && p.runningWorkers <= p.numOfWorkers {
+		p.cond.Wait()
+	}
+
+	var task T
+	if len(p.tasks) == 0 || ctx.Err() != nil || p.runningWorkers > p.numOfWorkers {
+		p.runningWorkers--
+		return task, false
+	}
+
+	task = p.tasks[0]
+	var zero T
+	p.tasks[0] = zero
+	p.tasks = p.tasks[1:]
+	p.activeWorkers++
+
+	return task, true
+}
+
+func runTask[T any](ctx context.Context, task T, processTask func(context.Context, T) error) (err error) {
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+
+	return processTask(taskCtx, task)
+}
@@ -0,0 +1,182 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_Success(t *testing.T) {
+	workerPool := NewWorkerPool[string](10)
+
+	tasks := []string{"a", "b", "c", "d", "e", "f"}
+	for _, task := range tasks {
+		workerPool.AddTask(task)
+	}
+
+	var results []string
+	var m sync.Mutex
+	processTaskFunc := func(_ context.Context, letter string) error {
+		result := letter + letter
+		m.Lock()
+		results = append(results, result)
+		m.Unlock()
+		if result == "ee" || result == "cc" {
+			workerPool.AddTask(result)
+		}
+		return nil
+	}
+
+	err := workerPool.ProcessTasks(context.Background(), processTaskFunc)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"aa", "bb", "cc", "cccc", "dd", "ee", "eeee", "ff"}, results)
+}
+
+func TestWorkerPool_SetLogger_WhileProcessing(t *testing.T) {
+	workerPool := NewWorkerPool[int](4)
+	for i := 0; i < 100; i++ {
+		workerPool.AddTask(i)
+	}
+
+	err := workerPool.ProcessTasks(context.Background(), func(_ context.Context, task int) error {
+		if task%10 == 0 {
+			workerPool.SetLogger(discardLogger())
+			workerPool.Resize(task%20/10 + 3)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestWorkerPool_NoTasks(t *testing.T) {
+	workerPool := NewWorkerPool[int](10)
+
+	err := workerPool.ProcessTasks(context.Background(), func(context.Context, int) error {
+		t.Fail()
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestWorkerPool_Errors(t *testing.T) {
+	workerPool := NewWorkerPool[int](3)
+	for i := 0; i < 5; i++ {
+		workerPool.AddTask(i)
+	}
+
+	errOdd := errors.New("odd number")
+	err := workerPool.ProcessTasks(context.Background(), func(_ context.Context, n int) error {
+		if n == 4 {
+			panic("four")
+		}
+		if n%2 == 1 {
+			return errOdd
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, errOdd)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "four", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "worker_test.go")
+	assert.EqualError(t, panicErr, "task panicked: four")
+}
+
+func TestWorkerPool_WorkerIDs(t *testing.T) {
+	workerPool := NewWorkerPool[int](2)
+	var logs bytes.Buffer
+	workerPool.SetLogger(newTestLogger(&logs))
+	for i := 0; i < 4; i++ {
+		workerPool.AddTask(i)
+	}
+
+	var m sync.Mutex
+	workerIDs := make(map[int]bool)
+	err := workerPool.ProcessTasks(context.Background(), func(ctx context.Context, n int) error {
+		workerID, ok := WorkerIDFrom(ctx)
+		assert.True(t, ok)
+		m.Lock()
+		workerIDs[workerID] = true
+		m.Unlock()
+		if n == 3 {
+			panic("three")
+		}
+		return nil
+	})
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	for workerID := range workerIDs {
+		assert.Contains(t, []int{1, 2}, workerID)
+	}
+	assert.Contains(t, logs.String(), "level=DEBUG msg=\"worker started\" worker_id=1\n")
+	assert.Contains(t, logs.String(), "level=DEBUG msg=\"worker stopped\" worker_id=2\n")
+	assert.Contains(t, logs.String(), "level=ERROR msg=\"task panicked\" worker_id=")
+	assert.Contains(t, logs.String(), "error=\"task panicked: three\" stack=")
+}
+
+func TestWorkerPool_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	workerPool := NewWorkerPool[int](1)
+	for i := 0; i < 5; i++ {
+		workerPool.AddTask(i)
+	}
+
+	var processed []int
+	var taskContexts []context.Context
+	err := workerPool.ProcessTasks(ctx, func(taskCtx context.Context, n int) error {
+		processed = append(processed, n)
+		taskContexts = append(taskContexts, taskCtx)
+		if n == 1 {
+			cancel()
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []int{0, 1}, processed)
+	for _, taskCtx := range taskContexts {
+		assert.Error(t, taskCtx.Err())
+	}
+}
+
+func TestWorkerPool_Resize(t *testing.T) {
+	workerPool := NewWorkerPool[int](1)
+	for i := 0; i < 20; i++ {
+		workerPool.AddTask(i)
+	}
+
+	var m sync.Mutex
+	var running, maxRunning int
+	err := workerPool.ProcessTasks(context.Background(), func(_ context.Context, n int) error {
+		m.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		m.Unlock()
+
+		switch n {
+		case 0:
+			workerPool.Resize(4)
+		case 10:
+			workerPool.Resize(0)
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		m.Lock()
+		running--
+		m.Unlock()
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, maxRunning)
+	assert.Equal(t, 1, workerPool.Size())
+	assert.Equal(t, 0, workerPool.runningWorkers)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crawler/crawler"
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"os"
+	"slices"
+)
+
+// runDiff is the diff subcommand, which compares the manifests written by
+// --output on two crawls of a site.
+func runDiff(args []string) error {
+	flags := pflag.NewFlagSet("diff", pflag.ContinueOnError)
+	format := flags.String("format", "text", fmt.Sprintf("Format of the differences %v", crawler.DiffFormats))
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s diff <old manifest> <new manifest>:\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("diff takes the manifests of two crawls, written by --output")
+	}
+	if !slices.Contains(crawler.DiffFormats, *format) {
+		return fmt.Errorf("unknown format %q, expected one of %v", *format, crawler.DiffFormats)
+	}
+
+	oldManifest, err := readManifest(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newManifest, err := readManifest(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diff := crawler.DiffManifests(oldManifest, newManifest)
+	if *format == "json" {
+		return diff.WriteJSON(os.Stdout)
+	}
+
+	return diff.WriteText(os.Stdout)
+}
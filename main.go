@@ -2,55 +2,528 @@ package main
 
 import (
 	"context"
+	"crawler/analysis"
+	"crawler/crawler"
 	"errors"
+	"fmt"
 	"github.com/spf13/pflag"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		subcommands := map[string]func([]string) error{"diff": runDiff, "monitor": runMonitor}
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fatal(err)
+			}
+			return
+		}
+	}
+
 	params, err := parseCommandLineFlags()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	var metrics *crawler.Metrics
+	if params.metricsAddr != "" || params.showProgress {
+		metrics = crawler.NewMetrics()
+	}
+
+	var progress *crawler.ProgressDisplay
+	var logOutput io.Writer = os.Stderr
+	if params.showProgress {
+		progress = crawler.NewProgressDisplay(os.Stderr, metrics, params.progressInterval)
+		logOutput = progress.LogWriter(os.Stderr)
+	}
+
+	logger, err := crawler.NewLogger(logOutput, params.logLevel, params.logFormat)
+	if err != nil {
+		fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	var feedMonitor *crawler.FeedMonitor
+	if params.followFeeds {
+		feedMonitor = crawler.NewFeedMonitor(params.staleFeedAge)
+	}
+
+	var trapDetector *crawler.TrapDetector
+	if params.detectTraps {
+		trapDetector = crawler.NewTrapDetector(params.trapDetectorParams)
+	}
+
+	visitedSet, err := crawler.NewVisitedSet(params.visitedSetType, params.bloomFalsePositiveRate, params.visitedSetDir)
+	if err != nil {
+		fatal(err)
 	}
 
-	crawlerParams := &CrawlerParams{
-		httpClient:      &http.Client{Timeout: params.timeout},
-		numberOfWorkers: params.numberOfWorkers,
-		retryAttempts:   params.numberOfRetries,
+	scorer, err := crawler.NewStrategyScorer(params.strategy, params.patternWeights)
+	if err != nil {
+		fatal(err)
+	}
+
+	var adaptiveConcurrency *crawler.AdaptiveConcurrency
+	if params.adaptiveConcurrency {
+		adaptiveConcurrency = crawler.NewAdaptiveConcurrency(crawler.AdaptiveConcurrencyParams{
+			InitialConcurrency: params.initialConcurrency,
+			MaxConcurrency:     params.numberOfWorkers,
+			TargetLatency:      params.targetLatency,
+			MaxErrorRate:       params.maxErrorRate,
+			Logger:             logger,
+		})
+	}
+
+	var cache *crawler.HTTPCache
+	if params.cacheDir != "" {
+		cache, err = crawler.NewHTTPCache(params.cacheDir)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	var previous *crawler.Manifest
+	if params.previousPath != "" {
+		previous, err = readManifest(params.previousPath)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	var manifest *crawler.Manifest
+	if params.outputPath != "" || previous != nil {
+		manifest = crawler.NewManifest()
+	}
+	recordResult := func(result crawler.Result) {
+		if manifest == nil {
+			return
+		}
+		previousEntry, _ := previous.Lookup(result.TargetURL)
+		if err := manifest.Add(crawler.NewManifestEntry(result, previousEntry, time.Now())); err != nil {
+			logger.Error("failed to record the page", "url", result.TargetURL.String(), "error", err)
+		}
+	}
+
+	if params.metricsAddr != "" {
+		serveMetrics(params.metricsAddr, metrics)
 	}
-	crawler := NewCrawler(crawlerParams)
 
-	onTargetURLProcessed := func(linksForTargetURL *LinksByTargetURL) {
-		log.Printf("URL -> %s: LINKS -> %s\n", linksForTargetURL.targetURL, linksForTargetURL.links)
+	c := crawler.New(
+		crawler.WithHTTPClient(&http.Client{Timeout: params.timeout}),
+		crawler.WithWorkers(params.numberOfWorkers),
+		crawler.WithRetryAttempts(params.numberOfRetries),
+		crawler.WithMaxBodySize(params.maxBodySize),
+		crawler.WithAllowedContentTypes(params.allowedContentTypes...),
+		crawler.WithDeniedExtensions(params.deniedExtensions...),
+		crawler.WithHeadBeforeGet(params.headBeforeGet),
+		crawler.WithFeedMonitor(feedMonitor),
+		crawler.WithTrapDetector(trapDetector),
+		crawler.WithVisitedSet(visitedSet),
+		crawler.WithScorer(scorer),
+		crawler.WithMaxPages(params.maxPages),
+		crawler.WithAdaptiveConcurrency(adaptiveConcurrency),
+		crawler.WithMetrics(metrics),
+		crawler.WithCache(cache),
+		crawler.WithPreviousManifest(previous),
+		crawler.WithLogger(logger),
+	)
+
+	var timingReport *crawler.TimingReport
+	if params.timingReportPath != "" {
+		timingReport = crawler.NewTimingReport()
+	}
+
+	errorSummary := crawler.NewErrorSummary()
+	// The graphs hold every link of the crawl, so they are only built when written.
+	var graph *crawler.Graph
+	if params.graphDOTPath != "" || params.graphGraphMLPath != "" || params.graphCSVPrefix != "" {
+		graph = crawler.NewGraph()
+	}
+	var linkGraph *analysis.Graph
+	if params.analysisJSONPath != "" || params.analysisTablePath != "" {
+		linkGraph = analysis.NewGraph(crawler.PageURL(params.targetURL))
+	}
+	onTargetURLProcessed := func(linksForTargetURL *crawler.LinksByTargetURL) {
+		recordResult(crawler.Result{TargetURL: linksForTargetURL.TargetURL, Page: linksForTargetURL})
+		if linksForTargetURL.Err != nil {
+			errorSummary.Observe(linksForTargetURL.Err)
+		}
+		if graph != nil {
+			graph.AddPage(linksForTargetURL)
+		}
+		if linkGraph != nil {
+			linkGraph.AddCrawledPage(linksForTargetURL)
+		}
+		if timingReport != nil {
+			timingReport.ObservePage(linksForTargetURL)
+		}
+		if linksForTargetURL.Skipped() {
+			logger.Info("page skipped", "url", linksForTargetURL.TargetURL.String(), "depth", linksForTargetURL.Depth,
+				"reason", linksForTargetURL.SkipReason, "type", linksForTargetURL.ContentType, "size", linksForTargetURL.ContentLength)
+			return
+		}
+		logger.Info("page crawled", "url", linksForTargetURL.TargetURL.String(), "depth", linksForTargetURL.Depth,
+			"status", linksForTargetURL.StatusCode, "charset", linksForTargetURL.Charset, "links", len(linksForTargetURL.Links))
 	}
 
-	var errs []error
 	onError := func(err error) {
-		errs = append(errs, err)
+		errorSummary.Observe(err)
+		var crawlerErr *crawler.Error
+		if errors.As(err, &crawlerErr) {
+			recordResult(crawler.Result{TargetURL: crawlerErr.TargetURL, Err: err})
+			logger.Debug("page failed", "url", crawlerErr.TargetURL.String(), "kind", crawlerErr.Kind.String(),
+				"attempts", crawlerErr.Attempts, "retryable", crawlerErr.Retryable, "error", crawlerErr.Err)
+			return
+		}
+		logger.Error("crawl failed", "error", err)
+	}
+
+	if progress != nil {
+		progress.Start()
+	}
+
+	c.GetAllLinksFor(context.Background(), params.targetURL, onTargetURLProcessed, onError)
+
+	if progress != nil {
+		progress.Stop()
+	}
+
+	if err = visitedSet.Close(); err != nil {
+		logger.Error("failed to close the visited set", "error", err)
+	}
+
+	logErrorSummary(logger, errorSummary)
+
+	if cache != nil {
+		stats := cache.Stats()
+		logger.Info("cache", "hits", stats.Hits, "revalidations", stats.Revalidations, "misses", stats.Misses,
+			"hit_rate", fmt.Sprintf("%.1f%%", 100*stats.HitRate()), "saved_bytes", stats.BytesSaved, "errors", stats.Errors)
+	}
+
+	if feedMonitor != nil {
+		logFeedReport(logger, feedMonitor.Report())
+	}
+
+	if adaptiveConcurrency != nil {
+		for _, hostConcurrency := range adaptiveConcurrency.Stats() {
+			logger.Info("host concurrency", "host", hostConcurrency.Host, "limit", hostConcurrency.Limit,
+				"increases", hostConcurrency.Increases, "decreases", hostConcurrency.Decreases)
+		}
+	}
+
+	if trapDetector != nil {
+		for _, trap := range trapDetector.Report() {
+			logger.Warn("spider trap", "pattern", trap.Pattern, "reason", trap.Reason, "suppressed", trap.Suppressed, "example", trap.Example)
+		}
+	}
+
+	if err = writeGraph(graph, params); err != nil {
+		fatal(err)
+	}
+
+	if err = writeAnalysis(linkGraph, params); err != nil {
+		fatal(err)
+	}
+
+	if err = writeTimingReport(timingReport, params); err != nil {
+		fatal(err)
 	}
 
-	crawler.GetAllLinksFor(context.Background(), params.targetURL, onTargetURLProcessed, onError)
+	if err = writeErrorReport(errorSummary, params); err != nil {
+		fatal(err)
+	}
 
-	for _, err = range errs {
-		log.Println(err)
+	if err = writeManifest(logger, manifest, previous, params); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// serveMetrics serves /metrics in the background for as long as the crawler runs.
+func serveMetrics(addr string, metrics *crawler.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("failed to serve metrics", "addr", addr, "error", err)
+		}
+	}()
+}
+
+func writeAnalysis(linkGraph *analysis.Graph, params *parameters) error {
+	if params.analysisJSONPath == "" && params.analysisTablePath == "" {
+		return nil
+	}
+
+	report := analysis.Analyze(linkGraph, analysis.Options{
+		Damping:    params.pageRankDamping,
+		Iterations: params.pageRankIterations,
+	})
+
+	if params.analysisJSONPath != "" {
+		if err := writeFile(params.analysisJSONPath, report.WriteJSON); err != nil {
+			return err
+		}
+	}
+
+	if params.analysisTablePath == "-" {
+		return report.WriteTable(os.Stdout, params.analysisSortBy)
+	}
+	if params.analysisTablePath != "" {
+		return writeFile(params.analysisTablePath, func(w io.Writer) error {
+			return report.WriteTable(w, params.analysisSortBy)
+		})
+	}
+
+	return nil
+}
+
+func writeTimingReport(timingReport *crawler.TimingReport, params *parameters) error {
+	if timingReport == nil {
+		return nil
+	}
+
+	if params.timingReportPath == "-" {
+		return timingReport.WriteTable(os.Stdout, params.slowestPages)
+	}
+
+	return writeFile(params.timingReportPath, func(w io.Writer) error {
+		return timingReport.WriteTable(w, params.slowestPages)
+	})
+}
+
+func writeErrorReport(errorSummary *crawler.ErrorSummary, params *parameters) error {
+	if params.errorReportPath == "" {
+		return nil
+	}
+
+	if params.errorReportPath == "-" {
+		return errorSummary.WriteTable(os.Stdout)
+	}
+
+	return writeFile(params.errorReportPath, errorSummary.WriteTable)
+}
+
+func readManifest(path string) (*crawler.Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	manifest, err := crawler.ReadManifest(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return manifest, nil
+}
+
+// writeManifest writes the manifest of the crawl and, when recrawling, what
+// changed since the previous one.
+func writeManifest(logger *slog.Logger, manifest *crawler.Manifest, previous *crawler.Manifest, params *parameters) error {
+	if params.outputPath != "" {
+		if err := writeFile(params.outputPath, manifest.Write); err != nil {
+			return err
+		}
+	}
+
+	if previous == nil {
+		return nil
+	}
+
+	changeSet := crawler.NewChangeSet(previous, manifest)
+	logger.Info("changes", "new", len(changeSet.New), "removed", len(changeSet.Removed),
+		"modified", len(changeSet.Modified), "unchanged", len(changeSet.Unchanged))
+
+	if params.changesPath == "-" {
+		return changeSet.WriteJSON(os.Stdout)
+	}
+	if params.changesPath != "" {
+		return writeFile(params.changesPath, changeSet.WriteJSON)
+	}
+
+	return nil
+}
+
+func writeGraph(graph *crawler.Graph, params *parameters) error {
+	if params.graphDOTPath != "" {
+		if err := writeFile(params.graphDOTPath, graph.WriteDOT); err != nil {
+			return err
+		}
+	}
+
+	if params.graphGraphMLPath != "" {
+		if err := writeFile(params.graphGraphMLPath, graph.WriteGraphML); err != nil {
+			return err
+		}
+	}
+
+	if params.graphCSVPrefix != "" {
+		return writeFile(params.graphCSVPrefix+"-nodes.csv", func(nodes io.Writer) error {
+			return writeFile(params.graphCSVPrefix+"-edges.csv", func(edges io.Writer) error {
+				return graph.WriteCSV(nodes, edges)
+			})
+		})
+	}
+
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return file.Close()
+}
+
+// logErrorSummary logs the pages that failed grouped by kind and host, the
+// errors of the crawl itself having been logged as they happened.
+func logErrorSummary(logger *slog.Logger, errorSummary *crawler.ErrorSummary) {
+	if total := errorSummary.Total(); total > 0 {
+		logger.Warn("crawl errors", "total", total)
+	}
+	for _, group := range errorSummary.Groups() {
+		logger.Warn("pages failed", "kind", group.Kind.String(), "host", group.Host, "pages", group.Count,
+			"retryable", group.Example.Retryable, "example", group.Example.TargetURL.String(), "error", group.Example.Err)
+	}
+}
+
+func logFeedReport(logger *slog.Logger, report []*crawler.FeedHealth) {
+	for _, health := range report {
+		feedLogger := logger.With("feed", health.FeedURL.String())
+		if health.ParseError != nil {
+			feedLogger.Error("feed parse error", "error", health.ParseError)
+			continue
+		}
+
+		level := slog.LevelInfo
+		if health.Stale {
+			level = slog.LevelWarn
+		}
+		lastPublished := "unknown"
+		if !health.LastPublished.IsZero() {
+			lastPublished = health.LastPublished.Format(time.RFC3339)
+		}
+		feedLogger.Log(context.Background(), level, "feed", "stale", health.Stale, "title", health.Title,
+			"entries", health.Entries, "last_published", lastPublished)
+		for _, entryURL := range health.BrokenEntries {
+			feedLogger.Warn("broken feed entry", "entry", entryURL.String())
+		}
 	}
 }
 
 type parameters struct {
-	numberOfWorkers int
-	timeout         time.Duration
-	targetURL       *url.URL
-	numberOfRetries uint
+	numberOfWorkers        int
+	timeout                time.Duration
+	targetURL              *url.URL
+	numberOfRetries        uint
+	maxBodySize            int64
+	allowedContentTypes    []string
+	deniedExtensions       []string
+	headBeforeGet          bool
+	followFeeds            bool
+	staleFeedAge           time.Duration
+	graphDOTPath           string
+	graphGraphMLPath       string
+	graphCSVPrefix         string
+	analysisJSONPath       string
+	analysisTablePath      string
+	analysisSortBy         string
+	pageRankDamping        float64
+	pageRankIterations     int
+	detectTraps            bool
+	trapDetectorParams     crawler.TrapDetectorParams
+	visitedSetType         string
+	bloomFalsePositiveRate float64
+	visitedSetDir          string
+	strategy               string
+	patternWeights         map[string]float64
+	maxPages               int
+	adaptiveConcurrency    bool
+	initialConcurrency     int
+	targetLatency          time.Duration
+	maxErrorRate           float64
+	metricsAddr            string
+	showProgress           bool
+	progressInterval       time.Duration
+	logLevel               string
+	logFormat              string
+	timingReportPath       string
+	slowestPages           int
+	errorReportPath        string
+	cacheDir               string
+	outputPath             string
+	previousPath           string
+	changesPath            string
 }
 
 func parseCommandLineFlags() (*parameters, error) {
-	workers := pflag.IntP("workers", "w", 100, "Number of workers")
+	workers := pflag.IntP("workers", "w", crawler.DefaultWorkers, "Number of workers")
 	timeout := pflag.IntP("timeout", "t", 30, "HTTP timeout (seconds)")
 	targetURL := pflag.StringP("url", "u", "", "Target URL")
-	retries := pflag.UintP("retries", "r", 3, "Number of task retries")
+	retries := pflag.UintP("retries", "r", crawler.DefaultRetryAttempts, "Number of task retries")
+	maxBodySize := pflag.Int64("max-body-size", crawler.DefaultMaxBodySize, "Maximum response body size (bytes), 0 for the default, negative for no limit")
+	allowedContentTypes := pflag.StringSlice("content-types", crawler.DefaultAllowedContentTypes, "Content types to extract links from")
+	deniedExtensions := pflag.StringSlice("deny-extensions", crawler.DefaultDeniedExtensions, "File extensions that are never fetched")
+	headBeforeGet := pflag.Bool("head-before-get", false, "Send a HEAD request before fetching links with non-HTML extensions")
+	followFeeds := pflag.Bool("feeds", false, "Follow RSS/Atom feeds advertised by pages and report their health")
+	staleFeedAge := pflag.Duration("stale-feed-age", crawler.DefaultStaleFeedAge, "Age of the latest entry after which a feed is reported as stale")
+	graphDOTPath := pflag.String("graph-dot", "", "Write the link graph to this file in the Graphviz DOT format")
+	graphGraphMLPath := pflag.String("graph-graphml", "", "Write the link graph to this file in the GraphML format")
+	graphCSVPrefix := pflag.String("graph-csv", "", "Write the link graph to <prefix>-nodes.csv and <prefix>-edges.csv")
+	analysisJSONPath := pflag.String("analysis-json", "", "Write link metrics (PageRank, degrees, click depth, components) to this file as JSON")
+	analysisTablePath := pflag.String("analysis-table", "", "Write link metrics to this file as a text table, - for stdout")
+	analysisSortBy := pflag.String("analysis-sort", "pagerank", fmt.Sprintf("Column to sort the link metrics table by %v", analysis.SortColumns))
+	pageRankDamping := pflag.Float64("pagerank-damping", analysis.DefaultDamping, "PageRank damping factor")
+	pageRankIterations := pflag.Int("pagerank-iterations", analysis.DefaultIterations, "Maximum number of PageRank iterations")
+	detectTraps := pflag.Bool("trap-detection", false, "Detect spider traps (calendars, faceted navigation, session IDs) and stop crawling them")
+	trapMaxRepeats := pflag.Int("trap-max-repeats", crawler.DefaultMaxRepeatedSegments, "Maximum number of times a segment can appear in a path")
+	trapMaxDepth := pflag.Int("trap-max-depth", crawler.DefaultMaxPathDepth, "Maximum number of segments in a path")
+	trapMaxPathLength := pflag.Int("trap-max-path-length", crawler.DefaultMaxPathLength, "Maximum length of a path, query string excluded")
+	trapMaxQueryVariations := pflag.Int("trap-max-query-variations", crawler.DefaultMaxQueryVariations, "Maximum number of query strings per URL pattern")
+	trapMaxPagesPerPattern := pflag.Int("trap-max-pages-per-pattern", crawler.DefaultMaxPagesPerPattern, "Maximum number of pages per URL pattern")
+	visitedSetType := pflag.String("visited-set", "map", fmt.Sprintf("How visited pages are remembered %v", crawler.VisitedSetTypes))
+	bloomFalsePositiveRate := pflag.Float64("bloom-false-positive-rate", crawler.DefaultBloomFalsePositiveRate, "Rate of new pages the bloom visited set wrongly reports as visited")
+	visitedSetDir := pflag.String("visited-set-dir", "", "Directory of the disk visited set file (default the system temporary directory)")
+	strategy := pflag.String("strategy", "bfs", fmt.Sprintf("Order in which pages are crawled %v", crawler.StrategyNames))
+	patternWeights := pflag.StringToString("pattern-weight", nil, "Weight given by the best-first strategy to the pages matching a URL pattern, e.g. abc.com/blog/**=5")
+	maxPages := pflag.Int("max-pages", 0, "Maximum number of pages to crawl, 0 for no limit")
+	adaptiveConcurrency := pflag.Bool("adaptive-concurrency", false, "Adapt the number of concurrent requests per host to its latency and error rate, up to --workers")
+	initialConcurrency := pflag.Int("initial-concurrency", crawler.DefaultInitialConcurrency, "Concurrent requests per host to start with when adapting concurrency")
+	targetLatency := pflag.Duration("target-latency", crawler.DefaultTargetLatency, "Average latency above which the concurrency of a host is halved")
+	maxErrorRate := pflag.Float64("max-error-rate", crawler.DefaultMaxErrorRate, "Rate of failed requests above which the concurrency of a host is halved")
+	metricsAddr := pflag.String("metrics-addr", "", "Serve Prometheus metrics on /metrics at this address while crawling, e.g. :9090")
+	showProgress := pflag.Bool("progress", true, "Show the progress of the crawl on stderr, in place on a terminal or as a status line every --progress-interval otherwise")
+	progressInterval := pflag.Duration("progress-interval", crawler.DefaultProgressPlainInterval, "Time between two status lines when stderr is not a terminal")
+	timingReportPath := pflag.String("timing-report", "", "Write the slowest pages, with the time spent waiting, on DNS, connect, TLS, first byte and download, and the percentiles per URL pattern to this file, - for stdout")
+	slowestPages := pflag.Int("slowest-pages", crawler.DefaultSlowestPages, "Number of pages listed in the timing report")
+	errorReportPath := pflag.String("error-report", "", "Write the pages that failed or still answered 429 or 5XX after the last attempt, grouped by kind and host, to this file, - for stdout")
+	logLevel := pflag.String("log-level", "info", fmt.Sprintf("Minimum level of the log lines %v", crawler.LogLevels))
+	logFormat := pflag.String("log-format", "text", fmt.Sprintf("Format of the log lines %v", crawler.LogFormats))
+	cacheDir := pflag.String("cache-dir", "", "Keep responses in this directory and revalidate them on the next crawl instead of downloading them again")
+	outputPath := pflag.String("output", "", "Write the manifest of the crawl, one JSON line per page, to this file")
+	previousPath := pflag.String("previous", "", "Recrawl incrementally from the manifest written by --output on a previous crawl")
+	changesPath := pflag.String("changes", "", "Write the pages new, removed, modified and unchanged since the --previous crawl to this file as JSON, - for stdout")
 
 	pflag.Parse()
 
@@ -63,10 +536,62 @@ func parseCommandLineFlags() (*parameters, error) {
 		return nil, err
 	}
 
+	weights := make(map[string]float64, len(*patternWeights))
+	for pattern, rawWeight := range *patternWeights {
+		weights[pattern], err = strconv.ParseFloat(rawWeight, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight for pattern %s: %w", pattern, err)
+		}
+	}
+
 	return &parameters{
-		targetURL:       u,
-		timeout:         time.Duration(*timeout) * time.Second,
-		numberOfWorkers: *workers,
-		numberOfRetries: *retries,
+		targetURL:           u,
+		timeout:             time.Duration(*timeout) * time.Second,
+		numberOfWorkers:     *workers,
+		numberOfRetries:     *retries,
+		maxBodySize:         *maxBodySize,
+		allowedContentTypes: *allowedContentTypes,
+		deniedExtensions:    *deniedExtensions,
+		headBeforeGet:       *headBeforeGet,
+		followFeeds:         *followFeeds,
+		staleFeedAge:        *staleFeedAge,
+		graphDOTPath:        *graphDOTPath,
+		graphGraphMLPath:    *graphGraphMLPath,
+		graphCSVPrefix:      *graphCSVPrefix,
+		analysisJSONPath:    *analysisJSONPath,
+		analysisTablePath:   *analysisTablePath,
+		analysisSortBy:      *analysisSortBy,
+		pageRankDamping:     *pageRankDamping,
+		pageRankIterations:  *pageRankIterations,
+		detectTraps:         *detectTraps,
+		trapDetectorParams: crawler.TrapDetectorParams{
+			MaxRepeatedSegments: *trapMaxRepeats,
+			MaxPathDepth:        *trapMaxDepth,
+			MaxPathLength:       *trapMaxPathLength,
+			MaxQueryVariations:  *trapMaxQueryVariations,
+			MaxPagesPerPattern:  *trapMaxPagesPerPattern,
+		},
+		visitedSetType:         *visitedSetType,
+		bloomFalsePositiveRate: *bloomFalsePositiveRate,
+		visitedSetDir:          *visitedSetDir,
+		strategy:               *strategy,
+		patternWeights:         weights,
+		maxPages:               *maxPages,
+		adaptiveConcurrency:    *adaptiveConcurrency,
+		initialConcurrency:     *initialConcurrency,
+		targetLatency:          *targetLatency,
+		maxErrorRate:           *maxErrorRate,
+		metricsAddr:            *metricsAddr,
+		showProgress:           *showProgress,
+		progressInterval:       *progressInterval,
+		logLevel:               *logLevel,
+		logFormat:              *logFormat,
+		timingReportPath:       *timingReportPath,
+		slowestPages:           *slowestPages,
+		errorReportPath:        *errorReportPath,
+		cacheDir:               *cacheDir,
+		outputPath:             *outputPath,
+		previousPath:           *previousPath,
+		changesPath:            *changesPath,
 	}, nil
 }
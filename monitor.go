@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crawler/crawler"
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runMonitor is the monitor subcommand, which crawls a site on a schedule
+// until interrupted and notifies the changes between consecutive crawls.
+func runMonitor(args []string) error {
+	flags := pflag.NewFlagSet("monitor", pflag.ContinueOnError)
+	targetURL := flags.StringP("url", "u", "", "Target URL")
+	schedule := flags.String("schedule", "@hourly", "When to crawl, as a cron expression (e.g. \"*/30 * * * *\"), @hourly, @daily, @weekly, @monthly or @every <duration>")
+	dir := flags.String("dir", "monitor", "Directory keeping the manifest of the last crawl and the history of the runs")
+	historySize := flags.Int("history", crawler.DefaultHistorySize, "Number of run summaries kept in the history")
+	notify := flags.StringArray("notify", []string{"stdout"}, "Where to send alerts: stdout, file:<path> or the URL of a webhook to POST them to as JSON, repeatable")
+	runAtStart := flags.Bool("run-at-start", true, "Crawl once when starting instead of waiting for the schedule")
+	workers := flags.IntP("workers", "w", crawler.DefaultWorkers, "Number of workers")
+	timeout := flags.IntP("timeout", "t", 30, "HTTP timeout (seconds)")
+	retries := flags.UintP("retries", "r", crawler.DefaultRetryAttempts, "Number of task retries")
+	maxPages := flags.Int("max-pages", 0, "Maximum number of pages to crawl, 0 for no limit")
+	logLevel := flags.String("log-level", "info", fmt.Sprintf("Minimum level of the log lines %v", crawler.LogLevels))
+	logFormat := flags.String("log-format", "text", fmt.Sprintf("Format of the log lines %v", crawler.LogFormats))
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s monitor:\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *targetURL == "" {
+		return errors.New("url parameters is required")
+	}
+	u, err := url.Parse(*targetURL)
+	if err != nil {
+		return err
+	}
+
+	parsedSchedule, err := crawler.ParseSchedule(*schedule)
+	if err != nil {
+		return err
+	}
+
+	logger, err := crawler.NewLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		return err
+	}
+
+	httpClient := &http.Client{Timeout: time.Duration(*timeout) * time.Second}
+	var notifiers []crawler.Notifier
+	for _, spec := range *notify {
+		notifier, err := parseNotifier(spec, httpClient)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	crawl := func(ctx context.Context, previous *crawler.Manifest) (*crawler.Manifest, error) {
+		c := crawler.New(
+			crawler.WithHTTPClient(httpClient),
+			crawler.WithWorkers(*workers),
+			crawler.WithRetryAttempts(*retries),
+			crawler.WithMaxPages(*maxPages),
+			crawler.WithPreviousManifest(previous),
+			crawler.WithLogger(logger),
+		)
+
+		iterator, err := c.Iterate(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		defer iterator.Close()
+
+		manifest := crawler.NewManifest()
+		for iterator.Next() {
+			result := iterator.Result()
+			previousEntry, _ := previous.Lookup(result.TargetURL)
+			if err := manifest.Add(crawler.NewManifestEntry(result, previousEntry, time.Now())); err != nil {
+				return nil, err
+			}
+		}
+
+		return manifest, iterator.Err()
+	}
+
+	monitor, err := crawler.NewMonitor(crawl, crawler.MonitorParams{
+		Site:        u.Host,
+		Schedule:    parsedSchedule,
+		Dir:         *dir,
+		HistorySize: *historySize,
+		RunAtStart:  *runAtStart,
+		Partial:     *maxPages > 0,
+		Notifiers:   notifiers,
+		Logger:      logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return monitor.Run(ctx)
+}
+
+// parseNotifier parses a --notify value.
+func parseNotifier(spec string, httpClient *http.Client) (crawler.Notifier, error) {
+	switch {
+	case spec == "stdout":
+		return crawler.NewWriterNotifier(os.Stdout), nil
+	case strings.HasPrefix(spec, "file:"):
+		return crawler.NewFileNotifier(strings.TrimPrefix(spec, "file:")), nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return crawler.NewWebhookNotifier(spec, httpClient), nil
+	}
+
+	return nil, fmt.Errorf("unknown notifier %q, expected stdout, file:<path> or a webhook URL", spec)
+}